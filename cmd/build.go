@@ -1,20 +1,50 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/NiR-/notpecl/pecl"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/xerrors"
 )
 
 var buildFlags = struct {
-	xml     string
-	cleanup bool
+	xml      string
+	cleanup  bool
+	jobs     int
+	printEnv bool
+	buildDir string
+	compilerFlags
 }{}
 
+// compilerFlags are the flags overriding the compiler flags used to build
+// extensions, shared by the build and install commands.
+type compilerFlags struct {
+	cflags   string
+	cppflags string
+	ldflags  string
+}
+
+func (f *compilerFlags) register(flags *pflag.FlagSet) {
+	flags.StringVar(&f.cflags,
+		"cflags",
+		"",
+		"CFLAGS used to build extensions (defaults to $PHP_CFLAGS, or to the flags used by the official PHP Docker images).")
+	flags.StringVar(&f.cppflags,
+		"cppflags",
+		"",
+		"CPPFLAGS used to build extensions (defaults to $PHP_CPPFLAGS, or to the flags used by the official PHP Docker images).")
+	flags.StringVar(&f.ldflags,
+		"ldflags",
+		"",
+		"LDFLAGS used to build extensions (defaults to $PHP_LDFLAGS, or to the flags used by the official PHP Docker images).")
+}
+
 func NewBuildCmd() *cobra.Command {
 	build := &cobra.Command{
 		Use:                   "build [--xml=<xml-path>] [<src-path>] -- [<extra-configure-args>]",
@@ -24,31 +54,62 @@ func NewBuildCmd() *cobra.Command {
 		Run:                   run(runBuildCmd),
 	}
 
-	build.Flags().StringVar(&buildFlags.xml, "xml", "", "Path to the package.xml file relative to the given source path.")
+	build.Flags().StringVar(&buildFlags.xml, "xml", "", "Path to the package.xml file relative to the given source path (defaults to the package.xml in the source path or its parent, if any, and falls back to its config.m4 otherwise).")
 	build.Flags().BoolVar(&buildFlags.cleanup,
 		"cleanup",
 		true,
 		"Remove build files after building the extension (enabled by default).")
+	build.Flags().IntVarP(&buildFlags.jobs,
+		"jobs",
+		"j",
+		0,
+		"Number of parallel jobs run by make (defaults to the number of CPUs, ignored when MAKEFLAGS sets it or when run by a parent make sharing its jobserver).")
+	build.Flags().BoolVar(&buildFlags.printEnv,
+		"print-env",
+		false,
+		"Print the environment variables phpize, configure and make would be run with, and exit without building.")
+	build.Flags().StringVar(&buildFlags.buildDir,
+		"build-dir",
+		"",
+		"Directory where the extension is built out of tree when building for several php-config, in a subdir per PHP version (defaults to <tmpdir>/notpecl/builds/<source dir name>).")
+	buildFlags.compilerFlags.register(build.Flags())
+	registerPhpFlags(build.Flags())
 
 	return build
 }
 
-func runBuildCmd(cmd *cobra.Command, args []string) error {
+func runBuildCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
 	extDir := cwd()
 	if len(args) > 0 {
 		extDir = args[0]
 		args = args[1:]
 	}
 
+	p := initPeclBackend()
+
+	if buildFlags.printEnv {
+		env := p.BuildEnv(pecl.BuildOpts{
+			CFlags:   buildFlags.cflags,
+			CPPFlags: buildFlags.cppflags,
+			LDFlags:  buildFlags.ldflags,
+		})
+		for _, v := range env {
+			fmt.Println(v)
+		}
+		return nil
+	}
+
 	if buildFlags.xml == "" {
 		if pathExists(filepath.Join(extDir, "package.xml")) {
 			buildFlags.xml = filepath.Join(extDir, "package.xml")
 		} else if pathExists(filepath.Join(extDir, "..", "package.xml")) {
 			buildFlags.xml = filepath.Join(extDir, "..", "package.xml")
+		} else if pathExists(filepath.Join(extDir, "config.m4")) {
+			logrus.Infof("No package.xml found in %s, nor in its parent directory: building the extension declared by its config.m4.", extDir)
 		} else {
 			return xerrors.Errorf(
-				"no package.xml found in %s, nor in its parent directory",
-				filepath.Join(extDir, "package.xml"))
+				"no package.xml found in %s, nor in its parent directory, and no config.m4 either",
+				extDir)
 		}
 	}
 
@@ -56,13 +117,41 @@ func runBuildCmd(cmd *cobra.Command, args []string) error {
 		SourceDir:      extDir,
 		PackageXmlPath: buildFlags.xml,
 		ConfigureArgs:  []string{},
-		Parallel:       findMaxParallelism(),
+		CFlags:         buildFlags.cflags,
+		CPPFlags:       buildFlags.cppflags,
+		LDFlags:        buildFlags.ldflags,
+		Parallel:       resolveJobs(buildFlags.jobs),
 		Cleanup:        buildFlags.cleanup,
 	}
 	opts.ConfigureArgs = args
 
-	p := initPeclBackend()
-	return p.Build(opts)
+	if len(phpFlags.phpConfig) <= 1 {
+		return p.Build(ctx, opts)
+	}
+
+	if err := checkMatrixFlags(); err != nil {
+		return err
+	}
+	absExtDir, err := filepath.Abs(extDir)
+	if err != nil {
+		return err
+	}
+	buildRoot := buildFlags.buildDir
+	if buildRoot == "" {
+		buildRoot = filepath.Join(os.TempDir(), "notpecl", "builds", filepath.Base(absExtDir))
+	}
+
+	newBackend := func(opts ...pecl.BackendOpt) (pecl.Backend, error) {
+		return initChannelBackend(initPeclClient(), opts...), nil
+	}
+	results := buildMatrix(ctx, filepath.Base(absExtDir), phpFlags.phpConfig, buildRoot, newBackend,
+		func(p pecl.Backend, buildDir string) error {
+			opts := opts
+			opts.BuildDir = buildDir
+			return p.Build(ctx, opts)
+		})
+
+	return printMatrixSummary(results)
 }
 
 func cwd() string {
@@ -75,5 +164,5 @@ func cwd() string {
 
 func pathExists(fullpath string) bool {
 	_, err := os.Stat(fullpath)
-	return err != nil
+	return err == nil
 }
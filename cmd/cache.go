@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func NewCacheCmd() *cobra.Command {
+	cache := &cobra.Command{
+		Use:               "cache",
+		DisableAutoGenTag: true,
+		Short:             "manage the local cache of REST metadata and release archives",
+	}
+
+	cache.AddCommand(&cobra.Command{
+		Use:               "list",
+		DisableAutoGenTag: true,
+		Short:             "list cached URLs",
+		Run:               run(runCacheListCmd),
+	})
+	cache.AddCommand(&cobra.Command{
+		Use:               "clear",
+		DisableAutoGenTag: true,
+		Short:             "remove everything notpecl wrote to the cache dir",
+		Run:               run(runCacheClearCmd),
+	})
+	cache.AddCommand(&cobra.Command{
+		Use:               "prune",
+		DisableAutoGenTag: true,
+		Short:             "remove expired metadata and unreferenced files from the cache",
+		Run:               run(runCachePruneCmd),
+	})
+
+	return cache
+}
+
+func runCacheListCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	c := initPeclCache()
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tKIND\tSIZE\tFETCHED AT\tEXPIRED")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%t\n",
+			e.URL,
+			e.Kind,
+			e.Size,
+			e.FetchedAt.Format(time.RFC3339),
+			c.IsExpired(e))
+	}
+
+	return w.Flush()
+}
+
+func runCacheClearCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	c := initPeclCache()
+	if err := c.Clear(); err != nil {
+		return err
+	}
+
+	logrus.Infof("Cache %s cleared.", c.Dir())
+	return nil
+}
+
+func runCachePruneCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	c := initPeclCache()
+	removed, err := c.Prune()
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("%d files removed from %s.", removed, c.Dir())
+	return nil
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclchannel"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var channelFlags = struct {
+	alias string
+}{}
+
+func NewChannelCmd() *cobra.Command {
+	channel := &cobra.Command{
+		Use:               "channel",
+		DisableAutoGenTag: true,
+		Short:             "manage the PEAR channels extensions can be installed from",
+	}
+
+	add := &cobra.Command{
+		Use:               "add <channel-name|channel.xml URL>",
+		DisableAutoGenTag: true,
+		Short:             "discover a channel through its channel.xml and add it",
+		Args:              cobra.ExactArgs(1),
+		Run:               run(runChannelAddCmd),
+	}
+	add.Flags().StringVar(&channelFlags.alias,
+		"alias",
+		"",
+		"Alias of the channel, usable in extension specs (defaults to the alias suggested by the channel).")
+
+	channel.AddCommand(add)
+	channel.AddCommand(&cobra.Command{
+		Use:               "list",
+		DisableAutoGenTag: true,
+		Short:             "list known channels",
+		Run:               run(runChannelListCmd),
+	})
+	channel.AddCommand(&cobra.Command{
+		Use:               "remove <channel-name|alias>",
+		DisableAutoGenTag: true,
+		Short:             "remove a channel",
+		Args:              cobra.ExactArgs(1),
+		Run:               run(runChannelRemoveCmd),
+	})
+
+	return channel
+}
+
+func channelsFilePath() string {
+	return filepath.Join(rootFlags.configDir, "channels.json")
+}
+
+func runChannelAddCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	r, err := peclchannel.LoadFromFile(channelsFilePath())
+	if err != nil {
+		return err
+	}
+
+	discovered, err := initPeclClient().DiscoverChannel(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	ch, err := peclchannel.FromDiscovery(discovered, channelFlags.alias)
+	if err != nil {
+		return err
+	}
+	if err := r.Add(ch); err != nil {
+		return err
+	}
+	if err := r.WriteToFile(channelsFilePath()); err != nil {
+		return err
+	}
+
+	logrus.Infof("Channel %s added (alias: %q, REST API: %s).", ch.Name, ch.Alias, ch.BaseURL)
+	return nil
+}
+
+func runChannelListCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	r, err := peclchannel.LoadFromFile(channelsFilePath())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tALIAS\tREST API\tSUMMARY")
+	for _, ch := range r.List() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ch.Name, ch.Alias, ch.BaseURL, ch.Summary)
+	}
+
+	return w.Flush()
+}
+
+func runChannelRemoveCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	r, err := peclchannel.LoadFromFile(channelsFilePath())
+	if err != nil {
+		return err
+	}
+	if err := r.Remove(args[0]); err != nil {
+		return err
+	}
+	if err := r.WriteToFile(channelsFilePath()); err != nil {
+		return err
+	}
+
+	logrus.Infof("Channel %s removed.", args[0])
+	return nil
+}
+
+// channelClients lazily creates the peclapi.Client of the channels referenced
+// by extension specs. It's not safe for concurrent use.
+type channelClients struct {
+	registry peclchannel.Registry
+	clients  map[string]peclapi.Client
+}
+
+func loadChannelClients() (*channelClients, error) {
+	r, err := peclchannel.LoadFromFile(channelsFilePath())
+	if err != nil {
+		return nil, err
+	}
+
+	return &channelClients{
+		registry: r,
+		clients:  map[string]peclapi.Client{},
+	}, nil
+}
+
+// Client returns the client of the given channel name or alias. An empty
+// channel designates the default pecl channel.
+func (cc *channelClients) Client(channel string) (peclapi.Client, error) {
+	ch, err := cc.registry.Resolve(channel)
+	if err != nil {
+		return peclapi.Client{}, err
+	}
+
+	if client, ok := cc.clients[ch.Name]; ok {
+		return client, nil
+	}
+
+	client := initChannelClient(ch)
+	cc.clients[ch.Name] = client
+	return client, nil
+}
+
+// Backend returns a pecl.Backend using the client of the given channel, and
+// the given opts (see initChannelBackend).
+func (cc *channelClients) Backend(channel string, opts ...pecl.BackendOpt) (pecl.Backend, error) {
+	client, err := cc.Client(channel)
+	if err != nil {
+		return nil, err
+	}
+	return initChannelBackend(client, opts...), nil
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"context"
-	"strings"
 
 	"github.com/NiR-/notpecl/pecl"
 	"github.com/NiR-/notpecl/peclapi"
@@ -19,7 +18,7 @@ var downloadFlags = struct {
 
 func NewDownloadCmd() *cobra.Command {
 	download := &cobra.Command{
-		Use:               "download <extension[:constraint]> ...",
+		Use:               "download <[channel/]extension[:constraint][@stability]> ...",
 		DisableAutoGenTag: true,
 		Short:             "download the given extensions and optionally unpack them",
 		Run:               run(runDownloadCmd),
@@ -31,22 +30,29 @@ func NewDownloadCmd() *cobra.Command {
 		"Directory where the extensions should be downloaded and compiled (defaults to a temporary directory).")
 	download.Flags().StringVar(&downloadFlags.minimumStability,
 		"minimum-stability",
-		string(peclapi.Stable),
+		peclapi.Stable.String(),
 		"Minimum stability level to look for when resolving version constraints (default: stable, available: stable > beta > alpha > devel > snapshot)",
 	)
 
 	return download
 }
 
-func runDownloadCmd(cmd *cobra.Command, args []string) error {
-	p := initPeclBackend()
+func runDownloadCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	channels, err := loadChannelClients()
+	if err != nil {
+		return err
+	}
 
 	if len(args) == 0 {
 		return xerrors.Errorf("you have to provide at least one extension")
 	}
 
-	eg, _ := errgroup.WithContext(context.TODO())
-	stability := peclapi.StabilityFromString(downloadFlags.minimumStability)
+	specs, err := parseExtensionSpecs(args, downloadFlags.minimumStability)
+	if err != nil {
+		return err
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
 	downloadDir := downloadFlags.downloadDir
 	if downloadDir == "" {
 		var err error
@@ -55,32 +61,30 @@ func runDownloadCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	for i := range args {
-		ext := args[i]
-		eg.Go(func() error {
-			segments := strings.SplitN(ext, ":", 2)
-			name := segments[0]
-			constraint := "*"
-			if len(segments) == 2 {
-				constraint = segments[1]
-			}
+	for i := range specs {
+		spec := specs[i]
+		p, err := channels.Backend(spec.Channel)
+		if err != nil {
+			return err
+		}
 
-			version, err := p.ResolveConstraint(name, constraint, stability)
+		eg.Go(func() error {
+			version, err := p.ResolveConstraint(ctx, spec.Name, spec.Constraint, spec.MinimumStability)
 			if err != nil {
 				return err
 			}
 
 			opts := pecl.DownloadOpts{
-				Extension:   name,
+				Extension:   spec.Name,
 				Version:     version,
 				DownloadDir: downloadDir,
 			}
-			extDir, err := p.Download(opts)
+			extDir, err := p.Download(ctx, opts)
 			if err != nil {
 				return err
 			}
 
-			logrus.Infof("Extension %s downloaded to %q", name, extDir)
+			logrus.Infof("Extension %s downloaded to %q", spec.Name, extDir)
 			return nil
 		})
 	}
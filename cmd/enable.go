@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var enableFlags = struct {
+	installDir string
+	iniDir     string
+	priority   int
+	ini        []string
+}{}
+
+func NewEnableCmd() *cobra.Command {
+	enable := &cobra.Command{
+		Use:               "enable <extension>",
+		DisableAutoGenTag: true,
+		Short:             "enable an installed extension by writing an ini file to the scan dir of PHP",
+		Args:              cobra.ExactArgs(1),
+		Run:               run(runEnableCmd),
+	}
+
+	enable.Flags().StringVar(&enableFlags.installDir,
+		"install-dir",
+		"",
+		"Directory where the extension was installed (see install --install-dir). The ini file is written to the scan dir of PHP within it.")
+	enable.Flags().StringVar(&enableFlags.iniDir,
+		"ini-dir",
+		"",
+		"Directory where the ini file should be written (defaults to the dir scanned by PHP for additional ini files).")
+	enable.Flags().IntVar(&enableFlags.priority,
+		"priority",
+		pecl.DefaultIniPriority,
+		"Priority of the ini file, used as a prefix of its name (eg. 20-redis.ini) to control the order in which extensions are loaded.")
+	enable.Flags().StringArrayVar(&enableFlags.ini,
+		"ini",
+		[]string{},
+		"Extra ini directive written to the ini file, in the format <directive>=<value> (eg. redis.session.locking_enabled=1). Can be repeated.")
+	registerPhpFlags(enable.Flags())
+
+	return enable
+}
+
+func runEnableCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	if len(phpFlags.phpConfig) > 1 {
+		return xerrors.Errorf("--php-config can only be used once with enable")
+	}
+
+	directives, err := parseIniFlags(enableFlags.ini)
+	if err != nil {
+		return err
+	}
+
+	p := initPeclBackend()
+	return p.Enable(ctx, pecl.EnableOpts{
+		Extension:  args[0],
+		InstallDir: enableFlags.installDir,
+		IniDir:     enableFlags.iniDir,
+		Priority:   enableFlags.priority,
+		Directives: directives,
+	})
+}
+
+// parseIniFlags parses --ini flags in the format <directive>=<value>.
+func parseIniFlags(flags []string) (map[string]string, error) {
+	directives := map[string]string{}
+	for _, flag := range flags {
+		segments := strings.SplitN(flag, "=", 2)
+		if len(segments) != 2 || strings.TrimSpace(segments[0]) == "" {
+			return nil, xerrors.Errorf("invalid --ini flag %q: expected format <directive>=<value>", flag)
+		}
+		directives[strings.TrimSpace(segments[0])] = segments[1]
+	}
+	return directives, nil
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclpkg"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var infoFlags = struct {
+	minimumStability string
+	changelog        int
+}{}
+
+func NewInfoCmd() *cobra.Command {
+	info := &cobra.Command{
+		Use:               "info <[channel/]extension[:constraint][@stability]>",
+		DisableAutoGenTag: true,
+		Short:             "show the metadata, the releases and the dependencies of an extension",
+		Args:              cobra.ExactArgs(1),
+		Run:               run(runInfoCmd),
+	}
+
+	info.Flags().StringVar(&infoFlags.minimumStability,
+		"minimum-stability",
+		peclapi.Stable.String(),
+		"Minimum stability level to look for when resolving the version constraint (default: stable, available: stable > beta > alpha > devel > snapshot)")
+	info.Flags().IntVar(&infoFlags.changelog,
+		"changelog",
+		3,
+		"Number of changelog entries to show.")
+
+	return info
+}
+
+func runInfoCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	if infoFlags.changelog < 0 {
+		return xerrors.Errorf("--changelog can't be negative, got %d", infoFlags.changelog)
+	}
+
+	channels, err := loadChannelClients()
+	if err != nil {
+		return err
+	}
+	specs, err := parseExtensionSpecs(args, infoFlags.minimumStability)
+	if err != nil {
+		return err
+	}
+	spec := specs[0]
+
+	p, err := channels.Backend(spec.Channel)
+	if err != nil {
+		return err
+	}
+	info, err := p.Info(ctx, spec.Name, spec.Constraint, spec.MinimumStability)
+	if err != nil {
+		return err
+	}
+
+	printExtensionInfo(info, infoFlags.changelog)
+	return nil
+}
+
+func printExtensionInfo(info pecl.ExtensionInfo, changelogEntries int) {
+	pkg := info.PackageXML
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Name:\t%s\n", info.Package.Name)
+	fmt.Fprintf(w, "Summary:\t%s\n", valueOrDash(info.Package.Summary))
+	fmt.Fprintf(w, "Category:\t%s\n", valueOrDash(info.Package.Category))
+	fmt.Fprintf(w, "License:\t%s\n", valueOrDash(info.Package.License))
+	fmt.Fprintf(w, "Maintainers:\t%s\n", valueOrDash(strings.Join(formatMaintainers(pkg), ", ")))
+
+	var latest []string
+	for _, stability := range []peclapi.Stability{peclapi.Stable, peclapi.Beta, peclapi.Alpha, peclapi.Devel, peclapi.Snapshot} {
+		if release := info.Latest(stability); release != "" {
+			latest = append(latest, fmt.Sprintf("%s (%s)", release, stability))
+		}
+	}
+	fmt.Fprintf(w, "Latest releases:\t%s\n", valueOrDash(strings.Join(latest, ", ")))
+	fmt.Fprintf(w, "Releases:\t%d\n", len(info.Releases))
+
+	fmt.Fprintf(w, "\nRelease:\t%s\n", info.Release.Version)
+	fmt.Fprintf(w, "Stability:\t%s\n", valueOrDash(info.Release.Stability))
+	fmt.Fprintf(w, "Released at:\t%s\n", valueOrDash(info.Release.ReleaseDate))
+
+	php := pkg.Dependencies.Required.PHP
+	fmt.Fprintf(w, "PHP:\t%s\n", formatConstraint(php.Min, php.Max, php.Exclude))
+	for _, ext := range pkg.Dependencies.Required.Extensions {
+		fmt.Fprintf(w, "Requires:\t%s %s\n", ext.Name, formatConstraint(ext.Min, ext.Max, ext.Exclude))
+	}
+	for _, ext := range pkg.Dependencies.Optional.Extensions {
+		fmt.Fprintf(w, "Suggests:\t%s %s\n", ext.Name, formatConstraint(ext.Min, ext.Max, ext.Exclude))
+	}
+	w.Flush()
+
+	if len(pkg.ExtSrcRelease.ConfigureOptions) > 0 {
+		fmt.Println("\nConfigure options:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tDEFAULT\tPROMPT")
+		for _, opt := range pkg.ExtSrcRelease.ConfigureOptions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", opt.Name, valueOrDash(opt.Default), opt.Prompt)
+		}
+		w.Flush()
+	}
+
+	entries := pkg.Changelog.Releases
+	if changelogEntries < len(entries) {
+		entries = entries[:changelogEntries]
+	}
+	if len(entries) > 0 {
+		fmt.Println("\nChangelog:")
+	}
+	for _, entry := range entries {
+		fmt.Printf("\n  %s (%s, %s)\n", entry.Version.Release, entry.Stability.Release, entry.Date)
+		for _, line := range strings.Split(strings.TrimSpace(entry.Notes), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				fmt.Println()
+				continue
+			}
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
+
+// formatMaintainers returns the maintainers listed by the package.xml, along
+// with their role. Inactive maintainers are flagged as such.
+func formatMaintainers(pkg peclpkg.Package) []string {
+	var maintainers []string
+	roles := []struct {
+		name   string
+		people []peclpkg.Maintainer
+	}{
+		{"lead", pkg.Leads},
+		{"developer", pkg.Developers},
+		{"contributor", pkg.Contributors},
+		{"helper", pkg.Helpers},
+	}
+
+	for _, role := range roles {
+		for _, m := range role.people {
+			desc := m.Name
+			if m.Email != "" {
+				desc += fmt.Sprintf(" <%s>", m.Email)
+			}
+			desc += " (" + role.name
+			if m.Active == "no" {
+				desc += ", inactive"
+			}
+			maintainers = append(maintainers, desc+")")
+		}
+	}
+	return maintainers
+}
+
+// formatConstraint formats the min/max/exclude constraint of a dependency
+// (eg. ">= 7.0.0, <= 7.9.99").
+func formatConstraint(min, max string, exclude []string) string {
+	var parts []string
+	if min != "" {
+		parts = append(parts, ">= "+min)
+	}
+	if max != "" {
+		parts = append(parts, "<= "+max)
+	}
+	for _, excluded := range exclude {
+		parts = append(parts, "!= "+excluded)
+	}
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"path/filepath"
 	"strings"
 
+	"github.com/NiR-/notpecl/lockfile"
+	"github.com/NiR-/notpecl/manifest"
 	"github.com/NiR-/notpecl/pecl"
 	"github.com/NiR-/notpecl/peclapi"
 	"github.com/spf13/cobra"
@@ -14,13 +18,21 @@ var installFlags = struct {
 	minimumStability string
 	downloadDir      string
 	installDir       string
+	configure        []string
+	configureOptions []string
+	file             string
+	locked           bool
+	lockFile         string
+	jobs             int
+	enable           bool
+	compilerFlags
 }{
 	cleanup: true,
 }
 
 func NewInstallCmd() *cobra.Command {
 	install := &cobra.Command{
-		Use:               "install",
+		Use:               "install [-f <manifest>] <[channel/]extension[:constraint][@stability]|path/to/archive.tgz|path/to/src|git+<url>[#<ref>]> ...",
 		DisableAutoGenTag: true,
 		Short:             "install the given extensions",
 		Run:               run(runInstallCmd),
@@ -42,51 +54,381 @@ func NewInstallCmd() *cobra.Command {
 		"install-dir",
 		"",
 		"Directory where the extensions shoud be installed.")
-	// @TODO: add a flag to set configure args for each extension
+	install.Flags().StringArrayVar(&installFlags.configure,
+		"configure",
+		[]string{},
+		"Flags passed to ./configure for a given extension, in the format <extension>=\"<flags>\" (eg. redis=\"--enable-redis-lzf\"). Flags are split like a shell does, quote values containing spaces. Can be repeated.")
+	install.Flags().StringArrayVar(&installFlags.configureOptions,
+		"configure-option",
+		[]string{},
+		"Answer to a configure option declared by the package.xml of a given extension, in the format <extension>:<option>=<value> (eg. redis:enable-redis-igbinary=yes). Can be repeated.")
+	install.Flags().StringVarP(&installFlags.file,
+		"file",
+		"f",
+		"",
+		"Path to a manifest file (either JSON or YAML) listing the extensions to install.")
+	install.Flags().BoolVar(&installFlags.locked,
+		"locked",
+		false,
+		"Install the exact releases recorded in the lock file of the manifest and verify their checksum (requires --file).")
+	install.Flags().StringVar(&installFlags.lockFile,
+		"lock-file",
+		"",
+		"Path to the lock file used with --locked (defaults to the manifest path with a .lock extension).")
+	install.Flags().IntVarP(&installFlags.jobs,
+		"jobs",
+		"j",
+		0,
+		"Number of parallel jobs run by make (defaults to the number of CPUs, ignored when MAKEFLAGS sets it or when run by a parent make sharing its jobserver).")
+	install.Flags().BoolVar(&installFlags.enable,
+		"enable",
+		false,
+		"Enable the extensions once installed, by writing an ini file loading them to the scan dir of PHP (see the enable command).")
+	installFlags.compilerFlags.register(install.Flags())
+	registerPhpFlags(install.Flags())
 
 	return install
 }
 
-func runInstallCmd(cmd *cobra.Command, args []string) error {
-	p := initPeclBackend()
+func runInstallCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	channels, err := loadChannelClients()
+	if err != nil {
+		return err
+	}
 
-	stability := peclapi.StabilityFromString(installFlags.minimumStability)
 	downloadDir := installFlags.downloadDir
 	if downloadDir == "" {
-		var err error
 		if downloadDir, err = resolveTmpDownloadDir(); err != nil {
 			return xerrors.Errorf("failed to find where downloaded files should be written: %w", err)
 		}
 	}
 
-	for _, arg := range args {
-		segments := strings.SplitN(arg, ":", 2)
-		extName := segments[0]
-		extVerConstraint := "*"
-		if len(segments) == 2 {
-			extVerConstraint = segments[1]
+	m, err := loadInstallManifest(ctx, args, downloadDir)
+	if err != nil {
+		return err
+	}
+
+	var lock *lockfile.LockFile
+	if installFlags.locked {
+		if installFlags.file == "" {
+			return xerrors.Errorf("--locked can only be used when installing from a manifest file")
+		}
+
+		lockPath := installFlags.lockFile
+		if lockPath == "" {
+			lockPath = lockfile.PathFor(installFlags.file)
+		}
+
+		l, err := lockfile.LoadFromFile(lockPath)
+		if err != nil {
+			return err
+		}
+		lock = &l
+	}
+
+	// --php-config flags take precedence over the matrix of the manifest.
+	phpConfigs := phpFlags.phpConfig
+	if len(phpConfigs) == 0 {
+		phpConfigs = m.Matrix.PhpConfig
+	}
+	var backendOpts []pecl.BackendOpt
+	if len(phpConfigs) == 1 {
+		backendOpts = append(backendOpts, pecl.WithPhpConfigPath(phpConfigs[0]))
+	} else if len(phpConfigs) > 1 {
+		if err := checkMatrixFlags(); err != nil {
+			return err
 		}
+	}
+	var results []matrixBuild
 
-		extVersion, err := p.ResolveConstraint(extName, extVerConstraint, stability)
+	for _, ext := range m.Extensions {
+		spec, err := m.Spec(ext)
+		if err != nil {
+			return err
+		}
+		p, err := channels.Backend(spec.Channel, backendOpts...)
 		if err != nil {
 			return err
 		}
 
-		opts := pecl.InstallOpts{
-			DownloadOpts: pecl.DownloadOpts{
-				Extension:   extName,
-				Version:     extVersion,
-				DownloadDir: downloadDir,
-			},
-			ConfigureArgs: []string{},
-			Parallel:      findMaxParallelism(),
-			Cleanup:       installFlags.cleanup,
-			InstallDir:    installFlags.installDir,
+		var extVersion, checksum string
+		switch {
+		case ext.Source != "":
+			// Local sources can't be resolved nor locked, their version is
+			// read from their package.xml.
+		case lock != nil:
+			locked, err := lock.Locked(spec)
+			if err != nil {
+				return err
+			}
+			extVersion = locked.Version
+			checksum = locked.Sha256
+		default:
+			extVersion, err = p.ResolveConstraint(ctx, spec.Name, spec.Constraint, spec.MinimumStability)
+			if err != nil {
+				return err
+			}
 		}
-		if err := p.Install(opts); err != nil {
+
+		ch, err := channels.registry.Resolve(spec.Channel)
+		if err != nil {
 			return err
 		}
+
+		opts := ext.InstallOpts(extVersion, downloadDir)
+		opts.Channel = ch.Name
+		opts.Constraint = spec.Constraint
+		opts.MinimumStability = spec.MinimumStability
+		opts.Checksum = checksum
+		opts.CFlags = installFlags.cflags
+		opts.CPPFlags = installFlags.cppflags
+		opts.LDFlags = installFlags.ldflags
+		opts.Parallel = resolveJobs(installFlags.jobs)
+		opts.Cleanup = installFlags.cleanup
+		if opts.InstallDir == "" {
+			opts.InstallDir = installFlags.installDir
+		}
+
+		enableOpts := ext.EnableOpts()
+		enableOpts.InstallDir = opts.InstallDir
+
+		if len(phpConfigs) <= 1 {
+			if err := p.Install(ctx, opts); err != nil {
+				return err
+			}
+			if installFlags.enable {
+				if err := p.Enable(ctx, enableOpts); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		channel := spec.Channel
+		newBackend := func(opts ...pecl.BackendOpt) (pecl.Backend, error) {
+			return channels.Backend(channel, opts...)
+		}
+		buildRoot := filepath.Join(downloadDir, "builds", ext.Name)
+		results = append(results, buildMatrix(ctx, ext.Name, phpConfigs, buildRoot, newBackend,
+			func(p pecl.Backend, buildDir string) error {
+				opts := opts
+				opts.BuildDir = buildDir
+				if err := p.Install(ctx, opts); err != nil {
+					return err
+				}
+				if installFlags.enable {
+					return p.Enable(ctx, enableOpts)
+				}
+				return nil
+			})...)
 	}
 
+	if len(phpConfigs) > 1 {
+		return printMatrixSummary(results)
+	}
 	return nil
 }
+
+// loadInstallManifest either loads the manifest passed through --file, or
+// builds a manifest from the extension specs and sources passed as args and
+// from the --configure and --configure-option flags. Git sources are fetched
+// to downloadDir to find the name of their package, and pinned to the commit
+// checked out.
+func loadInstallManifest(ctx context.Context, args []string, downloadDir string) (manifest.Manifest, error) {
+	if installFlags.file != "" {
+		if len(args) > 0 || len(installFlags.configure) > 0 || len(installFlags.configureOptions) > 0 {
+			return manifest.Manifest{}, xerrors.Errorf("extensions and configure flags can't be passed when installing from a manifest file")
+		}
+
+		m, err := manifest.LoadFromFile(installFlags.file)
+		if err != nil {
+			return m, err
+		}
+		if m.MinimumStability == "" {
+			m.MinimumStability = installFlags.minimumStability
+		}
+		return m, m.Validate()
+	}
+
+	var specArgs []string
+	sources := map[string]string{}
+	for _, arg := range args {
+		if !isSourceArg(arg) {
+			specArgs = append(specArgs, arg)
+			continue
+		}
+
+		pkg, source, err := initPeclBackend().LoadSourcePackage(ctx, arg, downloadDir)
+		if err != nil {
+			return manifest.Manifest{}, err
+		}
+		sources[pkg.Name] = source
+		specArgs = append(specArgs, pkg.Name)
+	}
+
+	specs, err := parseExtensionSpecs(specArgs, installFlags.minimumStability)
+	if err != nil {
+		return manifest.Manifest{}, err
+	}
+
+	configureArgs, err := parseConfigureFlags(installFlags.configure, specs)
+	if err != nil {
+		return manifest.Manifest{}, err
+	}
+	configureOptions, err := parseConfigureOptionFlags(installFlags.configureOptions, specs)
+	if err != nil {
+		return manifest.Manifest{}, err
+	}
+
+	m := manifest.Manifest{
+		MinimumStability: installFlags.minimumStability,
+		Extensions:       make([]manifest.Extension, 0, len(specs)),
+	}
+	for _, spec := range specs {
+		m.Extensions = append(m.Extensions, manifest.Extension{
+			Channel:          spec.Channel,
+			Name:             spec.Name,
+			Constraint:       spec.Constraint,
+			MinimumStability: spec.MinimumStability.String(),
+			ConfigureArgs:    configureArgs[spec.Name],
+			ConfigureOptions: configureOptions[spec.Name],
+			Source:           sources[spec.Name],
+		})
+	}
+
+	return m, nil
+}
+
+// isSourceArg returns true when the given install arg is a git source or the
+// path to a local release archive or source dir rather than an extension spec.
+// Paths have to either end with .tgz/.tar.gz or to start with /, ./ or ../ to
+// be told apart from extension specs (which could contain a channel name and
+// a slash).
+func isSourceArg(arg string) bool {
+	if pecl.IsGitSource(arg) {
+		return true
+	}
+	for _, prefix := range []string{"/", "./", "../"} {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return strings.HasSuffix(arg, ".tgz") || strings.HasSuffix(arg, ".tar.gz") || arg == "." || arg == ".."
+}
+
+// parseConfigureFlags parses --configure flags in the format
+// <extension>=<flags> and returns the list of configure flags for each
+// extension. Flags are split like a shell would do (see splitShellWords),
+// such that values containing whitespaces could be quoted.
+func parseConfigureFlags(flags []string, specs []pecl.ExtensionSpec) (map[string][]string, error) {
+	args := map[string][]string{}
+	for _, flag := range flags {
+		segments := strings.SplitN(flag, "=", 2)
+		if len(segments) != 2 || segments[0] == "" {
+			return nil, xerrors.Errorf("invalid --configure flag %q: expected format <extension>=<flags>", flag)
+		}
+
+		extName := segments[0]
+		if !hasExtensionSpec(specs, extName) {
+			return nil, xerrors.Errorf("invalid --configure flag %q: extension %s is not being installed", flag, extName)
+		}
+
+		words, err := splitShellWords(segments[1])
+		if err != nil {
+			return nil, xerrors.Errorf("invalid --configure flag %q: %w", flag, err)
+		}
+		args[extName] = append(args[extName], words...)
+	}
+
+	return args, nil
+}
+
+// splitShellWords splits s on whitespaces, like a POSIX shell does: single
+// quotes preserve every character they enclose, double quotes preserve every
+// character except backslashes escaping ", \, $ and `, and backslashes outside
+// of quotes escape the next character. Variables and globs aren't expanded.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case ' ', '\t', '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				word.WriteRune(runes[i])
+			}
+		case '\'', '"':
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == r {
+					closed = true
+					break
+				}
+				if r == '"' && runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, xerrors.Errorf("unterminated quote %c", r)
+			}
+		default:
+			word.WriteRune(r)
+		}
+		inWord = true
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// parseConfigureOptionFlags parses --configure-option flags in the format
+// <extension>:<option>=<value> and returns the configure option answers for
+// each extension.
+func parseConfigureOptionFlags(flags []string, specs []pecl.ExtensionSpec) (map[string]map[string]string, error) {
+	opts := map[string]map[string]string{}
+	for _, flag := range flags {
+		segments := strings.SplitN(flag, ":", 2)
+		if len(segments) != 2 || segments[0] == "" {
+			return nil, xerrors.Errorf("invalid --configure-option flag %q: expected format <extension>:<option>=<value>", flag)
+		}
+
+		extName := segments[0]
+		if !hasExtensionSpec(specs, extName) {
+			return nil, xerrors.Errorf("invalid --configure-option flag %q: extension %s is not being installed", flag, extName)
+		}
+
+		optSegments := strings.SplitN(segments[1], "=", 2)
+		if len(optSegments) != 2 || optSegments[0] == "" {
+			return nil, xerrors.Errorf("invalid --configure-option flag %q: expected format <extension>:<option>=<value>", flag)
+		}
+
+		if _, ok := opts[extName]; !ok {
+			opts[extName] = map[string]string{}
+		}
+		opts[extName][strings.TrimLeft(optSegments[0], "-")] = optSegments[1]
+	}
+
+	return opts, nil
+}
+
+func hasExtensionSpec(specs []pecl.ExtensionSpec, name string) bool {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NiR-/notpecl/installed"
+	"github.com/spf13/cobra"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+var listFlags = struct {
+	format string
+}{}
+
+func NewListCmd() *cobra.Command {
+	list := &cobra.Command{
+		Use:               "list",
+		DisableAutoGenTag: true,
+		Short:             "list the extensions installed by notpecl",
+		Args:              cobra.NoArgs,
+		Run:               run(runListCmd),
+	}
+
+	list.Flags().StringVar(&listFlags.format,
+		"format",
+		"table",
+		"Output format (available: table, json).")
+
+	return list
+}
+
+func runListCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	r, err := installed.LoadFromFile(vfs.HostOSFS, installedFilePath())
+	if err != nil {
+		return err
+	}
+
+	switch listFlags.format {
+	case "json":
+		raw, err := json.MarshalIndent(r.List(), "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(raw))
+		return nil
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tPHP\tFROM\tMODULE\tINSTALLED AT")
+		for _, ext := range r.List() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				ext.Name,
+				valueOrDash(ext.Version),
+				ext.PHPVersion,
+				installedFrom(ext),
+				ext.ModulePath,
+				ext.InstalledAt.Local().Format(time.RFC3339))
+		}
+		return w.Flush()
+	default:
+		return xerrors.Errorf("unsupported format %q (available: table, json)", listFlags.format)
+	}
+}
+
+// installedFrom returns the channel or the source the extension was installed
+// from, along with the commit checked out for git sources.
+func installedFrom(ext installed.Extension) string {
+	switch {
+	case ext.Commit != "":
+		return fmt.Sprintf("%s (%.12s)", ext.Source, ext.Commit)
+	case ext.Source != "":
+		return ext.Source
+	default:
+		return valueOrDash(ext.Channel)
+	}
+}
+
+func installedFilePath() string {
+	return filepath.Join(rootFlags.stateDir, installed.Filename)
+}
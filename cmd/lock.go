@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/NiR-/notpecl/lockfile"
+	"github.com/NiR-/notpecl/manifest"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var lockFlags = struct {
+	file             string
+	output           string
+	minimumStability string
+}{}
+
+func NewLockCmd() *cobra.Command {
+	lock := &cobra.Command{
+		Use:               "lock -f <manifest> [-o <lock-file>]",
+		DisableAutoGenTag: true,
+		Short:             "resolve the extensions of a manifest and record the exact releases in a lock file",
+		Run:               run(runLockCmd),
+	}
+
+	lock.Flags().StringVarP(&lockFlags.file,
+		"file",
+		"f",
+		"",
+		"Path to the manifest file (either JSON or YAML) listing the extensions to lock.")
+	lock.Flags().StringVarP(&lockFlags.output,
+		"output",
+		"o",
+		"",
+		"Path where the lock file should be written (defaults to the manifest path with a .lock extension).")
+	lock.Flags().StringVar(&lockFlags.minimumStability,
+		"minimum-stability",
+		peclapi.Stable.String(),
+		"Minimum stability level used for extensions that don't specify one, when the manifest doesn't either (default: stable, available: stable > beta > alpha > devel > snapshot)")
+
+	return lock
+}
+
+func runLockCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	if lockFlags.file == "" {
+		return xerrors.Errorf("you have to provide the manifest to lock with --file")
+	}
+
+	m, err := manifest.LoadFromFile(lockFlags.file)
+	if err != nil {
+		return err
+	}
+	if m.MinimumStability == "" {
+		m.MinimumStability = lockFlags.minimumStability
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	channels, err := loadChannelClients()
+	if err != nil {
+		return err
+	}
+
+	l := lockfile.LockFile{
+		Extensions: make([]lockfile.LockedExtension, 0, len(m.Extensions)),
+	}
+	for _, ext := range m.Extensions {
+		if ext.Source != "" {
+			logrus.Warnf("%s is installed from %s, it's not locked.", ext.Name, ext.Source)
+			continue
+		}
+
+		spec, err := m.Spec(ext)
+		if err != nil {
+			return err
+		}
+		client, err := channels.Client(spec.Channel)
+		if err != nil {
+			return err
+		}
+		p := initChannelBackend(client)
+
+		extVersion, err := p.ResolveConstraint(ctx, spec.Name, spec.Constraint, spec.MinimumStability)
+		if err != nil {
+			return err
+		}
+
+		locked, err := lockfile.Lock(ctx, client, spec.Name, extVersion)
+		if err != nil {
+			return err
+		}
+
+		locked.Channel = spec.Channel
+
+		logrus.Infof("Locked %s v%s (sha256: %s)", locked.Name, locked.Version, locked.Sha256)
+		l.Extensions = append(l.Extensions, locked)
+	}
+
+	output := lockFlags.output
+	if output == "" {
+		output = lockfile.PathFor(lockFlags.file)
+	}
+
+	return l.WriteToFile(output)
+}
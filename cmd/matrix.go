@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// matrixBuild is the outcome of building an extension for one of the PHP
+// installations of a build matrix.
+type matrixBuild struct {
+	extension  string
+	phpConfig  string
+	phpVersion string
+	buildDir   string
+	err        error
+}
+
+// buildMatrix builds an extension once for each of the given php-config,
+// through the build func. It's called with a backend targeting that PHP
+// installation, and with a build dir in buildRoot named after its PHP
+// version (eg. <buildRoot>/php-8.1.2). A failed build doesn't prevent the
+// other ones from running.
+func buildMatrix(
+	ctx context.Context,
+	extension string,
+	phpConfigs []string,
+	buildRoot string,
+	newBackend func(opts ...pecl.BackendOpt) (pecl.Backend, error),
+	build func(p pecl.Backend, buildDir string) error,
+) []matrixBuild {
+	results := make([]matrixBuild, 0, len(phpConfigs))
+	usedDirs := map[string]struct{}{}
+
+	for _, phpConfig := range phpConfigs {
+		result := matrixBuild{
+			extension: extension,
+			phpConfig: phpConfig,
+		}
+		results = append(results, result)
+		res := &results[len(results)-1]
+
+		if res.err = ctx.Err(); res.err != nil {
+			continue
+		}
+
+		p, err := newBackend(pecl.WithPhpConfigPath(phpConfig))
+		if err != nil {
+			res.err = err
+			continue
+		}
+		if res.phpVersion, res.err = p.PHPVersion(ctx); res.err != nil {
+			continue
+		}
+
+		// Two php-config might belong to the same PHP version (eg. a ZTS and
+		// a NTS build), they still need their own build dir.
+		dirname := "php-" + res.phpVersion
+		for i := 2; ; i++ {
+			if _, ok := usedDirs[dirname]; !ok {
+				break
+			}
+			dirname = fmt.Sprintf("php-%s-%d", res.phpVersion, i)
+		}
+		usedDirs[dirname] = struct{}{}
+		res.buildDir = filepath.Join(buildRoot, dirname)
+
+		logrus.Infof("Building %s for PHP %s in %s...", extension, res.phpVersion, res.buildDir)
+		if res.err = build(p, res.buildDir); res.err != nil {
+			logrus.Errorf("Failed to build %s for PHP %s: %v", extension, res.phpVersion, res.err)
+		}
+	}
+
+	return results
+}
+
+// printMatrixSummary prints a table listing the builds of a matrix and
+// whether they succeeded, and returns an error when some of them failed.
+func printMatrixSummary(results []matrixBuild) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EXTENSION\tPHP\tPHP-CONFIG\tBUILD DIR\tRESULT")
+
+	failed := 0
+	for _, res := range results {
+		status := "ok"
+		if res.err != nil {
+			status = "failed: " + res.err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			res.extension,
+			valueOrDash(res.phpVersion),
+			res.phpConfig,
+			valueOrDash(res.buildDir),
+			status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return xerrors.Errorf("%d of %d builds failed", failed, len(results))
+	}
+	return nil
+}
+
+// checkMatrixFlags returns an error when flags that only make sense for a
+// single PHP installation are used with a build matrix.
+func checkMatrixFlags() error {
+	if phpFlags.php != "" {
+		return xerrors.Errorf("--php can't be used when building for several php-config: the php binary of each one is used")
+	}
+	return nil
+}
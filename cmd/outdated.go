@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/NiR-/notpecl/installed"
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/mcuadros/go-version"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/twpayne/go-vfs"
+)
+
+var outdatedFlags = struct {
+	all bool
+}{}
+
+func NewOutdatedCmd() *cobra.Command {
+	outdated := &cobra.Command{
+		Use:               "outdated",
+		DisableAutoGenTag: true,
+		Short:             "list the installed extensions with newer releases available",
+		Args:              cobra.NoArgs,
+		Run:               run(runOutdatedCmd),
+	}
+
+	outdated.Flags().BoolVar(&outdatedFlags.all,
+		"all",
+		false,
+		"Also list the extensions that are up-to-date.")
+
+	return outdated
+}
+
+func runOutdatedCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	r, err := installed.LoadFromFile(vfs.HostOSFS, installedFilePath())
+	if err != nil {
+		return err
+	}
+	channels, err := loadChannelClients()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPHP\tCONSTRAINT\tCURRENT\tLATEST MATCHING\tLATEST")
+
+	for _, ext := range r.List() {
+		if ext.Channel == "" {
+			logrus.Debugf("Skipping %s: installed from %s.", ext.Name, ext.Source)
+			continue
+		}
+
+		latest, err := findLatestReleases(ctx, channels, ext)
+		if err != nil {
+			return err
+		}
+		if !outdatedFlags.all && !isNewerRelease(latest.Matching, ext.Version) && !isNewerRelease(latest.Latest, ext.Version) {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			ext.Name,
+			ext.PHPVersion,
+			valueOrDash(ext.Constraint),
+			valueOrDash(ext.Version),
+			valueOrDash(latest.Matching),
+			valueOrDash(latest.Latest))
+	}
+
+	return w.Flush()
+}
+
+// findLatestReleases returns the latest releases of an installed extension
+// from the channel it was downloaded from, using the constraint and the
+// minimum stability it was installed with. Extensions recorded without a
+// constraint accept any stable release.
+func findLatestReleases(ctx context.Context, channels *channelClients, ext installed.Extension) (pecl.LatestReleases, error) {
+	p, err := channels.Backend(ext.Channel)
+	if err != nil {
+		return pecl.LatestReleases{}, err
+	}
+
+	constraint := ext.Constraint
+	if constraint == "" {
+		constraint = "*"
+	}
+	stability := peclapi.StabilityFromString(ext.MinimumStability)
+	if stability == peclapi.Unknown {
+		stability = peclapi.Stable
+	}
+
+	return p.FindLatestReleases(ctx, ext.Name, constraint, stability)
+}
+
+// isNewerRelease returns true when release is newer than the current one.
+func isNewerRelease(release, current string) bool {
+	if release == "" {
+		return false
+	}
+	return current == "" || version.Compare(release, current, ">")
+}
@@ -1,19 +1,43 @@
 package cmd
 
 import (
+	"context"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"syscall"
+	"time"
 
 	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclcache"
+	"github.com/NiR-/notpecl/peclchannel"
 	"github.com/NiR-/notpecl/ui"
 	"github.com/mattn/go-isatty"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
 )
 
 var rootFlags = struct {
-	verbose bool
+	verbose   bool
+	cacheDir  string
+	cacheTTL  time.Duration
+	noCache   bool
+	offline   bool
+	vendorDir string
+	timeout   time.Duration
+
+	retries       int
+	retryDelay    time.Duration
+	retryMaxDelay time.Duration
+
+	configDir string
+	stateDir  string
 }{
 	verbose: false,
 }
@@ -30,26 +54,198 @@ func NewRootCmd() *cobra.Command {
 		},
 	}
 	root.PersistentFlags().BoolVarP(&rootFlags.verbose, "verbose", "v", true, "Use this flag to enable debug log messages.")
+	root.PersistentFlags().DurationVar(&rootFlags.timeout,
+		"timeout",
+		0,
+		"Maximum duration of the command (eg. 10m). Running processes are killed and partially extracted archives are removed when it's elapsed (defaults to no timeout).")
+	root.PersistentFlags().StringVar(&rootFlags.cacheDir,
+		"cache-dir",
+		defaultCacheDir(),
+		"Directory where REST metadata and release archives are cached.")
+	root.PersistentFlags().DurationVar(&rootFlags.cacheTTL,
+		"cache-ttl",
+		peclcache.DefaultMetadataTTL,
+		"Duration after which cached REST metadata are refreshed (release archives never expire).")
+	root.PersistentFlags().BoolVar(&rootFlags.noCache,
+		"no-cache",
+		false,
+		"Disable the cache.")
+	root.PersistentFlags().BoolVar(&rootFlags.offline,
+		"offline",
+		false,
+		"Never reach the network: REST metadata and release archives are only served from the cache and from the vendor directory.")
+	root.PersistentFlags().StringVar(&rootFlags.vendorDir,
+		"vendor-dir",
+		"",
+		"Directory containing release archives and REST metadata used in offline mode. It mirrors the URLs served (eg. <vendor-dir>/pecl.php.net/rest/r/redis/allreleases.xml), archives can also be put at its root.")
+	root.PersistentFlags().StringVar(&rootFlags.configDir,
+		"config-dir",
+		defaultConfigDir(),
+		"Directory where notpecl config files (eg. the list of channels) are stored.")
+	root.PersistentFlags().StringVar(&rootFlags.stateDir,
+		"state-dir",
+		defaultStateDir(),
+		"Directory where notpecl keeps track of the extensions it installed.")
+	root.PersistentFlags().IntVar(&rootFlags.retries,
+		"retries",
+		peclapi.DefaultRetryPolicy.MaxAttempts-1,
+		"Number of times failed requests to pecl.php.net (network errors, 429 and 5xx responses) are retried.")
+	root.PersistentFlags().DurationVar(&rootFlags.retryDelay,
+		"retry-delay",
+		peclapi.DefaultRetryPolicy.InitialDelay,
+		"Delay before the first retry. It's doubled after each retry, with some jitter, unless the server sends a Retry-After header.")
+	root.PersistentFlags().DurationVar(&rootFlags.retryMaxDelay,
+		"retry-max-delay",
+		peclapi.DefaultRetryPolicy.MaxDelay,
+		"Maximum delay between two retries.")
 
 	root.AddCommand(NewBuildCmd())
+	root.AddCommand(NewCacheCmd())
+	root.AddCommand(NewChannelCmd())
 	root.AddCommand(NewDownloadCmd())
+	root.AddCommand(NewEnableCmd())
+	root.AddCommand(NewInfoCmd())
 	root.AddCommand(NewInstallCmd())
+	root.AddCommand(NewListCmd())
+	root.AddCommand(NewLockCmd())
 	root.AddCommand(NewGendocCmd(root))
+	root.AddCommand(NewOutdatedCmd())
+	root.AddCommand(NewSearchCmd())
+	root.AddCommand(NewUninstallCmd())
+	root.AddCommand(NewUpgradeCmd())
 	root.AddCommand(NewVersionCmd())
 
 	return root
 }
 
+// initPeclClient returns a client for the default pecl channel.
+func initPeclClient() peclapi.Client {
+	return initChannelClient(peclchannel.Default)
+}
+
+func initChannelClient(ch peclchannel.Channel) peclapi.Client {
+	var transport http.RoundTripper
+
+	switch {
+	case rootFlags.offline:
+		var vendor http.RoundTripper
+		if rootFlags.vendorDir != "" {
+			vendor = peclcache.VendorTransport(vfs.HostOSFS, rootFlags.vendorDir)
+		}
+		if rootFlags.noCache {
+			if vendor == nil {
+				logrus.Fatal("--offline requires either the cache or a --vendor-dir")
+			}
+			transport = vendor
+		} else {
+			transport = initPeclCache().OfflineTransport(vendor)
+		}
+	case rootFlags.noCache:
+		transport = http.DefaultTransport
+	default:
+		transport = initPeclCache().Transport(http.DefaultTransport)
+	}
+
+	httpClient := &http.Client{Transport: transport}
+	opts := []peclapi.ClientOpt{
+		peclapi.WithBaseURI(ch.BaseURL),
+		peclapi.WithHttpClient(httpClient),
+	}
+	// There's no point in retrying requests when offline: they'd fail the
+	// same way.
+	if !rootFlags.offline {
+		opts = append(opts, peclapi.WithRetryPolicy(peclapi.RetryPolicy{
+			MaxAttempts:  rootFlags.retries + 1,
+			InitialDelay: rootFlags.retryDelay,
+			MaxDelay:     rootFlags.retryMaxDelay,
+		}))
+	}
+
+	return peclapi.NewClient(opts...)
+}
+
+func initPeclCache() peclcache.Cache {
+	return peclcache.New(rootFlags.cacheDir,
+		peclcache.WithMetadataTTL(rootFlags.cacheTTL))
+}
+
+func defaultConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "notpecl")
+}
+
+// defaultStateDir returns the state dir of notpecl, following the XDG Base
+// Directory spec as os.UserConfigDir and os.UserCacheDir do.
+func defaultStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "notpecl")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "notpecl", "state")
+	}
+	return filepath.Join(home, ".local", "state", "notpecl")
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "notpecl")
+}
+
+// initPeclBackend returns a backend for the default pecl channel.
 func initPeclBackend() pecl.Backend {
-	opts := make([]pecl.BackendOpt, 0, 1)
+	return initChannelBackend(initPeclClient())
+}
+
+// initChannelBackend returns a backend using the given client. The extra
+// opts are applied last, such that they take precedence over flags.
+func initChannelBackend(client peclapi.Client, extraOpts ...pecl.BackendOpt) pecl.Backend {
+	opts := make([]pecl.BackendOpt, 0, 6+len(extraOpts))
+	opts = append(opts,
+		pecl.WithClient(client),
+		pecl.WithStateDir(rootFlags.stateDir))
+	if rootFlags.offline {
+		opts = append(opts, pecl.WithOffline())
+	}
+	if len(phpFlags.phpConfig) == 1 {
+		opts = append(opts, pecl.WithPhpConfigPath(phpFlags.phpConfig[0]))
+	}
+	if phpFlags.php != "" {
+		opts = append(opts, pecl.WithPhpBinary(phpFlags.php))
+	}
 	if isatty.IsTerminal(os.Stdout.Fd()) {
 		interactiveUI := ui.NewInteractiveUI(os.Stdin, os.Stdout)
 		opts = append(opts, pecl.WithUI(interactiveUI))
 	}
+	opts = append(opts, extraOpts...)
 
 	return pecl.New(opts...)
 }
 
+// phpFlags are the flags selecting the PHP installation extensions are built
+// for, shared by the build, install, enable and uninstall commands.
+var phpFlags = struct {
+	phpConfig []string
+	php       string
+}{}
+
+func registerPhpFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&phpFlags.phpConfig,
+		"php-config",
+		[]string{},
+		"Path to the php-config of the PHP installation extensions are built for. phpize is looked up in its --prefix (defaults to the php-config living next to --php, or found in PATH). Can be repeated to build for several PHP installations, each in its own build dir.")
+	flags.StringVar(&phpFlags.php,
+		"php",
+		"",
+		"Path to the php binary used to check extension dependencies (defaults to php-config --php-binary). It should report the same version as php-config.")
+}
+
 func resolveTmpDownloadDir() (string, error) {
 	dir := filepath.Join(os.TempDir(), "notpecl")
 	_, err := os.Stat(dir)
@@ -59,6 +255,45 @@ func resolveTmpDownloadDir() (string, error) {
 	return dir, err
 }
 
+// parseExtensionSpecs parses the extension specs passed as command args. The
+// minimumStability flag is used as the default stability for specs without
+// any @<stability> suffix.
+func parseExtensionSpecs(args []string, minimumStability string) ([]pecl.ExtensionSpec, error) {
+	stability := peclapi.StabilityFromString(minimumStability)
+	if stability == peclapi.Unknown {
+		return nil, xerrors.Errorf("unsupported minimum stability %q", minimumStability)
+	}
+
+	specs := make([]pecl.ExtensionSpec, 0, len(args))
+	for _, arg := range args {
+		spec, err := pecl.ParseExtensionSpec(arg, stability)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// resolveJobs returns the number of parallel jobs make should run: either
+// the value of the --jobs flag, when set, or the number of CPUs available.
+func resolveJobs(jobs int) int {
+	if jobs > 0 {
+		return jobs
+	}
+	return findMaxParallelism()
+}
+
+// valueOrDash returns the given value, or a dash when it's empty, for values
+// printed in tables.
+func valueOrDash(val string) string {
+	if val == "" {
+		return "-"
+	}
+	return val
+}
+
 func findMaxParallelism() int {
 	maxProcs := runtime.GOMAXPROCS(0)
 	numCPU := runtime.NumCPU()
@@ -68,10 +303,43 @@ func findMaxParallelism() int {
 	return numCPU
 }
 
-func run(fn func(*cobra.Command, []string) error) func(*cobra.Command, []string) {
+func run(fn func(context.Context, *cobra.Command, []string) error) func(*cobra.Command, []string) {
 	return func(cmd *cobra.Command, args []string) {
-		if err := fn(cmd, args); err != nil {
+		ctx, cancel := newCmdContext()
+		defer cancel()
+
+		if err := fn(ctx, cmd, args); err != nil {
 			logrus.Fatal(err)
 		}
 	}
 }
+
+// newCmdContext returns a context canceled when SIGINT or SIGTERM is received,
+// or when the --timeout is elapsed. Commands should stop what they're doing
+// and clean up when it's done.
+func newCmdContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if rootFlags.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, rootFlags.timeout)
+		cancelParent := cancel
+		cancel = func() {
+			cancelTimeout()
+			cancelParent()
+		}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigs)
+		select {
+		case sig := <-sigs:
+			logrus.Warnf("Received %s, cleaning up...", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
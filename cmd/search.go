@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/NiR-/notpecl/peclsearch"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var searchFlags = struct {
+	channel  string
+	category string
+	format   string
+}{}
+
+func NewSearchCmd() *cobra.Command {
+	search := &cobra.Command{
+		Use:               "search <term>",
+		DisableAutoGenTag: true,
+		Short:             "search for extensions whose name, summary or description match the given term",
+		Args:              cobra.ExactArgs(1),
+		Run:               run(runSearchCmd),
+	}
+
+	search.Flags().StringVar(&searchFlags.channel,
+		"channel",
+		"",
+		"Name or alias of the channel to search in (defaults to pecl.php.net).")
+	search.Flags().StringVar(&searchFlags.category,
+		"category",
+		"",
+		"Only search for extensions in the given category (eg. Database).")
+	search.Flags().StringVar(&searchFlags.format,
+		"format",
+		"table",
+		"Output format (available: table, json).")
+
+	return search
+}
+
+// searchResult is a search match, as printed by the search command.
+type searchResult struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Summary  string `json:"summary"`
+	Match    string `json:"match"`
+}
+
+func runSearchCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	if searchFlags.format != "table" && searchFlags.format != "json" {
+		return xerrors.Errorf("unsupported format %q (available: table, json)", searchFlags.format)
+	}
+
+	channels, err := loadChannelClients()
+	if err != nil {
+		return err
+	}
+	client, err := channels.Client(searchFlags.channel)
+	if err != nil {
+		return err
+	}
+
+	matches, err := peclsearch.Search(ctx, client, args[0], peclsearch.Opts{
+		Category: searchFlags.category,
+	})
+	if err != nil {
+		return err
+	}
+
+	results := make([]searchResult, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, searchResult{
+			Name:     match.Package.Name,
+			Category: match.Package.Category,
+			Summary:  match.Package.Summary,
+			Match:    match.Rank.String(),
+		})
+	}
+
+	if searchFlags.format == "json" {
+		raw, err := json.MarshalIndent(results, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(raw))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCATEGORY\tSUMMARY")
+	for _, res := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			res.Name,
+			valueOrDash(res.Category),
+			valueOrDash(res.Summary))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var uninstallFlags = struct {
+	installDir string
+	iniDir     string
+	dryRun     bool
+}{}
+
+func NewUninstallCmd() *cobra.Command {
+	uninstall := &cobra.Command{
+		Use:               "uninstall <extension>",
+		DisableAutoGenTag: true,
+		Short:             "remove an installed extension, its headers and the ini files enabling it",
+		Args:              cobra.ExactArgs(1),
+		Run:               run(runUninstallCmd),
+	}
+
+	uninstall.Flags().StringVar(&uninstallFlags.installDir,
+		"install-dir",
+		"",
+		"Directory where the extension was installed (see install --install-dir).")
+	uninstall.Flags().StringVar(&uninstallFlags.iniDir,
+		"ini-dir",
+		"",
+		"Directory where the ini file enabling the extension was written (defaults to the dir scanned by PHP for additional ini files).")
+	uninstall.Flags().BoolVar(&uninstallFlags.dryRun,
+		"dry-run",
+		false,
+		"List the files that would be removed without removing them.")
+	registerPhpFlags(uninstall.Flags())
+
+	return uninstall
+}
+
+func runUninstallCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	if len(phpFlags.phpConfig) > 1 {
+		return xerrors.Errorf("--php-config can only be used once with uninstall")
+	}
+
+	p := initPeclBackend()
+	removed, err := p.Uninstall(ctx, pecl.UninstallOpts{
+		Extension:  args[0],
+		InstallDir: uninstallFlags.installDir,
+		IniDir:     uninstallFlags.iniDir,
+		DryRun:     uninstallFlags.dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	if uninstallFlags.dryRun {
+		for _, path := range removed {
+			fmt.Printf("Would remove %s\n", path)
+		}
+	}
+	return nil
+}
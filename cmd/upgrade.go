@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/NiR-/notpecl/installed"
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+var upgradeFlags = struct {
+	downloadDir string
+	jobs        int
+}{}
+
+func NewUpgradeCmd() *cobra.Command {
+	upgrade := &cobra.Command{
+		Use:               "upgrade [extension...]",
+		DisableAutoGenTag: true,
+		Short:             "rebuild installed extensions with the newest release allowed by their constraint",
+		Run:               run(runUpgradeCmd),
+	}
+
+	upgrade.Flags().StringVar(&upgradeFlags.downloadDir,
+		"download-dir",
+		"",
+		"Directory where the extensions should be downloaded and compiled (defaults to a temporary directory).")
+	upgrade.Flags().IntVarP(&upgradeFlags.jobs,
+		"jobs",
+		"j",
+		0,
+		"Number of parallel jobs run by make (defaults to the number of CPUs, ignored when MAKEFLAGS sets it or when run by a parent make sharing its jobserver).")
+
+	return upgrade
+}
+
+func runUpgradeCmd(ctx context.Context, cmd *cobra.Command, args []string) error {
+	r, err := installed.LoadFromFile(vfs.HostOSFS, installedFilePath())
+	if err != nil {
+		return err
+	}
+	channels, err := loadChannelClients()
+	if err != nil {
+		return err
+	}
+
+	toUpgrade := r.List()
+	if len(args) > 0 {
+		toUpgrade = nil
+		for _, name := range args {
+			found := r.Lookup(name)
+			if len(found) == 0 {
+				return xerrors.Errorf("%s is not installed", name)
+			}
+			toUpgrade = append(toUpgrade, found...)
+		}
+	}
+
+	downloadDir := upgradeFlags.downloadDir
+	if downloadDir == "" {
+		if downloadDir, err = resolveTmpDownloadDir(); err != nil {
+			return xerrors.Errorf("failed to find where downloaded files should be written: %w", err)
+		}
+	}
+
+	for _, ext := range toUpgrade {
+		if ext.Channel == "" {
+			logrus.Infof("Skipping %s: installed from %s, it can't be upgraded.", ext.Name, ext.Source)
+			continue
+		}
+
+		latest, err := findLatestReleases(ctx, channels, ext)
+		if err != nil {
+			return err
+		}
+		if !isNewerRelease(latest.Matching, ext.Version) {
+			logrus.Infof("%s %s is up-to-date for PHP %s.", ext.Name, ext.Version, ext.PHPVersion)
+			continue
+		}
+
+		if err := upgradeExtension(ctx, channels, ext, latest.Matching, downloadDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upgradeExtension installs the given release of an installed extension, for
+// the same PHP installation, in the same install dir and with the same
+// configure args.
+func upgradeExtension(ctx context.Context, channels *channelClients, ext installed.Extension, release, downloadDir string) error {
+	var backendOpts []pecl.BackendOpt
+	if ext.PhpConfig != "" {
+		backendOpts = append(backendOpts, pecl.WithPhpConfigPath(ext.PhpConfig))
+	}
+	p, err := channels.Backend(ext.Channel, backendOpts...)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Upgrading %s from %s to %s for PHP %s...", ext.Name, ext.Version, release, ext.PHPVersion)
+
+	return p.Install(ctx, pecl.InstallOpts{
+		DownloadOpts: pecl.DownloadOpts{
+			Extension:   ext.Name,
+			Version:     release,
+			DownloadDir: downloadDir,
+		},
+		Channel:          ext.Channel,
+		Constraint:       ext.Constraint,
+		MinimumStability: peclapi.StabilityFromString(ext.MinimumStability),
+		InstallDir:       ext.InstallDir,
+		ConfigureArgs:    ext.ConfigureArgs,
+		Parallel:         resolveJobs(upgradeFlags.jobs),
+		Cleanup:          true,
+	})
+}
@@ -1,6 +1,7 @@
 package cmdexec
 
 import (
+	"context"
 	"io"
 	"os/exec"
 	"strings"
@@ -32,15 +33,43 @@ func (executor CmdExecutor) With(opts ...ExecOpt) CmdExecutor {
 }
 
 // Run creates a new exec.Cmd and applies the ExecOpt of the executor and then
-// run the Cmd.
-func (executor CmdExecutor) Run(name string, args ...string) error {
+// run the Cmd. The process is killed if ctx is done before it exits, in which
+// case the error of the context is returned. On unix, the commands spawned by
+// the process (eg. by make or configure) are killed too, unless the process
+// runs in the foreground of a terminal (see setProcessGroup).
+func (executor CmdExecutor) Run(ctx context.Context, name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	for _, opt := range executor.opts {
 		opt(cmd)
 	}
+	setProcessGroup(cmd)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	logrus.Debugf("Running %s...", strings.Join(cmd.Args, " "))
-	return cmd.Run()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// exec.CommandContext() can't be used here as ExecOpt might replace the
+	// whole exec.Cmd (see NewTestExecutor()).
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcess(cmd)
+		case <-done:
+		}
+	}()
+
+	err := cmd.Wait()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
 }
 
 // BaseDir returns an ExecOpt that sets the Dir field of the exec.Cmd.
@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package cmdexec
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/mattn/go-isatty"
+)
+
+// setProcessGroup starts the process in its own process group, such that
+// killProcess kills the commands it spawns too. This is only done when stdin
+// isn't a terminal: processes outside of the foreground process group of the
+// terminal are stopped as soon as they read from it (eg. git asking for
+// credentials or configure scripts asking questions). In the foreground, the
+// interrupt signal sent by the terminal already reaches the whole group.
+func setProcessGroup(cmd *exec.Cmd) {
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// killProcess kills the process group of the process when it has its own,
+// or only the process otherwise.
+func killProcess(cmd *exec.Cmd) {
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		// Signaling -pid signals the whole process group.
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return
+	}
+	cmd.Process.Kill()
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package cmdexec
+
+import "os/exec"
+
+// setProcessGroup does nothing on windows, where process groups aren't used.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcess kills the process.
+func killProcess(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
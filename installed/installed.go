@@ -0,0 +1,165 @@
+// Package installed implements the registry of the extensions installed by
+// notpecl. It records which release of each extension got installed, for
+// which PHP installation and how it was configured. The registry is stored
+// as a JSON file in the state dir of notpecl.
+package installed
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+// Filename is the name of the registry file in the state dir.
+const Filename = "installed.json"
+
+// Extension is an extension installed for a given PHP installation.
+type Extension struct {
+	// Name is the name of the extension.
+	Name string `json:"name"`
+	// Version is the version of the release installed. It might be empty
+	// for extensions built from a source dir without package.xml.
+	Version string `json:"version,omitempty"`
+	// Channel is the name of the channel the release was downloaded from.
+	// It's empty when the extension was installed from a local source or
+	// a git repository.
+	Channel string `json:"channel,omitempty"`
+	// Constraint is the version constraint the release installed was
+	// resolved from. It's empty when the extension wasn't installed from a
+	// channel.
+	Constraint string `json:"constraint,omitempty"`
+	// MinimumStability is the minimum stability accepted when the constraint
+	// was resolved.
+	MinimumStability string `json:"minimum_stability,omitempty"`
+	// Source is the local archive, source dir or git source the extension
+	// was installed from, if any.
+	Source string `json:"source,omitempty"`
+	// Commit is the commit checked out when installing from a git source.
+	Commit string `json:"commit,omitempty"`
+	// ConfigureArgs is the list of flags passed to ./configure.
+	ConfigureArgs []string `json:"configure_args,omitempty"`
+	// PHPVersion is the version of the PHP installation the extension was
+	// built for.
+	PHPVersion string `json:"php_version"`
+	// PHPAPI is the API version of the PHP installation the extension was
+	// built for (eg. 20190902), as reported by php-config --phpapi.
+	PHPAPI string `json:"php_api"`
+	// PhpConfig is the path to the php-config script of the PHP installation
+	// the extension was built for.
+	PhpConfig string `json:"php_config,omitempty"`
+	// InstallDir is the directory the extension was installed to (see
+	// pecl.InstallOpts).
+	InstallDir string `json:"install_dir,omitempty"`
+	// ModulePath is the path to the .so file installed.
+	ModulePath string `json:"module_path"`
+	// Sha256 is the sha256 digest (hex-encoded) of the .so file installed.
+	Sha256 string `json:"sha256"`
+	// InstalledAt is the time at which the extension was installed.
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// Registry is the list of the extensions installed.
+type Registry struct {
+	Extensions []Extension `json:"extensions"`
+}
+
+// LoadFromFile loads the registry at the given path. An empty registry is
+// returned when the file doesn't exist yet.
+func LoadFromFile(fs vfs.FS, path string) (Registry, error) {
+	r := Registry{Extensions: []Extension{}}
+
+	raw, err := fs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return r, xerrors.Errorf("could not load installed extensions from %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return r, xerrors.Errorf("could not load installed extensions from %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// WriteToFile writes the registry to the given path. The file is first
+// written next to it and then renamed, such that concurrent readers never
+// see a partially written registry.
+func (r Registry) WriteToFile(fs vfs.FS, path string) error {
+	raw, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return xerrors.Errorf("could not write installed extensions to %s: %w", path, err)
+	}
+
+	if err := vfs.MkdirAll(fs, filepath.Dir(path), 0750); err != nil {
+		return xerrors.Errorf("could not write installed extensions to %s: %w", path, err)
+	}
+	tmpPath := path + ".tmp"
+	if err := fs.WriteFile(tmpPath, append(raw, '\n'), 0644); err != nil {
+		return xerrors.Errorf("could not write installed extensions to %s: %w", path, err)
+	}
+	if err := fs.Rename(tmpPath, path); err != nil {
+		return xerrors.Errorf("could not write installed extensions to %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Record adds the given extension to the registry. It replaces any extension
+// previously installed at the same ModulePath.
+func (r *Registry) Record(ext Extension) {
+	extensions := make([]Extension, 0, len(r.Extensions)+1)
+	for _, existing := range r.Extensions {
+		if existing.ModulePath != ext.ModulePath {
+			extensions = append(extensions, existing)
+		}
+	}
+	r.Extensions = append(extensions, ext)
+}
+
+// Remove removes the extension installed at the given ModulePath from the
+// registry. It returns false when no extension was installed there.
+func (r *Registry) Remove(modulePath string) bool {
+	extensions := make([]Extension, 0, len(r.Extensions))
+	for _, existing := range r.Extensions {
+		if existing.ModulePath != modulePath {
+			extensions = append(extensions, existing)
+		}
+	}
+	removed := len(extensions) != len(r.Extensions)
+	r.Extensions = extensions
+	return removed
+}
+
+// List returns the extensions installed, sorted by name and then by PHP
+// version.
+func (r Registry) List() []Extension {
+	extensions := make([]Extension, len(r.Extensions))
+	copy(extensions, r.Extensions)
+
+	sort.SliceStable(extensions, func(i, j int) bool {
+		if extensions[i].Name != extensions[j].Name {
+			return extensions[i].Name < extensions[j].Name
+		}
+		return extensions[i].PHPVersion < extensions[j].PHPVersion
+	})
+
+	return extensions
+}
+
+// Lookup returns the installations of the given extension, for every PHP
+// installation it was installed for.
+func (r Registry) Lookup(name string) []Extension {
+	var found []Extension
+	for _, ext := range r.List() {
+		if ext.Name == name {
+			found = append(found, ext)
+		}
+	}
+	return found
+}
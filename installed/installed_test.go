@@ -0,0 +1,152 @@
+package installed_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NiR-/notpecl/installed"
+	"github.com/go-test/deep"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+var installedAt = time.Date(2020, 4, 12, 10, 30, 0, 0, time.UTC)
+
+func redis(phpVersion, modulePath string) installed.Extension {
+	return installed.Extension{
+		Name:          "redis",
+		Version:       "5.1.1",
+		Channel:       "pecl.php.net",
+		ConfigureArgs: []string{"--enable-redis-lzf"},
+		PHPVersion:    phpVersion,
+		PHPAPI:        "20190902",
+		ModulePath:    modulePath,
+		Sha256:        "0f1e2d3c",
+		InstalledAt:   installedAt,
+	}
+}
+
+type recordTC struct {
+	registry installed.Registry
+	ext      installed.Extension
+	expected []installed.Extension
+}
+
+func TestRecord(t *testing.T) {
+	redis74 := redis("7.4.3", "/usr/lib/php/20190902/redis.so")
+	redis80 := redis("8.0.1", "/usr/lib/php/20200930/redis.so")
+	apcu := installed.Extension{
+		Name:       "apcu",
+		Version:    "5.1.18",
+		PHPVersion: "7.4.3",
+		ModulePath: "/usr/lib/php/20190902/apcu.so",
+	}
+	upgraded := redis74
+	upgraded.Version = "5.2.0"
+
+	testcases := map[string]recordTC{
+		"record an extension in an empty registry": {
+			registry: installed.Registry{},
+			ext:      redis74,
+			expected: []installed.Extension{redis74},
+		},
+		"record an extension installed for another PHP installation": {
+			registry: installed.Registry{Extensions: []installed.Extension{redis80}},
+			ext:      redis74,
+			expected: []installed.Extension{redis74, redis80},
+		},
+		"record an extension installed again at the same path": {
+			registry: installed.Registry{Extensions: []installed.Extension{redis74, apcu}},
+			ext:      upgraded,
+			expected: []installed.Extension{apcu, upgraded},
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc.registry.Record(tc.ext)
+			if diff := deep.Equal(tc.registry.List(), tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+type removeTC struct {
+	registry        installed.Registry
+	modulePath      string
+	expected        []installed.Extension
+	expectedRemoved bool
+}
+
+func TestRemove(t *testing.T) {
+	redis74 := redis("7.4.3", "/usr/lib/php/20190902/redis.so")
+	redis80 := redis("8.0.1", "/usr/lib/php/20200930/redis.so")
+
+	testcases := map[string]removeTC{
+		"remove an extension installed for one of the PHP installations": {
+			registry:        installed.Registry{Extensions: []installed.Extension{redis74, redis80}},
+			modulePath:      "/usr/lib/php/20200930/redis.so",
+			expected:        []installed.Extension{redis74},
+			expectedRemoved: true,
+		},
+		"remove an extension that is not in the registry": {
+			registry:        installed.Registry{Extensions: []installed.Extension{redis74}},
+			modulePath:      "/usr/lib/php/20200930/redis.so",
+			expected:        []installed.Extension{redis74},
+			expectedRemoved: false,
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			removed := tc.registry.Remove(tc.modulePath)
+			if removed != tc.expectedRemoved {
+				t.Fatalf("Expected removed: %t - Got: %t", tc.expectedRemoved, removed)
+			}
+			if diff := deep.Equal(tc.registry.List(), tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestWriteAndLoadFromFile(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	path := "/var/lib/notpecl/" + installed.Filename
+	r, err := installed.LoadFromFile(fs, path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(r.Extensions) != 0 {
+		t.Fatalf("Expected an empty registry - Got: %v", r.Extensions)
+	}
+
+	r.Record(redis("7.4.3", "/usr/lib/php/20190902/redis.so"))
+	if err := r.WriteToFile(fs, path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	vfst.RunTests(t, fs, "registry",
+		vfst.TestPath(path, vfst.TestModeIsRegular),
+		vfst.TestPath(path+".tmp", vfst.TestDoesNotExist))
+
+	loaded, err := installed.LoadFromFile(fs, path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := deep.Equal(loaded, r); diff != nil {
+		t.Fatal(diff)
+	}
+}
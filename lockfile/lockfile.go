@@ -0,0 +1,129 @@
+// Package lockfile implements lock files. A lock file records the exact
+// release resolved for each extension of a manifest, along with the digest of
+// its archive, such that subsequent installs get exactly the same releases.
+package lockfile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/mcuadros/go-version"
+	"golang.org/x/xerrors"
+)
+
+// PathFor returns the path of the lock file associated to the given manifest.
+// The lock file is stored next to the manifest, with a .lock extension.
+func PathFor(manifestPath string) string {
+	ext := filepath.Ext(manifestPath)
+	return strings.TrimSuffix(manifestPath, ext) + ".lock"
+}
+
+// LockFile contains the list of locked extensions.
+type LockFile struct {
+	Extensions []LockedExtension `json:"extensions"`
+}
+
+// LockedExtension is the exact release resolved for an extension.
+type LockedExtension struct {
+	// Channel is the channel serving the extension, as written in the
+	// manifest. It's empty for the default pecl channel.
+	Channel   string `json:"channel,omitempty"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Stability string `json:"stability"`
+	URL       string `json:"url"`
+	Sha256    string `json:"sha256"`
+}
+
+// LoadFromFile loads the lock file at the given path.
+func LoadFromFile(path string) (LockFile, error) {
+	var l LockFile
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return l, xerrors.Errorf("could not load lock file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return l, xerrors.Errorf("could not load lock file %s: %w", path, err)
+	}
+
+	return l, nil
+}
+
+// WriteToFile writes the lock file at the given path.
+func (l LockFile) WriteToFile(path string) error {
+	raw, err := json.MarshalIndent(l, "", "    ")
+	if err != nil {
+		return xerrors.Errorf("could not write lock file %s: %w", path, err)
+	}
+
+	if err := ioutil.WriteFile(path, append(raw, '\n'), 0644); err != nil {
+		return xerrors.Errorf("could not write lock file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Locked returns the locked release for the given extension spec. An error is
+// returned if the extension isn't locked or if the locked version doesn't
+// satisfy the constraint of the spec anymore (ie. the lock file is outdated).
+func (l LockFile) Locked(spec pecl.ExtensionSpec) (LockedExtension, error) {
+	for _, locked := range l.Extensions {
+		if locked.Name != spec.Name {
+			continue
+		}
+		if locked.Channel != spec.Channel {
+			return locked, xerrors.Errorf("lock file is outdated: %s is locked from channel %q but should be installed from %q", spec.Name, locked.Channel, spec.Channel)
+		}
+
+		cg := version.NewConstrainGroupFromString(spec.Constraint)
+		if !cg.Match(locked.Version) {
+			return locked, xerrors.Errorf("lock file is outdated: locked version %s of %s doesn't satisfy %q", locked.Version, spec.Name, spec.Constraint)
+		}
+		if peclapi.StabilityFromString(locked.Stability) < spec.MinimumStability {
+			return locked, xerrors.Errorf("lock file is outdated: locked version %s of %s is %s, but minimum stability is %s", locked.Version, spec.Name, locked.Stability, spec.MinimumStability)
+		}
+
+		return locked, nil
+	}
+
+	return LockedExtension{}, xerrors.Errorf("lock file is outdated: %s is not locked", spec.Name)
+}
+
+// Lock describes the given release of an extension and downloads its archive
+// to compute its digest.
+func Lock(ctx context.Context, client peclapi.Client, name, extVersion string) (LockedExtension, error) {
+	release, err := client.DescribeRelease(ctx, name, extVersion)
+	if err != nil {
+		return LockedExtension{}, xerrors.Errorf("could not lock %s v%s: %w", name, extVersion, err)
+	}
+
+	r, err := client.DownloadRelease(ctx, release, os.TempDir())
+	if err != nil {
+		return LockedExtension{}, xerrors.Errorf("could not lock %s v%s: %w", name, extVersion, err)
+	}
+	defer r.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return LockedExtension{}, xerrors.Errorf("could not lock %s v%s: %w", name, extVersion, err)
+	}
+
+	return LockedExtension{
+		Name:      name,
+		Version:   release.Version,
+		Stability: release.Stability,
+		URL:       release.ArchiveURL(),
+		Sha256:    hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
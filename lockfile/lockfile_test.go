@@ -0,0 +1,184 @@
+package lockfile_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NiR-/notpecl/lockfile"
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/go-test/deep"
+)
+
+type testRoundTripper func(*http.Request) *http.Response
+
+func (fn testRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req), nil
+}
+
+func newTestClient(t *testing.T, resps map[string][]byte) *http.Client {
+	return &http.Client{
+		Transport: testRoundTripper(func(req *http.Request) *http.Response {
+			body, ok := resps[req.URL.String()]
+			if !ok {
+				t.Fatalf("No matching resps found for %s", req.URL)
+			}
+
+			return &http.Response{
+				StatusCode:    200,
+				Body:          ioutil.NopCloser(bytes.NewBuffer(body)),
+				ContentLength: int64(len(body)),
+			}
+		}),
+	}
+}
+
+func loadRawTestdata(t *testing.T, filepath string) []byte {
+	raw, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestPathFor(t *testing.T) {
+	testcases := map[string]string{
+		"extensions.json":       "extensions.lock",
+		"/app/php/notpecl.yaml": "/app/php/notpecl.lock",
+		"/app/php/notpecl.yml":  "/app/php/notpecl.lock",
+		"/app/php.d/extensions": "/app/php.d/extensions.lock",
+	}
+
+	for manifestPath, expected := range testcases {
+		if out := lockfile.PathFor(manifestPath); out != expected {
+			t.Fatalf("Expected lock file path for %s: %s - Got: %s", manifestPath, expected, out)
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	release := loadRawTestdata(t, "testdata/redis-release-5.1.1.xml")
+	tgz := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+	httpClient := newTestClient(t, map[string][]byte{
+		"https://pecl.php.net/rest/r/redis/5.1.1.xml": release,
+		"https://pecl.php.net/get/redis-5.1.1.tgz":    tgz,
+	})
+	client := peclapi.NewClient(peclapi.WithHttpClient(httpClient))
+
+	locked, err := lockfile.Lock(context.Background(), client, "redis", "5.1.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := lockfile.LockedExtension{
+		Name:      "redis",
+		Version:   "5.1.1",
+		Stability: "stable",
+		URL:       "https://pecl.php.net/get/redis-5.1.1.tgz",
+		Sha256:    "621c9d2b4054c797b0e5d5bc5e0f1eeb49bedb37f20e46f838aa4d17d2fe8180",
+	}
+	if diff := deep.Equal(locked, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notpecl-lockfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "extensions.lock")
+	l := lockfile.LockFile{
+		Extensions: []lockfile.LockedExtension{
+			{
+				Name:      "redis",
+				Version:   "5.1.1",
+				Stability: "stable",
+				URL:       "https://pecl.php.net/get/redis-5.1.1.tgz",
+				Sha256:    "621c9d2b4054c797b0e5d5bc5e0f1eeb49bedb37f20e46f838aa4d17d2fe8180",
+			},
+		},
+	}
+
+	if err := l.WriteToFile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded, err := lockfile.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if diff := deep.Equal(loaded, l); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+type lockedTC struct {
+	spec        pecl.ExtensionSpec
+	expected    string
+	expectedErr error
+}
+
+func TestLocked(t *testing.T) {
+	l := lockfile.LockFile{
+		Extensions: []lockfile.LockedExtension{
+			{Name: "redis", Version: "5.1.1", Stability: "stable"},
+			{Name: "yaml", Version: "2.0.0RC8", Stability: "beta"},
+		},
+	}
+
+	testcases := map[string]lockedTC{
+		"find locked version satisfying the constraint": {
+			spec:     pecl.ExtensionSpec{Name: "redis", Constraint: "~5.1.0", MinimumStability: peclapi.Stable},
+			expected: "5.1.1",
+		},
+		"fail when the extension is locked from another channel": {
+			spec:        pecl.ExtensionSpec{Channel: "internal", Name: "redis", Constraint: "*", MinimumStability: peclapi.Stable},
+			expectedErr: fmt.Errorf("lock file is outdated: redis is locked from channel \"\" but should be installed from \"internal\""),
+		},
+		"fail when the extension is not locked": {
+			spec:        pecl.ExtensionSpec{Name: "zip", Constraint: "*", MinimumStability: peclapi.Stable},
+			expectedErr: fmt.Errorf("lock file is outdated: zip is not locked"),
+		},
+		"fail when the locked version doesn't satisfy the constraint": {
+			spec:        pecl.ExtensionSpec{Name: "redis", Constraint: "^5.2", MinimumStability: peclapi.Stable},
+			expectedErr: fmt.Errorf("lock file is outdated: locked version 5.1.1 of redis doesn't satisfy \"^5.2\""),
+		},
+		"fail when the locked version is not stable enough": {
+			spec:        pecl.ExtensionSpec{Name: "yaml", Constraint: "*", MinimumStability: peclapi.Stable},
+			expectedErr: fmt.Errorf("lock file is outdated: locked version 2.0.0RC8 of yaml is beta, but minimum stability is stable"),
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			locked, err := l.Locked(tc.spec)
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if locked.Version != tc.expected {
+				t.Fatalf("Expected locked version: %s - Got: %s", tc.expected, locked.Version)
+			}
+		})
+	}
+}
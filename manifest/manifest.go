@@ -0,0 +1,229 @@
+// Package manifest implements the loading of manifest files. A manifest lists
+// the extensions that should be installed along with their version
+// constraint, their minimum stability and how they should be configured.
+// Manifests can be written either in JSON or in YAML.
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v2"
+)
+
+// Format is the serialization format of a manifest.
+type Format string
+
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+)
+
+// FormatFromPath returns the Format of a manifest based on the extension of
+// its path. An error is returned if the extension isn't supported.
+func FormatFromPath(path string) (Format, error) {
+	switch filepath.Ext(path) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	default:
+		return "", xerrors.Errorf("unsupported manifest format %q (supported: .json, .yaml, .yml)", filepath.Ext(path))
+	}
+}
+
+// LoadFromFile loads the manifest at the given path. The format of the file is
+// guessed from its extension.
+func LoadFromFile(path string) (Manifest, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return Manifest{}, xerrors.Errorf("could not load manifest %s: %w", path, err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Manifest{}, xerrors.Errorf("could not load manifest %s: %w", path, err)
+	}
+
+	m, err := Load(bytes.NewBuffer(raw), format)
+	if err != nil {
+		return m, xerrors.Errorf("could not load manifest %s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// Load reads a manifest in the given format from r and validates it.
+func Load(r io.Reader, format Format) (Manifest, error) {
+	var m Manifest
+
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return m, err
+	}
+
+	switch format {
+	case JSON:
+		// Unknown fields are rejected, like yaml.UnmarshalStrict does, such
+		// that typos aren't silently ignored.
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&m)
+	case YAML:
+		err = yaml.UnmarshalStrict(raw, &m)
+	default:
+		err = xerrors.Errorf("unsupported manifest format %q", format)
+	}
+	if err != nil {
+		return m, err
+	}
+
+	if err := m.Validate(); err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
+
+// Manifest is the list of extensions that should be installed.
+type Manifest struct {
+	// MinimumStability is the default minimum stability used for extensions
+	// that don't specify one. It defaults to stable.
+	MinimumStability string `json:"minimum_stability,omitempty" yaml:"minimum_stability,omitempty"`
+	// Extensions is the list of extensions to install, in order.
+	Extensions []Extension `json:"extensions" yaml:"extensions"`
+	// Matrix lists the PHP installations extensions should be built and
+	// installed for. When empty, they're built for a single PHP installation.
+	Matrix Matrix `json:"matrix,omitempty" yaml:"matrix,omitempty"`
+}
+
+// Matrix lists the PHP installations extensions should be built for. Each
+// extension is built for each of them, in a separate build dir.
+type Matrix struct {
+	// PhpConfig is the list of paths to the php-config of each PHP
+	// installation (eg. /opt/php74/bin/php-config).
+	PhpConfig []string `json:"php_config,omitempty" yaml:"php_config,omitempty"`
+}
+
+// Extension is a single extension listed in a Manifest.
+type Extension struct {
+	// Channel is the name or the alias of the channel serving the extension.
+	// It defaults to the pecl channel.
+	Channel string `json:"channel,omitempty" yaml:"channel,omitempty"`
+	// Name is the name of the extension.
+	Name string `json:"name" yaml:"name"`
+	// Constraint is the version constraint in Composer format. It defaults
+	// to "*".
+	Constraint string `json:"constraint,omitempty" yaml:"constraint,omitempty"`
+	// MinimumStability is the minimum stability accepted when resolving the
+	// version constraint. It defaults to the MinimumStability of the Manifest.
+	MinimumStability string `json:"minimum_stability,omitempty" yaml:"minimum_stability,omitempty"`
+	// ConfigureArgs is a list of flags to pass to ./configure.
+	ConfigureArgs []string `json:"configure_args,omitempty" yaml:"configure_args,omitempty"`
+	// ConfigureOptions maps the name of configure options declared by the
+	// package.xml of the extension to their answer.
+	ConfigureOptions map[string]string `json:"configure_options,omitempty" yaml:"configure_options,omitempty"`
+	// InstallDir is the directory where the extension should be installed.
+	InstallDir string `json:"install_dir,omitempty" yaml:"install_dir,omitempty"`
+	// Ini lists extra ini directives written to the ini file enabling the
+	// extension, when it's enabled after being installed.
+	Ini map[string]string `json:"ini,omitempty" yaml:"ini,omitempty"`
+	// Source is the path to a local release archive or source dir, or a git
+	// source in the format git+<url>[#<ref>] where ref could be a branch, a
+	// tag or a commit to pin (see pecl.ParseGitSource), the extension should
+	// be installed from instead of downloading it. In that case, Channel,
+	// Constraint and MinimumStability are ignored.
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+// Validate checks that the manifest lists at least one extension, that all
+// of them have a valid spec, that no extension is listed twice and that no
+// php-config of the matrix is empty or listed twice.
+func (m Manifest) Validate() error {
+	if len(m.Extensions) == 0 {
+		return xerrors.Errorf("no extensions listed")
+	}
+	if m.MinimumStability != "" && peclapi.StabilityFromString(m.MinimumStability) == peclapi.Unknown {
+		return xerrors.Errorf("unsupported minimum stability %q", m.MinimumStability)
+	}
+
+	seenPhpConfigs := map[string]struct{}{}
+	for i, phpConfig := range m.Matrix.PhpConfig {
+		if phpConfig == "" {
+			return xerrors.Errorf("matrix: php_config #%d is empty", i+1)
+		}
+		if _, ok := seenPhpConfigs[phpConfig]; ok {
+			return xerrors.Errorf("matrix: php_config %s is listed more than once", phpConfig)
+		}
+		seenPhpConfigs[phpConfig] = struct{}{}
+	}
+
+	seen := map[string]struct{}{}
+	for i, ext := range m.Extensions {
+		if _, err := m.Spec(ext); err != nil {
+			return xerrors.Errorf("extension #%d: %w", i+1, err)
+		}
+		if _, ok := seen[ext.Name]; ok {
+			return xerrors.Errorf("extension #%d: %s is listed more than once", i+1, ext.Name)
+		}
+		seen[ext.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// Spec returns the pecl.ExtensionSpec of the given extension, using the
+// manifest defaults for missing values.
+func (m Manifest) Spec(ext Extension) (pecl.ExtensionSpec, error) {
+	stability := peclapi.Stable
+	if m.MinimumStability != "" {
+		stability = peclapi.StabilityFromString(m.MinimumStability)
+	}
+
+	raw := ext.Name
+	if ext.Channel != "" {
+		raw = ext.Channel + "/" + raw
+	}
+	if ext.Constraint != "" {
+		raw += ":" + ext.Constraint
+	}
+	if ext.MinimumStability != "" {
+		raw += "@" + ext.MinimumStability
+	}
+
+	return pecl.ParseExtensionSpec(raw, stability)
+}
+
+// EnableOpts returns the pecl.EnableOpts used to enable the extension once
+// it's installed. The Priority is set to pecl.DefaultIniPriority.
+func (ext Extension) EnableOpts() pecl.EnableOpts {
+	return pecl.EnableOpts{
+		Extension:  ext.Name,
+		InstallDir: ext.InstallDir,
+		Priority:   pecl.DefaultIniPriority,
+		Directives: ext.Ini,
+	}
+}
+
+// InstallOpts returns the pecl.InstallOpts used to install the given version
+// of the extension. Parallel and Cleanup are left to their zero value as they
+// don't depend on the manifest.
+func (ext Extension) InstallOpts(version, downloadDir string) pecl.InstallOpts {
+	return pecl.InstallOpts{
+		DownloadOpts: pecl.DownloadOpts{
+			Extension:   ext.Name,
+			Version:     version,
+			DownloadDir: downloadDir,
+		},
+		InstallDir:       ext.InstallDir,
+		ConfigureArgs:    ext.ConfigureArgs,
+		ConfigureOptions: ext.ConfigureOptions,
+		Source:           ext.Source,
+	}
+}
@@ -0,0 +1,141 @@
+package manifest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NiR-/notpecl/manifest"
+	"github.com/go-test/deep"
+)
+
+type loadTC struct {
+	file        string
+	expected    manifest.Manifest
+	expectedErr error
+}
+
+func expectedManifest() manifest.Manifest {
+	return manifest.Manifest{
+		MinimumStability: "beta",
+		Extensions: []manifest.Extension{
+			{
+				Name:             "redis",
+				Constraint:       "~5.1.0",
+				MinimumStability: "stable",
+				ConfigureArgs:    []string{"--enable-redis-lzf"},
+				ConfigureOptions: map[string]string{
+					"enable-redis-igbinary": "yes",
+				},
+				InstallDir: "/opt/php/ext",
+				Ini: map[string]string{
+					"redis.session.locking_enabled": "1",
+				},
+			},
+			{
+				Name: "yaml",
+			},
+		},
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	testcases := map[string]loadTC{
+		"successfully load a JSON manifest": {
+			file:     "testdata/extensions.json",
+			expected: expectedManifest(),
+		},
+		"successfully load a YAML manifest": {
+			file:     "testdata/extensions.yaml",
+			expected: expectedManifest(),
+		},
+		"successfully load a manifest with a matrix": {
+			file: "testdata/extensions-matrix.yaml",
+			expected: manifest.Manifest{
+				Extensions: []manifest.Extension{
+					{Name: "redis"},
+				},
+				Matrix: manifest.Matrix{
+					PhpConfig: []string{
+						"/opt/php74/bin/php-config",
+						"/opt/php81/bin/php-config",
+					},
+				},
+			},
+		},
+		"fail to load a manifest with a php-config listed twice in its matrix": {
+			file:        "testdata/extensions-matrix-duplicated.json",
+			expectedErr: fmt.Errorf("could not load manifest testdata/extensions-matrix-duplicated.json: matrix: php_config /opt/php74/bin/php-config is listed more than once"),
+		},
+		"fail to load a manifest with an unsupported format": {
+			file:        "testdata/extensions.toml",
+			expectedErr: fmt.Errorf("could not load manifest testdata/extensions.toml: unsupported manifest format \".toml\" (supported: .json, .yaml, .yml)"),
+		},
+		"fail to load a manifest with a duplicated extension": {
+			file:        "testdata/extensions-duplicated.json",
+			expectedErr: fmt.Errorf("could not load manifest testdata/extensions-duplicated.json: extension #2: redis is listed more than once"),
+		},
+		"fail to load a manifest with an unsupported stability": {
+			file:        "testdata/extensions-bad-stability.yaml",
+			expectedErr: fmt.Errorf("could not load manifest testdata/extensions-bad-stability.yaml: extension #1: invalid extension spec \"redis@rc\": unsupported stability \"rc\""),
+		},
+		"fail to load a JSON manifest with an unknown field": {
+			file:        "testdata/extensions-unknown-field.json",
+			expectedErr: fmt.Errorf("could not load manifest testdata/extensions-unknown-field.json: json: unknown field \"constraints\""),
+		},
+		"fail to load a manifest without extensions": {
+			file:        "testdata/extensions-empty.yaml",
+			expectedErr: fmt.Errorf("could not load manifest testdata/extensions-empty.yaml: no extensions listed"),
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := manifest.LoadFromFile(tc.file)
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(m, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestSpec(t *testing.T) {
+	m := expectedManifest()
+
+	redis, err := m.Spec(m.Extensions[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if redis.String() != "redis:~5.1.0@stable" {
+		t.Fatalf("Expected spec: redis:~5.1.0@stable - Got: %s", redis)
+	}
+
+	yaml, err := m.Spec(m.Extensions[1])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if yaml.String() != "yaml:*@beta" {
+		t.Fatalf("Expected spec: yaml:*@beta - Got: %s", yaml)
+	}
+
+	internal, err := m.Spec(manifest.Extension{Channel: "internal", Name: "acme"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if internal.String() != "internal/acme:*@beta" {
+		t.Fatalf("Expected spec: internal/acme:*@beta - Got: %s", internal)
+	}
+}
@@ -1,19 +1,18 @@
 package pecl
 
 import (
-	"archive/tar"
-	"bytes"
-	"compress/gzip"
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/NiR-/notpecl/cmdexec"
 	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclcache"
 	"github.com/NiR-/notpecl/peclpkg"
 	"github.com/NiR-/notpecl/ui"
 	"github.com/mcuadros/go-version"
@@ -23,10 +22,17 @@ import (
 )
 
 type Backend interface {
-	ResolveConstraint(name, constraint string, minimumStability peclapi.Stability) (string, error)
-	Install(opts InstallOpts) error
-	Download(opts DownloadOpts) (string, error)
-	Build(opts BuildOpts) error
+	ResolveConstraint(ctx context.Context, name, constraint string, minimumStability peclapi.Stability) (string, error)
+	Install(ctx context.Context, opts InstallOpts) error
+	Download(ctx context.Context, opts DownloadOpts) (string, error)
+	Build(ctx context.Context, opts BuildOpts) error
+	BuildEnv(opts BuildOpts) []string
+	PHPVersion(ctx context.Context) (string, error)
+	FindLatestReleases(ctx context.Context, name, constraint string, minimumStability peclapi.Stability) (LatestReleases, error)
+	Info(ctx context.Context, name, constraint string, minimumStability peclapi.Stability) (ExtensionInfo, error)
+	Enable(ctx context.Context, opts EnableOpts) error
+	Uninstall(ctx context.Context, opts UninstallOpts) ([]string, error)
+	LoadSourcePackage(ctx context.Context, source, downloadDir string) (peclpkg.Package, string, error)
 }
 
 type backend struct {
@@ -35,6 +41,11 @@ type backend struct {
 	fs            vfs.FS
 	cmdexec       cmdexec.CmdExecutor
 	phpConfigPath string
+	phpBinary     string
+	offline       bool
+	extractLimits ExtractLimits
+	environ       []string
+	stateDir      string
 }
 
 // New creates a new pecl backend with d default (and fully working) peclapi
@@ -47,6 +58,9 @@ func New(opts ...BackendOpt) backend {
 		apiClient: peclapi.NewClient(),
 		fs:        vfs.HostOSFS,
 		cmdexec:   cmdexec.NewExecutor(),
+
+		extractLimits: DefaultExtractLimits,
+		environ:       os.Environ(),
 	}
 	for _, opt := range opts {
 		opt(&b)
@@ -89,26 +103,92 @@ func WithCmdExec(cmdexec cmdexec.CmdExecutor) BackendOpt {
 	}
 }
 
+// WithPhpConfigPath returns a BackendOpt that could be used with New() to
+// build extensions for the PHP installation the given php-config belongs to,
+// instead of the one found in PATH.
 func WithPhpConfigPath(phpConfigPath string) BackendOpt {
 	return func(b *backend) {
 		b.phpConfigPath = phpConfigPath
 	}
 }
 
+// WithPhpBinary returns a BackendOpt that could be used with New() to change
+// the php binary used to check the dependencies of extensions. Unless
+// WithPhpConfigPath is used too, the php-config script living next to it is
+// used to build extensions.
+func WithPhpBinary(phpBinary string) BackendOpt {
+	return func(b *backend) {
+		b.phpBinary = phpBinary
+	}
+}
+
+// WithOffline returns a BackendOpt that could be used with New() to indicate
+// that the peclapi.Client used can only serve local files (see
+// peclcache.OfflineTransport). In that case, ResolveConstraint only considers
+// releases available offline.
+func WithOffline() BackendOpt {
+	return func(b *backend) {
+		b.offline = true
+	}
+}
+
+// WithEnviron returns a BackendOpt that could be used with New() to change
+// the environment variables, in the format key=value, the backend reads
+// build settings from (defaults to os.Environ()).
+func WithEnviron(environ []string) BackendOpt {
+	return func(b *backend) {
+		b.environ = environ
+	}
+}
+
+// WithStateDir returns a BackendOpt that could be used with New() to record
+// the extensions installed by Install in the registry of the given state dir
+// (see package installed). Nothing is recorded when it's not used.
+func WithStateDir(stateDir string) BackendOpt {
+	return func(b *backend) {
+		b.stateDir = stateDir
+	}
+}
+
 // ResolveConstraint takes an extension name, a version constraint in
 // Composer format and also the minimum stability accepted. It tries to find
 // a release of that extension that statifies the version constraint and the
 // minimum stability.
 func (b backend) ResolveConstraint(
+	ctx context.Context,
 	name,
 	constraint string,
 	minimumStability peclapi.Stability,
 ) (string, error) {
-	extVersions, err := b.apiClient.ListReleases(name)
+	extVersions, err := b.apiClient.ListReleases(ctx, name)
+	if err != nil {
+		return "", xerrors.Errorf("could not resolve constraint for %s: %w", name, err)
+	}
+
+	extVer, err := b.newestRelease(ctx, name, extVersions, constraint, minimumStability)
 	if err != nil {
 		return "", xerrors.Errorf("could not resolve constraint for %s: %w", name, err)
 	}
+	if extVer != "" {
+		return extVer, nil
+	}
+
+	if b.offline {
+		return "", xerrors.Errorf("could not find a version of %s satisfying %q among the releases available offline", name, constraint)
+	}
+	return "", xerrors.Errorf("could not find a version of %s satisfying %q", name, constraint)
+}
 
+// newestRelease returns the newest of the given releases that satisfies the
+// version constraint and the minimum stability, and that is available offline
+// when the backend is offline. It returns an empty string when there's none.
+func (b backend) newestRelease(
+	ctx context.Context,
+	name string,
+	extVersions peclapi.PackageReleases,
+	constraint string,
+	minimumStability peclapi.Stability,
+) (string, error) {
 	cg := version.NewConstrainGroupFromString(constraint)
 	sortedVersions := extVersions.Sort()
 
@@ -118,49 +198,136 @@ func (b backend) ResolveConstraint(
 		if stability < minimumStability {
 			continue
 		}
-		if cg.Match(extVer) {
-			return extVer, nil
+		if !cg.Match(extVer) {
+			continue
 		}
+		if b.offline {
+			available, err := b.isAvailableOffline(ctx, name, extVer)
+			if err != nil {
+				return "", err
+			}
+			if !available {
+				logrus.Debugf("Skipping %s v%s: not available offline.", name, extVer)
+				continue
+			}
+		}
+		return extVer, nil
 	}
 
-	return "", xerrors.Errorf("could not find a version of %s satisfying %q", name, constraint)
+	return "", nil
+}
+
+func (b backend) isAvailableOffline(ctx context.Context, name, extVersion string) (bool, error) {
+	_, err := b.apiClient.DescribeRelease(ctx, name, extVersion)
+	var notAvailableErr peclcache.NotAvailableOfflineError
+	if xerrors.As(err, &notAvailableErr) {
+		return false, nil
+	}
+	return err == nil, err
 }
 
 type InstallOpts struct {
 	DownloadOpts
 
+	// Source is the path to a local release archive (as produced by pecl
+	// package), the path to a local source dir or a git source in the format
+	// git+<url>[#<ref>] (see ParseGitSource). When set, the extension is
+	// installed from there instead of being downloaded: its name and version
+	// are read from its package.xml and DownloadOpts.Version is ignored. If
+	// DownloadOpts.Extension is set, it has to match the package name.
+	Source string
+	// Channel is the name of the channel the extension is downloaded from.
+	// It's only used to record the extension installed (see WithStateDir).
+	Channel string
+	// Constraint and MinimumStability are the version constraint and the
+	// minimum stability DownloadOpts.Version was resolved from. Like
+	// Channel, they're only used to record the extension installed.
+	Constraint       string
+	MinimumStability peclapi.Stability
 	// InstallDir is the directory where the compiled extension is copied to.
 	InstallDir string
 	// ConfigureArgs is a list of flags to pass to ./configure when building
 	// the extension.
 	ConfigureArgs []string
+	// ConfigureOptions maps the name of configure options declared by the
+	// package.xml to the answer that should be used instead of prompting.
+	ConfigureOptions map[string]string
+	// CFlags, CPPFlags and LDFlags override the compiler flags used to build
+	// the extension (see BuildOpts).
+	CFlags   string
+	CPPFlags string
+	LDFlags  string
 	// Parallel is the maximum number of parallel jobs executed by make at once.
 	Parallel int
+	// BuildDir is the directory where the extension is built out of tree (see
+	// BuildOpts). It's removed along with the source code on cleanup.
+	BuildDir string
 	// Clenaup indicates whether source code and build files should be removed
 	// after sucessful builds.
 	Cleanup bool
 }
 
-func (b backend) Install(opts InstallOpts) error {
-	extDir, err := b.Download(opts.DownloadOpts)
-	if err != nil {
-		return err
+func (b backend) Install(ctx context.Context, opts InstallOpts) error {
+	var src preparedSource
+	if opts.Source != "" {
+		var err error
+		src, err = b.prepareSource(ctx, opts.Source, opts.DownloadDir)
+		if err != nil {
+			return xerrors.Errorf("failed to install %s: %w", opts.Source, err)
+		}
+		if opts.Extension != "" && opts.Extension != src.pkg.Name {
+			return xerrors.Errorf("failed to install %s: expected package %s, got %s", opts.Source, opts.Extension, src.pkg.Name)
+		}
+		opts.Extension = src.pkg.Name
+		if src.commit != "" {
+			logrus.Infof("Installing %s from %s (commit %s).", describePackage(src.pkg), opts.Source, src.commit)
+		} else {
+			logrus.Infof("Installing %s from %s.", describePackage(src.pkg), opts.Source)
+		}
+	} else {
+		extDir, err := b.Download(ctx, opts.DownloadOpts)
+		if err != nil {
+			return err
+		}
+		src = preparedSource{
+			sourceDir:      extDir,
+			packageXmlPath: filepath.Join(extDir, "package.xml"),
+			managed:        true,
+		}
 	}
 
 	buildOpts := BuildOpts{
-		SourceDir:      extDir,
-		InstallDir:     opts.InstallDir,
-		PackageXmlPath: filepath.Join(extDir, "package.xml"),
-		ConfigureArgs:  opts.ConfigureArgs,
-		Parallel:       opts.Parallel,
-		Cleanup:        opts.Cleanup,
-	}
-	if err := b.Build(buildOpts); err != nil {
+		SourceDir:        src.sourceDir,
+		InstallDir:       opts.InstallDir,
+		PackageXmlPath:   src.packageXmlPath,
+		ConfigureArgs:    opts.ConfigureArgs,
+		ConfigureOptions: opts.ConfigureOptions,
+		CFlags:           opts.CFlags,
+		CPPFlags:         opts.CPPFlags,
+		LDFlags:          opts.LDFlags,
+		Parallel:         opts.Parallel,
+		BuildDir:         opts.BuildDir,
+		Cleanup:          opts.Cleanup,
+	}
+	configureArgs, err := b.build(ctx, buildOpts)
+	if err != nil {
 		return xerrors.Errorf("failed to install %s: %w", opts.DownloadOpts.Extension, err)
 	}
+	// The extension is installed at this point, such that failing to record
+	// it shouldn't make the install fail.
+	if err := b.recordInstall(ctx, opts, src, configureArgs); err != nil {
+		logrus.Warnf("Failed to record %s as installed: %v", opts.DownloadOpts.Extension, err)
+	}
 
-	if opts.Cleanup {
-		if err := b.fs.RemoveAll(extDir); err != nil {
+	// Source dirs are never removed as they're not managed by notpecl, and
+	// neither are git checkouts (see prepareGitSource).
+	if opts.Cleanup && src.managed {
+		if err := b.fs.RemoveAll(src.sourceDir); err != nil {
+			return xerrors.Errorf("failed to install %s: %w", opts.DownloadOpts.Extension, err)
+		}
+	}
+	if opts.Cleanup && opts.BuildDir != "" {
+		if err := b.fs.RemoveAll(opts.BuildDir); err != nil {
 			return xerrors.Errorf("failed to install %s: %w", opts.DownloadOpts.Extension, err)
 		}
 	}
@@ -176,104 +343,88 @@ type DownloadOpts struct {
 	// DownloadDir is the directory where notpecl decompress downloaded
 	// extension archives.
 	DownloadDir string
+	// Checksum is the expected sha256 digest (hex-encoded) of the extension
+	// archive. When not empty, the archive is rejected if its digest doesn't
+	// match.
+	Checksum string
 }
 
-func (b backend) Download(opts DownloadOpts) (string, error) {
+func (b backend) Download(ctx context.Context, opts DownloadOpts) (string, error) {
 	dirPrefix := fmt.Sprintf("%s-%s/", opts.Extension, opts.Version)
 	extDir := filepath.Join(opts.DownloadDir, dirPrefix)
 	if _, err := b.fs.Stat(extDir); err == nil {
-		return extDir, nil
+		if opts.Checksum == "" {
+			return extDir, nil
+		}
+		// Files extracted beforehand can't be checked against the checksum of
+		// the archive, they might be stale or tampered with. They're extracted
+		// again from an archive verified against the checksum.
+		logrus.Debugf("Removing %s to extract it again from a verified archive.", extDir)
+		if err := b.fs.RemoveAll(extDir); err != nil {
+			return "", xerrors.Errorf("failed to download %s v%s: %w", opts.Extension, opts.Version, err)
+		}
 	}
 
-	release, err := b.apiClient.DescribeRelease(opts.Extension, opts.Version)
+	release, err := b.apiClient.DescribeRelease(ctx, opts.Extension, opts.Version)
 	if err != nil {
 		return "", xerrors.Errorf("failed to download %s v%s: %w", opts.Extension, opts.Version, err)
 	}
 
-	rawr, err := b.apiClient.DownloadRelease(release)
+	archive, err := b.apiClient.DownloadRelease(ctx, release, opts.DownloadDir)
 	if err != nil {
 		return "", xerrors.Errorf("failed to download %s v%s: %w", opts.Extension, opts.Version, err)
 	}
+	defer archive.Close()
 
-	gzipr, err := gzip.NewReader(rawr)
-	if err != nil {
-		return "", xerrors.Errorf("could not decompress %s v%s: %w", opts.Extension, opts.Version, err)
+	if opts.Checksum != "" {
+		if err := verifyChecksum(archive, opts.Checksum); err != nil {
+			return "", xerrors.Errorf("failed to download %s v%s: %w", opts.Extension, opts.Version, err)
+		}
 	}
-	defer gzipr.Close()
 
-	tarr := tar.NewReader(gzipr)
-
-	for {
-		headers, err := tarr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", xerrors.Errorf("could not decompress %s v%s: %w", opts.Extension, opts.Version, err)
-		}
+	if err := b.extract(ctx, extDir, dirPrefix, archive); err != nil {
+		b.removeExtractedFiles(extDir)
+		return "", xerrors.Errorf("could not decompress %s v%s: %w", opts.Extension, opts.Version, err)
+	}
 
-		switch headers.Typeflag {
-		case tar.TypeReg:
-			if err := b.extractFile(extDir, dirPrefix, tarr, headers); err != nil {
-				return "", xerrors.Errorf("could not decompress %s v%s: %w", opts.Extension, opts.Version, err)
-			}
-		}
+	if err := b.verifyExtractedFiles(extDir); err != nil {
+		b.removeExtractedFiles(extDir)
+		return "", xerrors.Errorf("failed to download %s v%s: %w", opts.Extension, opts.Version, err)
 	}
 
 	return extDir, nil
 }
 
-func (b backend) extractFile(
-	extDir string,
-	dirPrefix string,
-	tarr *tar.Reader,
-	headers *tar.Header,
-) error {
-	fullpath := filepath.Join(extDir, headers.Name)
-	if strings.HasPrefix(headers.Name, dirPrefix) {
-		trimmedPath := strings.TrimPrefix(headers.Name, dirPrefix)
-		fullpath = filepath.Join(extDir, trimmedPath)
+// removeExtractedFiles removes partially extracted or corrupted archives. As
+// Download() returns early when extDir already exists and no checksum is
+// provided, they'd be used otherwise.
+func (b backend) removeExtractedFiles(extDir string) {
+	if err := b.fs.RemoveAll(extDir); err != nil {
+		logrus.Warnf("Could not remove %s: %v", extDir, err)
 	}
+}
 
-	dirpath := filepath.Dir(fullpath)
-	if err := vfs.MkdirAll(b.fs, dirpath, 0750); err != nil {
-		return xerrors.Errorf("could not extract %s: could not create %s: %w", headers.Name, dirpath, err)
+// verifyChecksum computes the sha256 digest of the archive and compares it to
+// the expected one. The archive is rewound afterwards, such that it can be
+// extracted.
+func verifyChecksum(archive io.Reader, expected string) error {
+	rs, ok := archive.(io.ReadSeeker)
+	if !ok {
+		return xerrors.Errorf("could not verify checksum: the downloaded archive can't be rewound")
 	}
 
-	f, err := b.fs.Create(fullpath)
-	if err != nil {
-		return xerrors.Errorf("could not extract %s: %w", headers.Name, err)
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, rs); err != nil {
+		return xerrors.Errorf("could not verify checksum: %w", err)
 	}
-	defer f.Close()
-
-	var total int64
-	logrus.Debugf("Unpacking %s (%d bytes)...", headers.Name, headers.Size)
-
-	for {
-		var isEOF bool
-		raw := make([]byte, headers.Size-total)
-		r, err := tarr.Read(raw)
-		if err == io.EOF {
-			isEOF = true
-		} else if err != nil {
-			return xerrors.Errorf("could not extract %s: failed to read from tar archive: %w", headers.Name, err)
-		}
-
-		_, err = f.Write(raw[:r])
-		if err != nil {
-			return xerrors.Errorf("could not extract %s: failed to write extracted file: %w", headers.Name, err)
-		}
-
-		total += int64(r)
-		if isEOF {
-			break
-		}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return xerrors.Errorf("could not verify checksum: %w", err)
 	}
 
-	if total != headers.Size {
-		return xerrors.Errorf("file %q is %d bytes long, but only %d read from tar archive", headers.Name, headers.Size, total)
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return xerrors.Errorf("checksum mismatch: expected sha256 %s, got %s", expected, actual)
 	}
-
 	return nil
 }
 
@@ -284,98 +435,136 @@ type BuildOpts struct {
 	// InstallDir is the folder where the compiled extension should be installed.
 	InstallDir string
 	// PackageXmlPath is the full path to the package.xml for the extension to
-	// build.
+	// build. When empty, the extension name is inferred from the config.m4 in
+	// SourceDir and dependency checks are skipped.
 	PackageXmlPath string
 	// ConfigureArgs is a list of flags to pass to ./configure when building.
 	ConfigureArgs []string
-	// Parallel is the maximum number of parallel jobs executed by make at once.
+	// ConfigureOptions maps the name of configure options declared by the
+	// package.xml to the answer that should be used instead of prompting.
+	ConfigureOptions map[string]string
+	// CFlags, CPPFlags and LDFlags override the CFLAGS, CPPFLAGS and LDFLAGS
+	// used to build the extension when they're not empty (see BuildEnv).
+	CFlags   string
+	CPPFlags string
+	LDFlags  string
+	// Parallel is the maximum number of parallel jobs executed by make at once
+	// (make -j). It's ignored when MAKEFLAGS already sets the number of jobs.
 	Parallel int
+	// BuildDir is the directory where SourceDir is copied to be built out of
+	// tree, leaving SourceDir untouched. Any previous build in BuildDir is
+	// removed. When empty, the extension is built in SourceDir.
+	BuildDir string
 	// Cleanup indicates whether make clean should be run.
 	Cleanup bool
 }
 
-func (b backend) Build(opts BuildOpts) error {
+func (b backend) Build(ctx context.Context, opts BuildOpts) error {
+	_, err := b.build(ctx, opts)
+	return err
+}
+
+// build builds the extension and returns the configure args it was configured
+// with, including the ones asked through the UI. When the extension was
+// already built, it returns the configure args from opts.
+func (b backend) build(ctx context.Context, opts BuildOpts) ([]string, error) {
+	var pkg peclpkg.Package
 	var err error
-	logrus.Debugf("Loading %s...", opts.PackageXmlPath)
+	if opts.PackageXmlPath != "" {
+		logrus.Debugf("Loading %s...", opts.PackageXmlPath)
 
-	xmlPath, err := b.fs.RawPath(opts.PackageXmlPath)
-	if err != nil {
-		return xerrors.Errorf("failed to build package: %w", err)
+		xmlPath, err := b.fs.RawPath(opts.PackageXmlPath)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to build package: %w", err)
+		}
+
+		pkg, err = peclpkg.LoadPackageXMLFromFile(xmlPath)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load package.xml: %v", err)
+		}
+	} else {
+		pkg, err = b.packageFromConfigM4(opts.SourceDir)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to build package: %w", err)
+		}
+		logrus.Debugf("No package.xml provided, building %s as declared by its config.m4.", pkg.Name)
 	}
 
-	pkg, err := peclpkg.LoadPackageXMLFromFile(xmlPath)
-	if err != nil {
-		return xerrors.Errorf("failed to load package.xml: %v", err)
+	workDir := opts.SourceDir
+	if opts.BuildDir != "" {
+		logrus.Debugf("Copying %s to %s to build it out of tree...", opts.SourceDir, opts.BuildDir)
+		if err := b.prepareBuildDir(opts.SourceDir, opts.BuildDir); err != nil {
+			return nil, xerrors.Errorf("failed to build %s: could not prepare build dir: %w", pkg.Name, err)
+		}
+		workDir = opts.BuildDir
 	}
 
-	sourceDir, err := b.fs.RawPath(opts.SourceDir)
+	rawWorkDir, err := b.fs.RawPath(workDir)
 	if err != nil {
-		return xerrors.Errorf("failed to build %s: %w", pkg.Name, err)
+		return nil, xerrors.Errorf("failed to build %s: %w", pkg.Name, err)
 	}
 
 	cmdexec := b.cmdexec.With(
-		cmdexec.BaseDir(sourceDir),
+		cmdexec.BaseDir(rawWorkDir),
 		cmdexec.Stdout(os.Stdout),
 		cmdexec.Stderr(os.Stderr),
-		cmdexec.ExtraEnv([]string{
-			"PATH=" + lookupEnv("PATH", ""),
-			"CFLAGS=" + lookupEnv("PHP_CFLAGS", defaultCflags),
-			"CPPFLAGS=" + lookupEnv("PHP_CPPFLAGS", defaultCppflags),
-			"LDFLAGS=" + lookupEnv("PHP_LDFLAGS", defaultLdflags),
-		}))
-
-	modulePath := filepath.Join(opts.SourceDir, fmt.Sprintf("modules/%s.so", pkg.Name))
+		cmdexec.ExtraEnv(b.BuildEnv(opts)))
+
+	modulePath := filepath.Join(workDir, fmt.Sprintf("modules/%s.so", pkg.Name))
 	if _, err := b.fs.Stat(modulePath); os.IsNotExist(err) {
-		if err := b.checkPackageDependencies(pkg); err != nil {
-			return err
+		php, err := b.resolvePhpInstallation(ctx)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to build %s: %w", pkg.Name, err)
+		}
+
+		if opts.PackageXmlPath != "" {
+			if err := b.checkPackageDependencies(ctx, php, pkg); err != nil {
+				return nil, err
+			}
+		} else {
+			logrus.Warnf("Dependencies of %s can't be checked without a package.xml.", pkg.Name)
 		}
 		if err := askAboutMissingArgs(b.ui, pkg, &opts); err != nil {
-			return err
+			return nil, err
 		}
 
-		if err := b.buildStepPhpize(cmdexec); err != nil {
-			return err
+		if err := b.buildStepPhpize(ctx, cmdexec, php); err != nil {
+			return nil, err
 		}
 
-		if err := b.buildStepConfigure(cmdexec, opts, pkg); err != nil {
-			return err
+		if err := b.buildStepConfigure(ctx, cmdexec, php, opts); err != nil {
+			return nil, err
 		}
 
-		if err := b.buildStepMake(cmdexec); err != nil {
-			return err
+		if err := b.buildStepMake(ctx, cmdexec, opts.Parallel); err != nil {
+			return nil, err
 		}
 	}
 
-	if err := b.buildStepMakeInstall(cmdexec, opts.InstallDir); err != nil {
-		return err
+	if err := b.buildStepMakeInstall(ctx, cmdexec, opts.InstallDir); err != nil {
+		return nil, err
 	}
 
 	if opts.Cleanup {
-		if err := b.buildStepMakeClean(cmdexec); err != nil {
-			return err
+		if err := b.buildStepMakeClean(ctx, cmdexec); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	return opts.ConfigureArgs, nil
 }
 
-func (b backend) buildStepPhpize(cmdexec cmdexec.CmdExecutor) error {
-	if err := cmdexec.Run("phpize"); err != nil {
+func (b backend) buildStepPhpize(ctx context.Context, cmdexec cmdexec.CmdExecutor, php phpInstallation) error {
+	if err := cmdexec.Run(ctx, php.phpize); err != nil {
 		return xerrors.Errorf("failed to run phpize: %v", err)
 	}
 
 	return nil
 }
 
-func (b backend) buildStepConfigure(cmdexec cmdexec.CmdExecutor, opts BuildOpts, pkg peclpkg.Package) error {
-	if b.phpConfigPath == "" {
-		if err := b.resolvePhpConfigPath(); err != nil {
-			return xerrors.Errorf("failed to build %s: %w", pkg.Name, err)
-		}
-	}
-
-	args := append(opts.ConfigureArgs, "--with-php-config="+b.phpConfigPath)
-	err := cmdexec.Run("./configure", args...)
+func (b backend) buildStepConfigure(ctx context.Context, cmdexec cmdexec.CmdExecutor, php phpInstallation, opts BuildOpts) error {
+	args := append(opts.ConfigureArgs, "--with-php-config="+php.phpConfig)
+	err := cmdexec.Run(ctx, "./configure", args...)
 	if err != nil {
 		return xerrors.Errorf("failed to run configure: %v", err)
 	}
@@ -383,62 +572,71 @@ func (b backend) buildStepConfigure(cmdexec cmdexec.CmdExecutor, opts BuildOpts,
 	return nil
 }
 
-func (b *backend) resolvePhpConfigPath() error {
-	var err error
-	b.phpConfigPath, err = exec.LookPath("php-config")
-
-	return err
-}
+func (b backend) buildStepMake(ctx context.Context, cmdexec cmdexec.CmdExecutor, parallel int) error {
+	args := []string{}
+	if makeflags := b.getenv("MAKEFLAGS"); hasJobsFlag(makeflags) {
+		logrus.Debugf("Number of make jobs set by MAKEFLAGS=%q.", makeflags)
+	} else if parallel > 1 {
+		args = append(args, fmt.Sprintf("-j%d", parallel))
+	}
 
-func (b backend) buildStepMake(cmdexec cmdexec.CmdExecutor) error {
-	if err := cmdexec.Run("make"); err != nil {
+	if err := cmdexec.Run(ctx, "make", args...); err != nil {
 		return xerrors.Errorf("failed to run make: %v", err)
 	}
 
 	return nil
 }
 
-func (b backend) buildStepMakeInstall(cmdexec cmdexec.CmdExecutor, installDir string) error {
+func (b backend) buildStepMakeInstall(ctx context.Context, cmdexec cmdexec.CmdExecutor, installDir string) error {
 	installArgs := make([]string, 0, 2)
 	if installDir != "" {
 		installArgs = append(installArgs, "INSTALL_ROOT="+installDir)
 	}
 	installArgs = append(installArgs, "install")
 
-	if err := cmdexec.Run("make", installArgs...); err != nil {
+	if err := cmdexec.Run(ctx, "make", installArgs...); err != nil {
 		return xerrors.Errorf("failed to run make install: %v", err)
 	}
 
 	return nil
 }
 
-func (b backend) buildStepMakeClean(cmdexec cmdexec.CmdExecutor) error {
-	if err := cmdexec.Run("make", "clean"); err != nil {
+func (b backend) buildStepMakeClean(ctx context.Context, cmdexec cmdexec.CmdExecutor) error {
+	if err := cmdexec.Run(ctx, "make", "clean"); err != nil {
 		return xerrors.Errorf("failed to run make clean: %v", err)
 	}
 
 	return nil
 }
 
-var (
-	defaultCflags   = "-fstack-protector-strong -fpic -fpie -O2 -D_LARGEFILE_SOURCE -D_FILE_OFFSET_BITS=64"
-	defaultCppflags = "-fstack-protector-strong -fpic -fpie -O2 -D_LARGEFILE_SOURCE -D_FILE_OFFSET_BITS=64"
-	defaultLdflags  = "-Wl,-O1 -Wl,--hash-style=both -pie"
-)
-
-func lookupEnv(name, defaultVal string) string {
-	val, _ := os.LookupEnv(name)
-	return val
+// hasJobsFlag returns true when the given MAKEFLAGS sets the number of jobs
+// make can run at once (eg. "-j4", "--jobs=4" or "j4" as GNU make allows
+// single-letter flags to be passed without dash in MAKEFLAGS), or when a
+// parent make shares its jobserver (--jobserver-auth or --jobserver-fds).
+func hasJobsFlag(makeflags string) bool {
+	for i, flag := range strings.Fields(makeflags) {
+		if strings.HasPrefix(flag, "-j") || flag == "--jobs" || strings.HasPrefix(flag, "--jobs=") {
+			return true
+		}
+		// Older versions of GNU make use --jobserver-fds instead.
+		if strings.HasPrefix(flag, "--jobserver-auth=") || strings.HasPrefix(flag, "--jobserver-fds=") {
+			return true
+		}
+		if i == 0 && !strings.HasPrefix(flag, "-") && !strings.Contains(flag, "=") && strings.Contains(flag, "j") {
+			return true
+		}
+	}
+	return false
 }
 
-func (b backend) checkPackageDependencies(pkg peclpkg.Package) error {
+func (b backend) checkPackageDependencies(ctx context.Context, php phpInstallation, pkg peclpkg.Package) error {
 	logrus.Debug("Checking extension dependencies...")
-	if err := b.checkPHPVersion(pkg.Dependencies.Required.PHP); err != nil {
+	if err := checkPHPVersion(php.version, pkg.Dependencies.Required.PHP); err != nil {
 		return err
 	}
 
 	for _, dep := range pkg.Dependencies.Required.Extensions {
-		isEnabled, err := b.isExtensionEnabled(dep.Name)
+		isEnabled, err := b.isExtensionEnabled(ctx, php.binary, dep.Name)
 		if err != nil {
 			return err
 		}
@@ -448,7 +646,7 @@ func (b backend) checkPackageDependencies(pkg peclpkg.Package) error {
 	}
 
 	for _, dep := range pkg.Dependencies.Optional.Extensions {
-		isEnabled, err := b.isExtensionEnabled(dep.Name)
+		isEnabled, err := b.isExtensionEnabled(ctx, php.binary, dep.Name)
 		if err != nil {
 			return err
 		}
@@ -460,7 +658,7 @@ func (b backend) checkPackageDependencies(pkg peclpkg.Package) error {
 	return nil
 }
 
-func (b backend) checkPHPVersion(extConstraint peclpkg.PHPConstraint) error {
+func checkPHPVersion(currentVersion string, extConstraint peclpkg.PHPConstraint) error {
 	cg := version.NewConstrainGroup()
 	if extConstraint.Min != "" {
 		cg.AddConstraint(version.NewConstrain(">=", extConstraint.Min))
@@ -473,11 +671,6 @@ func (b backend) checkPHPVersion(extConstraint peclpkg.PHPConstraint) error {
 		cg.AddConstraint(version.NewConstrain("!=", excluded))
 	}
 
-	currentVersion, err := b.currentPHPVersion()
-	if err != nil {
-		return err
-	}
-
 	if !cg.Match(currentVersion) {
 		return xerrors.Errorf(
 			"current php version is %s, required >=%s,<=%s (excluded: %v)",
@@ -491,40 +684,10 @@ func (b backend) checkPHPVersion(extConstraint peclpkg.PHPConstraint) error {
 	return nil
 }
 
-func (b backend) currentPHPVersion() (string, error) {
-	var outbuf bytes.Buffer
-	cmdexec := b.cmdexec.With(cmdexec.Stdout(&outbuf))
-
-	err := cmdexec.Run("php", "-r", "echo json_encode(PHP_VERSION);")
-	if err != nil {
-		return "", err
-	}
-
-	var phpVersion string
-	if err := json.Unmarshal(outbuf.Bytes(), &phpVersion); err != nil {
-		return "", err
-	}
-
-	return phpVersion, nil
-}
-
-func (b backend) isExtensionEnabled(name string) (bool, error) {
-	var outbuf bytes.Buffer
-	cmdexec := b.cmdexec.With(cmdexec.Stdout(&outbuf))
-	err := cmdexec.Run("php", "-r",
-		fmt.Sprintf("echo json_encode(extension_loaded('%s'));", name))
-	if err != nil {
-		return false, err
-	}
-
-	var val bool
-	if err := json.Unmarshal(outbuf.Bytes(), &val); err != nil {
-		return false, err
-	}
-
-	return val, nil
-}
-
+// askAboutMissingArgs adds a configure flag for every configure option
+// declared by the package.xml and not already part of the configure args.
+// Answers provided through opts.ConfigureOptions are used as is, others are
+// asked through the UI.
 func askAboutMissingArgs(u ui.UI, pkg peclpkg.Package, opts *BuildOpts) error {
 	currentFlags := map[string]struct{}{}
 	for _, flag := range opts.ConfigureArgs {
@@ -533,21 +696,37 @@ func askAboutMissingArgs(u ui.UI, pkg peclpkg.Package, opts *BuildOpts) error {
 		currentFlags[flagName] = struct{}{}
 	}
 
+	declaredOpts := map[string]struct{}{}
 	for _, configOpt := range pkg.ExtSrcRelease.ConfigureOptions {
+		declaredOpts[configOpt.Name] = struct{}{}
 		if _, ok := currentFlags[configOpt.Name]; ok {
 			continue
 		}
 
-		val, err := u.Prompt(configOpt.Prompt, configOpt.Default)
-		if err != nil {
-			return err
+		val, ok := opts.ConfigureOptions[configOpt.Name]
+		if !ok {
+			var err error
+			val, err = u.Prompt(configOpt.Prompt, configOpt.Default)
+			if err != nil {
+				return err
+			}
 		}
 
-		flag := "--" + configOpt.Name + "=" + val
-		if strings.HasPrefix(configOpt.Name, "with-") && (val == "yes" || val == "autodetect") {
-			flag = "--" + configOpt.Name
+		opts.ConfigureArgs = append(opts.ConfigureArgs, configureFlag(configOpt.Name, val))
+	}
+
+	for name := range opts.ConfigureOptions {
+		if _, ok := declaredOpts[name]; !ok {
+			logrus.Warnf("Configure option %q is not declared by %s, it's ignored.", name, pkg.Name)
 		}
-		opts.ConfigureArgs = append(opts.ConfigureArgs, flag)
 	}
+
 	return nil
 }
+
+func configureFlag(name, val string) string {
+	if strings.HasPrefix(name, "with-") && (val == "yes" || val == "autodetect") {
+		return "--" + name
+	}
+	return "--" + name + "=" + val
+}
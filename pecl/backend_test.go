@@ -1,21 +1,31 @@
 package pecl_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/NiR-/notpecl/cmdexec"
 	"github.com/NiR-/notpecl/pecl"
 	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclcache"
+	"github.com/twpayne/go-vfs"
 	"github.com/twpayne/go-vfs/vfst"
 )
 
 var phpconfigPath string
 
+// phpizePath is the path to phpize for the PHP installation faked by fakePHP.
+var phpizePath string
+
 func init() {
 	if cmdexec.IsMockbin() {
 		cmdexec.Mockbin()
@@ -25,6 +35,25 @@ func init() {
 	if phpconfigPath == "" {
 		phpconfigPath = "/usr/bin/php-config"
 	}
+	phpizePath = "/usr/bin/phpize" + strings.TrimPrefix(filepath.Base(phpconfigPath), "php-config")
+}
+
+// fakePHP returns an ExecOpt faking a PHP installation of the given version,
+// installed in /usr with its php-config at phpconfigPath.
+func fakePHP(phpVersion string) cmdexec.ExecOpt {
+	fakes := []cmdexec.ExecOpt{
+		cmdexec.FakeOn([]string{phpconfigPath, "--prefix"},
+			cmdexec.FakeStdout("/usr\n")),
+		cmdexec.FakeOn([]string{phpconfigPath, "--version"},
+			cmdexec.FakeStdout(phpVersion+"\n")),
+		cmdexec.FakeOn([]string{"php", "-r", "echo json_encode(PHP_VERSION);"},
+			cmdexec.FakeStdout("\""+phpVersion+"\"")),
+	}
+	return func(cmd *exec.Cmd) {
+		for _, fake := range fakes {
+			fake(cmd)
+		}
+	}
 }
 
 func newTestRoundTripper(
@@ -83,6 +112,7 @@ func loadRawTestdata(t *testing.T, filepath string) []byte {
 
 type resolveConstraintTC struct {
 	httpClient       *http.Client
+	backendOpts      []pecl.BackendOpt
 	extension        string
 	constraint       string
 	minimumStability peclapi.Stability
@@ -117,10 +147,60 @@ func initFailToResolveWhenClientFailsTC(t *testing.T) resolveConstraintTC {
 	}
 }
 
+// offlineRoundTripper serves the given resps and returns a
+// peclcache.NotAvailableOfflineError for any other URL.
+type offlineRoundTripper map[string][]byte
+
+func (resps offlineRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := resps[req.URL.String()]
+	if !ok {
+		return nil, peclcache.NotAvailableOfflineError{URL: req.URL.String()}
+	}
+
+	return &http.Response{
+		StatusCode:    200,
+		Body:          ioutil.NopCloser(bytes.NewBuffer(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+func initSuccessfullyResolveLastVersionAvailableOfflineTC(t *testing.T) resolveConstraintTC {
+	roundTripper := offlineRoundTripper{
+		"https://pecl.php.net/rest/r/redis/allreleases.xml": loadRawTestdata(t, "testdata/redis-releases.xml"),
+		"https://pecl.php.net/rest/r/redis/5.1.1.xml":       loadRawTestdata(t, "testdata/redis-release-5.1.1.xml"),
+	}
+
+	return resolveConstraintTC{
+		httpClient:       &http.Client{Transport: roundTripper},
+		backendOpts:      []pecl.BackendOpt{pecl.WithOffline()},
+		extension:        "redis",
+		constraint:       "*",
+		minimumStability: peclapi.Stable,
+		expected:         "5.1.1",
+	}
+}
+
+func initFailToResolveWhenNoVersionIsAvailableOfflineTC(t *testing.T) resolveConstraintTC {
+	roundTripper := offlineRoundTripper{
+		"https://pecl.php.net/rest/r/redis/allreleases.xml": loadRawTestdata(t, "testdata/redis-releases.xml"),
+	}
+
+	return resolveConstraintTC{
+		httpClient:       &http.Client{Transport: roundTripper},
+		backendOpts:      []pecl.BackendOpt{pecl.WithOffline()},
+		extension:        "redis",
+		constraint:       "~5.1.0",
+		minimumStability: peclapi.Stable,
+		expectedErr:      fmt.Errorf("could not find a version of redis satisfying \"~5.1.0\" among the releases available offline"),
+	}
+}
+
 func TestResolveConstraint(t *testing.T) {
 	testcases := map[string]func(*testing.T) resolveConstraintTC{
-		"successfully resolve last stable version":         initSuccessfullyResolveLastStableVersionTC,
-		"fail to resolve constraint when API client fails": initFailToResolveWhenClientFailsTC,
+		"successfully resolve last stable version":             initSuccessfullyResolveLastStableVersionTC,
+		"fail to resolve constraint when API client fails":     initFailToResolveWhenClientFailsTC,
+		"successfully resolve last version available offline":  initSuccessfullyResolveLastVersionAvailableOfflineTC,
+		"fail to resolve when no version is available offline": initFailToResolveWhenNoVersionIsAvailableOfflineTC,
 	}
 
 	for tcname := range testcases {
@@ -131,9 +211,9 @@ func TestResolveConstraint(t *testing.T) {
 
 			tc := tcinit(t)
 			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
-			backend := pecl.New(pecl.WithClient(client))
+			backend := pecl.New(append(tc.backendOpts, pecl.WithClient(client))...)
 
-			resolved, err := backend.ResolveConstraint(tc.extension, tc.constraint, tc.minimumStability)
+			resolved, err := backend.ResolveConstraint(context.Background(), tc.extension, tc.constraint, tc.minimumStability)
 			if tc.expectedErr != nil {
 				if err == nil || tc.expectedErr.Error() != err.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
@@ -152,7 +232,9 @@ func TestResolveConstraint(t *testing.T) {
 }
 
 type peclDownloadTC struct {
-	httpClient   *http.Client
+	httpClient *http.Client
+	// files are put in the download dir before downloading.
+	files        map[string]interface{}
 	downloadOpts pecl.DownloadOpts
 	// expected is the path where the extensions should be downloaded
 	expected    string
@@ -178,13 +260,92 @@ func initSuccessfullyDownloadZipV1155TC(t *testing.T) peclDownloadTC {
 		expected: "/tmp/zip-1.15.5",
 		fsTests: []interface{}{
 			vfst.TestPath("/tmp/zip-1.15.5", vfst.TestIsDir),
+			vfst.TestPath("/tmp/zip-1.15.5.tgz.part", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initSuccessfullyDownloadZipWithMatchingChecksumTC(t *testing.T) peclDownloadTC {
+	tc := initSuccessfullyDownloadZipV1155TC(t)
+	tc.downloadOpts.Checksum = "23e55398820dff9775ed08cdba7267d5e9f4895e64ffb427a233aab86ccc5d9a"
+	return tc
+}
+
+func initFailToDownloadZipWithMismatchingChecksumTC(t *testing.T) peclDownloadTC {
+	tc := initSuccessfullyDownloadZipV1155TC(t)
+	tc.downloadOpts.Checksum = "621c9d2b4054c797b0e5d5bc5e0f1eeb49bedb37f20e46f838aa4d17d2fe8180"
+	tc.expectedErr = fmt.Errorf("failed to download zip v1.15.5: checksum mismatch: expected sha256 621c9d2b4054c797b0e5d5bc5e0f1eeb49bedb37f20e46f838aa4d17d2fe8180, got 23e55398820dff9775ed08cdba7267d5e9f4895e64ffb427a233aab86ccc5d9a")
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
+	}
+	return tc
+}
+
+func initFailToReuseExtractedZipWithMismatchingChecksumTC(t *testing.T) peclDownloadTC {
+	tc := initFailToDownloadZipWithMismatchingChecksumTC(t)
+	tc.files = map[string]interface{}{
+		"/tmp/zip-1.15.5/config.m4":  "dnl tampered",
+		"/tmp/zip-1.15.5/backdoor.c": "",
+	}
+	return tc
+}
+
+func initSuccessfullyExtractZipAgainWithMatchingChecksumTC(t *testing.T) peclDownloadTC {
+	tc := initSuccessfullyDownloadZipWithMatchingChecksumTC(t)
+	tc.files = map[string]interface{}{
+		"/tmp/zip-1.15.5/config.m4":  "dnl tampered",
+		"/tmp/zip-1.15.5/backdoor.c": "",
+	}
+	tc.fsTests = append(tc.fsTests,
+		vfst.TestPath("/tmp/zip-1.15.5/backdoor.c", vfst.TestDoesNotExist),
+		vfst.TestPath("/tmp/zip-1.15.5/config.m4", vfst.TestModeIsRegular,
+			func(t *testing.T, fs vfs.FS, path string) {
+				raw, err := fs.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(raw) == "dnl tampered" {
+					t.Errorf("Expected %s to be extracted again", path)
+				}
+			}))
+	return tc
+}
+
+func initFailToDownloadTamperedZipTC(t *testing.T) peclDownloadTC {
+	releases := loadRawTestdata(t, "testdata/zip-release-1.15.5.xml")
+	// This archive lacks tests/001.phpt, contains an extra backdoor.c and
+	// config.m4 has been modified.
+	tgz := loadRawTestdata(t, "testdata/zip-1.15.5-tampered.tgz")
+	roundTripper := newTestRoundTripper(t, map[string][]byte{
+		"https://pecl.php.net/rest/r/zip/1.15.5.xml": releases,
+		"https://pecl.php.net/get/zip-1.15.5.tgz":    tgz,
+	})
+
+	return peclDownloadTC{
+		httpClient: newTestClient(roundTripper),
+		downloadOpts: pecl.DownloadOpts{
+			Extension:   "zip",
+			Version:     "1.15.5",
+			DownloadDir: "/tmp",
+		},
+		expectedErr: fmt.Errorf(`failed to download zip v1.15.5: extracted files don't match package.xml (3 mismatches):
+  - missing: tests/001.phpt
+  - extra: backdoor.c
+  - corrupted: config.m4 (expected md5 d3a296432d6509b2b848075a55511b86, got 1f496d9f60c21e38dc016f7c5454e6c6)`),
+		fsTests: []interface{}{
+			vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
 		},
 	}
 }
 
 func TestDownload(t *testing.T) {
 	testcases := map[string]func(*testing.T) peclDownloadTC{
-		"successfully download zip v1.15.5": initSuccessfullyDownloadZipV1155TC,
+		"fail to download zip v1.15.5 when extracted files don't match package.xml": initFailToDownloadTamperedZipTC,
+		"successfully download zip v1.15.5":                                         initSuccessfullyDownloadZipV1155TC,
+		"successfully download zip v1.15.5 with matching sum":                       initSuccessfullyDownloadZipWithMatchingChecksumTC,
+		"fail to download zip v1.15.5 with mismatching checksum":                    initFailToDownloadZipWithMismatchingChecksumTC,
+		"fail to reuse extracted zip v1.15.5 with mismatching checksum":             initFailToReuseExtractedZipWithMismatchingChecksumTC,
+		"successfully extract zip v1.15.5 again with matching checksum":             initSuccessfullyExtractZipAgainWithMatchingChecksumTC,
 	}
 
 	for tcname := range testcases {
@@ -194,27 +355,34 @@ func TestDownload(t *testing.T) {
 			t.Parallel()
 
 			tc := tcinit(t)
-			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
-
-			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+			files := map[string]interface{}{
 				tc.downloadOpts.DownloadDir: &vfst.Dir{
 					Perm: 0750,
 				},
-			})
+			}
+			for path, contents := range tc.files {
+				files[path] = contents
+			}
+			fs, cleanup, err := vfst.NewTestFS(files)
 			if err != nil {
 				t.Fatalf("Unexpected error: %+v", err)
 			}
 			defer cleanup()
 
+			client := peclapi.NewClient(
+				peclapi.WithHttpClient(tc.httpClient),
+				peclapi.WithFS(fs))
+
 			backend := pecl.New(
 				pecl.WithClient(client),
 				pecl.WithFS(fs))
 
-			outpath, err := backend.Download(tc.downloadOpts)
+			outpath, err := backend.Download(context.Background(), tc.downloadOpts)
 			if tc.expectedErr != nil {
 				if err == nil || err.Error() != tc.expectedErr.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
 				}
+				vfst.RunTests(t, fs, "downloaded file", tc.fsTests...)
 				return
 			}
 			if err != nil {
@@ -236,6 +404,11 @@ type installTC struct {
 	recorder    *cmdexec.Recorder
 	cmdTester   cmdexec.Tester
 	opts        pecl.InstallOpts
+	backendOpts []pecl.BackendOpt
+	// files are added to the test filesystem, along with DownloadDir and
+	// InstallDir.
+	files       map[string]interface{}
+	fsTests     []interface{}
 	expectedErr error
 }
 
@@ -249,12 +422,11 @@ func initSuccessfullyInstallZipTC(t *testing.T) installTC {
 
 	executor, recorder := cmdexec.NewTestExecutor()
 	executor = executor.With(
-		cmdexec.FakeOn([]string{"php", "-r", "echo json_encode(PHP_VERSION);"},
-			cmdexec.FakeStdout("\"7.4.3\"")),
+		fakePHP("7.4.3"),
 	)
 
 	cmdTester := cmdexec.BuildTesters(
-		cmdexec.ExpectCommandArgs([]string{"phpize"}),
+		cmdexec.ExpectCommandArgs([]string{phpizePath}),
 		cmdexec.ExpectCommandArgs([]string{
 			"./configure",
 			"--with-php-config=" + phpconfigPath}),
@@ -292,11 +464,10 @@ func initSuccessfullyInstallRedisWithArgsTC(t *testing.T) installTC {
 
 	executor, recorder := cmdexec.NewTestExecutor()
 	executor = executor.With(
-		cmdexec.FakeOn([]string{"php", "-r", "echo json_encode(PHP_VERSION);"},
-			cmdexec.FakeStdout("\"7.4.3\"")),
+		fakePHP("7.4.3"),
 	)
 	cmdTester := cmdexec.BuildTesters(
-		cmdexec.ExpectCommandArgs([]string{"phpize"}),
+		cmdexec.ExpectCommandArgs([]string{phpizePath}),
 		cmdexec.ExpectCommandArgs([]string{
 			"./configure",
 			"--enable-redis-lzf",
@@ -328,12 +499,214 @@ func initSuccessfullyInstallRedisWithArgsTC(t *testing.T) installTC {
 	}
 }
 
+func initSuccessfullyInstallRedisWithConfigureOptionsTC(t *testing.T) installTC {
+	releases := loadRawTestdata(t, "testdata/redis-release-5.1.1.xml")
+	tgz := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+	roundTripper := newTestRoundTripper(t, map[string][]byte{
+		"https://pecl.php.net/rest/r/redis/5.1.1.xml": releases,
+		"https://pecl.php.net/get/redis-5.1.1.tgz":    tgz,
+	})
+
+	executor, recorder := cmdexec.NewTestExecutor()
+	executor = executor.With(
+		fakePHP("7.4.3"),
+	)
+	cmdTester := cmdexec.BuildTesters(
+		cmdexec.ExpectCommandArgs([]string{
+			"./configure",
+			"--enable-redis-lzf",
+			"--enable-redis-igbinary=yes",
+			"--enable-redis-zstd=no",
+			"--with-php-config=" + phpconfigPath}))
+
+	return installTC{
+		httpClient: newTestClient(roundTripper),
+		cmdExec:    executor,
+		recorder:   recorder,
+		cmdTester:  cmdTester,
+		opts: pecl.InstallOpts{
+			DownloadOpts: pecl.DownloadOpts{
+				Extension:   "redis",
+				Version:     "5.1.1",
+				DownloadDir: "/tmp",
+			},
+			ConfigureArgs: []string{"--enable-redis-lzf"},
+			ConfigureOptions: map[string]string{
+				"enable-redis-igbinary": "yes",
+			},
+			InstallDir: "/installdir",
+			Cleanup:    true,
+		},
+	}
+}
+
+func initSuccessfullyInstallZipWithParallelJobsTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipTC(t)
+	tc.opts.Parallel = 4
+	tc.cmdTester = cmdexec.ExpectCommandArgs([]string{"make", "-j4"})
+	return tc
+}
+
+func initSuccessfullyInstallZipWithJobsSetByMakeflagsTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipTC(t)
+	tc.opts.Parallel = 4
+	tc.backendOpts = []pecl.BackendOpt{
+		pecl.WithEnviron([]string{"MAKEFLAGS=-j2"}),
+	}
+	tc.cmdTester = cmdexec.ExpectCommandArgs([]string{"make"})
+	return tc
+}
+
+func TestInstallWithMakeflags(t *testing.T) {
+	testcases := map[string]struct {
+		makeflags        string
+		expectedMakeArgs []string
+	}{
+		"-j4":                   {makeflags: "-j4", expectedMakeArgs: []string{"make"}},
+		"--jobs=4":              {makeflags: "--jobs=4", expectedMakeArgs: []string{"make"}},
+		"short-flag word j4":    {makeflags: "j4", expectedMakeArgs: []string{"make"}},
+		"short-flag word kj":    {makeflags: "kj --jobserver-auth=3,4", expectedMakeArgs: []string{"make"}},
+		"--jobserver-auth=3,4":  {makeflags: " --jobserver-auth=3,4", expectedMakeArgs: []string{"make"}},
+		"--jobserver-fds=3,4":   {makeflags: "--jobserver-fds=3,4", expectedMakeArgs: []string{"make"}},
+		"no jobs flag":          {makeflags: "-k", expectedMakeArgs: []string{"make", "-j4"}},
+		"short-flag word k":     {makeflags: "k -- CC=clang", expectedMakeArgs: []string{"make", "-j4"}},
+		"variable containing j": {makeflags: "-- OBJDIR=obj", expectedMakeArgs: []string{"make", "-j4"}},
+	}
+
+	installTestcases := map[string]func(*testing.T) installTC{}
+	for tcname := range testcases {
+		tc := testcases[tcname]
+		installTestcases["successfully install zip with MAKEFLAGS="+tcname] = func(t *testing.T) installTC {
+			installTC := initSuccessfullyInstallZipTC(t)
+			installTC.opts.Parallel = 4
+			installTC.backendOpts = []pecl.BackendOpt{
+				pecl.WithEnviron([]string{"MAKEFLAGS=" + tc.makeflags}),
+			}
+			installTC.cmdTester = cmdexec.ExpectCommandArgs(tc.expectedMakeArgs)
+			return installTC
+		}
+	}
+
+	runInstallTestcases(t, installTestcases)
+}
+
+func initSuccessfullyInstallZipOutOfTreeTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipTC(t)
+	tc.opts.BuildDir = "/tmp/builds/zip/php-7.4.3"
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/tmp/builds/zip/php-7.4.3", vfst.TestDoesNotExist),
+		vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
+	}
+	return tc
+}
+
+func newZipBuildTester() cmdexec.Tester {
+	return cmdexec.BuildTesters(
+		cmdexec.ExpectCommandArgs([]string{phpizePath}),
+		cmdexec.ExpectCommandArgs([]string{
+			"./configure",
+			"--with-php-config=" + phpconfigPath}),
+		cmdexec.ExpectCommandArgs([]string{"make"}),
+		cmdexec.ExpectCommandArgs([]string{
+			"make",
+			"INSTALL_ROOT=/installdir",
+			"install"}),
+		cmdexec.ExpectCommandArgs([]string{"make", "clean"}))
+}
+
+func initSuccessfullyInstallZipFromLocalArchiveTC(t *testing.T) installTC {
+	executor, recorder := cmdexec.NewTestExecutor()
+	executor = executor.With(
+		fakePHP("7.4.3"),
+	)
+
+	return installTC{
+		httpClient: newTestClient(newTestRoundTripper(t, map[string][]byte{})),
+		cmdExec:    executor,
+		recorder:   recorder,
+		cmdTester:  newZipBuildTester(),
+		opts: pecl.InstallOpts{
+			DownloadOpts: pecl.DownloadOpts{
+				DownloadDir: "/tmp",
+			},
+			Source:     "/src/zip-1.15.5-patched.tgz",
+			InstallDir: "/installdir",
+			Cleanup:    true,
+		},
+		files: map[string]interface{}{
+			"/src/zip-1.15.5-patched.tgz": loadRawTestdata(t, "testdata/zip-1.15.5.tgz"),
+		},
+		fsTests: []interface{}{
+			vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
+			vfst.TestPath("/src/zip-1.15.5-patched.tgz", vfst.TestModeIsRegular),
+		},
+	}
+}
+
+func initSuccessfullyInstallZipFromSourceDirTC(t *testing.T) installTC {
+	executor, recorder := cmdexec.NewTestExecutor()
+	executor = executor.With(
+		fakePHP("7.4.3"),
+	)
+
+	return installTC{
+		httpClient: newTestClient(newTestRoundTripper(t, map[string][]byte{})),
+		cmdExec:    executor,
+		recorder:   recorder,
+		cmdTester:  newZipBuildTester(),
+		opts: pecl.InstallOpts{
+			DownloadOpts: pecl.DownloadOpts{
+				Extension:   "zip",
+				DownloadDir: "/tmp",
+			},
+			Source:     "/src/zip",
+			InstallDir: "/installdir",
+			Cleanup:    true,
+		},
+		files: map[string]interface{}{
+			"/src/zip/package.xml": loadRawTestdata(t, "testdata/zip-package.xml"),
+			"/src/zip/config.m4":   "PHP_ARG_ENABLE(zip)",
+		},
+		fsTests: []interface{}{
+			vfst.TestPath("/src/zip/config.m4", vfst.TestModeIsRegular),
+		},
+	}
+}
+
+func initFailToInstallLocalArchiveOfAnotherPackageTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromLocalArchiveTC(t)
+	tc.opts.Extension = "redis"
+	tc.expectedErr = fmt.Errorf("failed to install /src/zip-1.15.5-patched.tgz: expected package redis, got zip")
+	return tc
+}
+
+func initFailToInstallLocalArchiveWithoutPackageXMLTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromLocalArchiveTC(t)
+	tc.files["/src/zip-1.15.5-patched.tgz"] = newTestArchive(t, []tarEntry{
+		{name: "zip-1.15.5/config.m4", typeflag: tar.TypeReg, mode: 0644, body: "PHP_ARG_ENABLE(zip)"},
+	})
+	tc.expectedErr = fmt.Errorf("failed to install /src/zip-1.15.5-patched.tgz: could not load /src/zip-1.15.5-patched.tgz: no package.xml found at the root of the archive")
+	return tc
+}
+
 func TestInstall(t *testing.T) {
 	testcases := map[string]func(*testing.T) installTC{
-		"successfully install zip v1.15.5":            initSuccessfullyInstallZipTC,
-		"successfully install redis v5.1.1 with args": initSuccessfullyInstallRedisWithArgsTC,
+		"successfully install zip v1.15.5":                         initSuccessfullyInstallZipTC,
+		"successfully install redis v5.1.1 with args":              initSuccessfullyInstallRedisWithArgsTC,
+		"successfully install redis v5.1.1 with configure options": initSuccessfullyInstallRedisWithConfigureOptionsTC,
+		"successfully install zip with parallel jobs":              initSuccessfullyInstallZipWithParallelJobsTC,
+		"successfully install zip with jobs set by MAKEFLAGS":      initSuccessfullyInstallZipWithJobsSetByMakeflagsTC,
+		"successfully install zip out of tree":                     initSuccessfullyInstallZipOutOfTreeTC,
+		"successfully install zip from a local archive":            initSuccessfullyInstallZipFromLocalArchiveTC,
+		"successfully install zip from a source dir":               initSuccessfullyInstallZipFromSourceDirTC,
+		"fail to install a local archive of another package":       initFailToInstallLocalArchiveOfAnotherPackageTC,
+		"fail to install a local archive without package.xml":      initFailToInstallLocalArchiveWithoutPackageXMLTC,
 	}
 
+	runInstallTestcases(t, testcases)
+}
+
+func runInstallTestcases(t *testing.T, testcases map[string]func(*testing.T) installTC) {
 	for tcname := range testcases {
 		tcinit := testcases[tcname]
 
@@ -341,24 +714,31 @@ func TestInstall(t *testing.T) {
 			t.Parallel()
 
 			tc := tcinit(t)
-			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+			files := map[string]interface{}{
 				tc.opts.DownloadDir: &vfst.Dir{Perm: 0750},
 				tc.opts.InstallDir:  &vfst.Dir{Perm: 0750},
-			})
+			}
+			for path, contents := range tc.files {
+				files[path] = contents
+			}
+			fs, cleanup, err := vfst.NewTestFS(files)
 			if err != nil {
 				t.Fatal(err)
 			}
 			defer cleanup()
 
-			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
+			client := peclapi.NewClient(
+				peclapi.WithHttpClient(tc.httpClient),
+				peclapi.WithFS(fs))
 
-			backend := pecl.New(
+			backend := pecl.New(append([]pecl.BackendOpt{
 				pecl.WithFS(fs),
 				pecl.WithClient(client),
 				pecl.WithCmdExec(tc.cmdExec),
-				pecl.WithPhpConfigPath(phpconfigPath))
+				pecl.WithPhpConfigPath(phpconfigPath),
+			}, tc.backendOpts...)...)
 
-			err = backend.Install(tc.opts)
+			err = backend.Install(context.Background(), tc.opts)
 			if tc.expectedErr != nil {
 				if err == nil || err.Error() != tc.expectedErr.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
@@ -370,6 +750,58 @@ func TestInstall(t *testing.T) {
 			}
 
 			tc.cmdTester(t, tc.recorder)
+			vfst.RunTests(t, fs, "installed files", tc.fsTests...)
+		})
+	}
+}
+
+type buildTC struct {
+	files       map[string]interface{}
+	opts        pecl.BuildOpts
+	cmdTester   cmdexec.Tester
+	fsTests     []interface{}
+	expectedErr error
+}
+
+func runBuildTestcases(t *testing.T, testcases map[string]func(*testing.T) buildTC) {
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+			files := map[string]interface{}{
+				tc.opts.InstallDir: &vfst.Dir{Perm: 0750},
+			}
+			for path, contents := range tc.files {
+				files[path] = contents
+			}
+			fs, cleanup, err := vfst.NewTestFS(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			executor, recorder := cmdexec.NewTestExecutor()
+			backend := pecl.New(
+				pecl.WithFS(fs),
+				pecl.WithCmdExec(executor.With(fakePHP("7.4.3"))),
+				pecl.WithPhpConfigPath(phpconfigPath))
+
+			err = backend.Build(context.Background(), tc.opts)
+			if tc.expectedErr != nil {
+				if err == nil || err.Error() != tc.expectedErr.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			tc.cmdTester(t, recorder)
+			vfst.RunTests(t, fs, "built files", tc.fsTests...)
 		})
 	}
 }
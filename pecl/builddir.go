@@ -0,0 +1,94 @@
+package pecl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+// Build artifacts (objects, built modules, etc...) left in source dirs by
+// in-tree builds. They're not copied to build dirs as they're specific to the
+// PHP version they were built for.
+var (
+	skippedBuildDirs     = []string{".git", ".libs", "autom4te.cache", "modules"}
+	skippedBuildFileExts = []string{".la", ".lo", ".o", ".so"}
+)
+
+// prepareBuildDir copies sourceDir to buildDir, such that phpize, configure
+// and make could run in buildDir without touching sourceDir. That's needed to
+// build the same source for several PHP versions, as phpize generates files
+// specific to the PHP version it belongs to. Any previous build in buildDir
+// is removed first, and build artifacts found in sourceDir are not copied.
+func (b backend) prepareBuildDir(sourceDir, buildDir string) error {
+	sourceDir = filepath.Clean(sourceDir)
+	buildDir = filepath.Clean(buildDir)
+	// The build dir is removed first, so it can't contain the source dir.
+	if isWithinDir(sourceDir, buildDir) {
+		return xerrors.Errorf("build dir %s can't contain the source dir %s", buildDir, sourceDir)
+	}
+
+	if err := b.fs.RemoveAll(buildDir); err != nil {
+		return err
+	}
+	if err := vfs.MkdirAll(b.fs, filepath.Dir(buildDir), 0750); err != nil {
+		return err
+	}
+
+	return vfs.Walk(b.fs, sourceDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// The build dir might live in the source dir (eg. ./builds/php-7.4.3),
+		// it shouldn't be copied into itself.
+		if path == buildDir {
+			return vfs.SkipDir
+		}
+
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(buildDir, rel)
+
+		switch {
+		case fi.IsDir() && path != sourceDir && containsString(skippedBuildDirs, fi.Name()):
+			return vfs.SkipDir
+		case containsString(skippedBuildFileExts, filepath.Ext(path)):
+			return nil
+		case fi.IsDir():
+			return b.fs.Mkdir(target, fi.Mode().Perm()|0700)
+		case fi.Mode()&os.ModeSymlink != 0:
+			linkname, err := b.fs.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return b.fs.Symlink(linkname, target)
+		case fi.Mode().IsRegular():
+			raw, err := b.fs.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return b.fs.WriteFile(target, raw, fi.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
+
+// isWithinDir returns true when path is dir or one of its descendants.
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package pecl_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+func initSuccessfullyBuildOutOfTreeTC(t *testing.T) buildTC {
+	return buildTC{
+		files: map[string]interface{}{
+			"/src/redis/config.m4": redisConfigM4,
+			"/src/redis/redis.c":   "",
+			"/src/redis/library.h": &vfst.Symlink{Target: "include/library.h"},
+			// The module built in tree shouldn't prevent the out-of-tree
+			// build from happening.
+			"/src/redis/modules/redis.so":      "ELF",
+			"/builds/php-7.4.3/stale-build.lo": "",
+		},
+		opts: pecl.BuildOpts{
+			SourceDir:  "/src/redis",
+			BuildDir:   "/builds/php-7.4.3",
+			InstallDir: "/installdir",
+		},
+		cmdTester: cmdexec.BuildTesters(
+			cmdexec.ExpectCommandArgs([]string{phpizePath}),
+			cmdexec.ExpectCommandArgs([]string{"make"})),
+		fsTests: []interface{}{
+			vfst.TestPath("/builds/php-7.4.3/config.m4", vfst.TestModeIsRegular, vfst.TestContentsString(redisConfigM4)),
+			vfst.TestPath("/builds/php-7.4.3/redis.c", vfst.TestModeIsRegular),
+			vfst.TestPath("/builds/php-7.4.3/library.h", vfst.TestModeType(os.ModeSymlink), vfst.TestSymlinkTarget("include/library.h")),
+			vfst.TestPath("/builds/php-7.4.3/stale-build.lo", vfst.TestDoesNotExist),
+			vfst.TestPath("/src/redis/config.m4", vfst.TestModeIsRegular),
+		},
+	}
+}
+
+func initSuccessfullyBuildOutOfTreeInSourceDirTC(t *testing.T) buildTC {
+	tc := initSuccessfullyBuildOutOfTreeTC(t)
+	tc.opts.BuildDir = "/src/redis/builds/php-7.4.3"
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/src/redis/builds/php-7.4.3/config.m4", vfst.TestModeIsRegular),
+		vfst.TestPath("/src/redis/builds/php-7.4.3/builds/php-7.4.3", vfst.TestDoesNotExist),
+	}
+	return tc
+}
+
+func initFailToBuildOutOfTreeWhenBuildDirContainsSourceDirTC(t *testing.T) buildTC {
+	tc := initSuccessfullyBuildOutOfTreeTC(t)
+	tc.opts.BuildDir = "/src"
+	tc.expectedErr = fmt.Errorf("failed to build redis: could not prepare build dir: build dir /src can't contain the source dir /src/redis")
+	return tc
+}
+
+func TestBuildOutOfTree(t *testing.T) {
+	testcases := map[string]func(*testing.T) buildTC{
+		"successfully build out of tree":                               initSuccessfullyBuildOutOfTreeTC,
+		"successfully build out of tree in a subdir of the source":     initSuccessfullyBuildOutOfTreeInSourceDirTC,
+		"fail to build out of tree when build dir contains the source": initFailToBuildOutOfTreeWhenBuildDirContainsSourceDirTC,
+	}
+
+	runBuildTestcases(t, testcases)
+}
@@ -0,0 +1,113 @@
+package pecl
+
+import (
+	"sort"
+	"strings"
+)
+
+// Default compiler flags used when building extensions. They're the same as
+// the ones used to build PHP in the official Docker images, and they could be
+// overridden through the PHP_CFLAGS, PHP_CPPFLAGS and PHP_LDFLAGS env vars or
+// through BuildOpts.
+const (
+	DefaultCFLAGS   = "-fstack-protector-strong -fpic -fpie -O2 -D_LARGEFILE_SOURCE -D_FILE_OFFSET_BITS=64"
+	DefaultCPPFLAGS = "-fstack-protector-strong -fpic -fpie -O2 -D_LARGEFILE_SOURCE -D_FILE_OFFSET_BITS=64"
+	DefaultLDFLAGS  = "-Wl,-O1 -Wl,--hash-style=both -pie"
+)
+
+// PassthroughEnv lists the environment variables passed as is to the build
+// steps, when they're set. Other environment variables are not passed to
+// avoid leaking unrelated settings into builds.
+var PassthroughEnv = []string{
+	"PATH",
+	"HOME",
+	"TMPDIR",
+	"LANG",
+	"LC_ALL",
+	"CC",
+	"CXX",
+	"CPP",
+	"CXXCPP",
+	"CXXFLAGS",
+	"LD",
+	"AR",
+	"NM",
+	"RANLIB",
+	"STRIP",
+	"PKG_CONFIG",
+	"PKG_CONFIG_PATH",
+	"PKG_CONFIG_LIBDIR",
+	"CCACHE_DIR",
+	"MAKEFLAGS",
+}
+
+// compilerFlags maps the compiler flags set for builds to the env var that
+// could be used to override their default value.
+var compilerFlags = []struct {
+	name       string
+	envVar     string
+	defaultVal string
+}{
+	{"CFLAGS", "PHP_CFLAGS", DefaultCFLAGS},
+	{"CPPFLAGS", "PHP_CPPFLAGS", DefaultCPPFLAGS},
+	{"LDFLAGS", "PHP_LDFLAGS", DefaultLDFLAGS},
+}
+
+// BuildEnv returns the environment variables, in the format key=value and
+// sorted by key, phpize, configure and make are run with when building with
+// the given BuildOpts. It contains:
+//
+//   - CFLAGS, CPPFLAGS and LDFLAGS: BuildOpts.CFlags, BuildOpts.CPPFlags and
+//     BuildOpts.LDFlags when set, or the value of PHP_CFLAGS, PHP_CPPFLAGS and
+//     PHP_LDFLAGS when they're set, or DefaultCFLAGS, DefaultCPPFLAGS and
+//     DefaultLDFLAGS otherwise;
+//   - the environment variables listed in PassthroughEnv that are set.
+func (b backend) BuildEnv(opts BuildOpts) []string {
+	env := map[string]string{}
+	for _, name := range PassthroughEnv {
+		if val, ok := b.lookupEnv(name); ok {
+			env[name] = val
+		}
+	}
+
+	overrides := map[string]string{
+		"CFLAGS":   opts.CFlags,
+		"CPPFLAGS": opts.CPPFlags,
+		"LDFLAGS":  opts.LDFlags,
+	}
+	for _, flag := range compilerFlags {
+		if val := overrides[flag.name]; val != "" {
+			env[flag.name] = val
+		} else if val, ok := b.lookupEnv(flag.envVar); ok {
+			env[flag.name] = val
+		} else {
+			env[flag.name] = flag.defaultVal
+		}
+	}
+
+	environ := make([]string, 0, len(env))
+	for name, val := range env {
+		environ = append(environ, name+"="+val)
+	}
+	sort.Strings(environ)
+
+	return environ
+}
+
+// lookupEnv returns the value of the given environment variable, as seen by
+// the backend (see WithEnviron), and whether it's set.
+func (b backend) lookupEnv(name string) (string, bool) {
+	for i := len(b.environ) - 1; i >= 0; i-- {
+		if strings.HasPrefix(b.environ[i], name+"=") {
+			return strings.TrimPrefix(b.environ[i], name+"="), true
+		}
+	}
+	return "", false
+}
+
+// getenv returns the value of the given environment variable, as seen by the
+// backend, or an empty string when it's not set.
+func (b backend) getenv(name string) string {
+	val, _ := b.lookupEnv(name)
+	return val
+}
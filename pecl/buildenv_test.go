@@ -0,0 +1,84 @@
+package pecl_test
+
+import (
+	"testing"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/go-test/deep"
+)
+
+type buildEnvTC struct {
+	environ  []string
+	opts     pecl.BuildOpts
+	expected []string
+}
+
+func TestBuildEnv(t *testing.T) {
+	testcases := map[string]buildEnvTC{
+		"use default compiler flags when PHP_*FLAGS are not set": {
+			environ: []string{"PATH=/usr/bin"},
+			expected: []string{
+				"CFLAGS=" + pecl.DefaultCFLAGS,
+				"CPPFLAGS=" + pecl.DefaultCPPFLAGS,
+				"LDFLAGS=" + pecl.DefaultLDFLAGS,
+				"PATH=/usr/bin",
+			},
+		},
+		"use compiler flags from PHP_*FLAGS env vars": {
+			environ: []string{
+				"PHP_CFLAGS=-O3",
+				"PHP_CPPFLAGS=-DNDEBUG",
+				"PHP_LDFLAGS=",
+			},
+			expected: []string{
+				"CFLAGS=-O3",
+				"CPPFLAGS=-DNDEBUG",
+				"LDFLAGS=",
+			},
+		},
+		"compiler flags from build opts take precedence": {
+			environ: []string{"PHP_CFLAGS=-O3"},
+			opts: pecl.BuildOpts{
+				CFlags:  "-O0 -g",
+				LDFlags: "-s",
+			},
+			expected: []string{
+				"CFLAGS=-O0 -g",
+				"CPPFLAGS=" + pecl.DefaultCPPFLAGS,
+				"LDFLAGS=-s",
+			},
+		},
+		"pass through toolchain env vars and drop unrelated ones": {
+			environ: []string{
+				"CC=clang",
+				"CXX=clang++",
+				"PKG_CONFIG_PATH=/opt/libzip/lib/pkgconfig",
+				"AWS_SECRET_ACCESS_KEY=secret",
+				"CC=gcc",
+			},
+			expected: []string{
+				"CC=gcc",
+				"CFLAGS=" + pecl.DefaultCFLAGS,
+				"CPPFLAGS=" + pecl.DefaultCPPFLAGS,
+				"CXX=clang++",
+				"LDFLAGS=" + pecl.DefaultLDFLAGS,
+				"PKG_CONFIG_PATH=/opt/libzip/lib/pkgconfig",
+			},
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			backend := pecl.New(pecl.WithEnviron(tc.environ))
+			env := backend.BuildEnv(tc.opts)
+
+			if diff := deep.Equal(env, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package pecl
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/NiR-/notpecl/peclpkg"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+// phpNewExtensionRegexp matches the PHP_NEW_EXTENSION() macro call declaring
+// the extension in a config.m4 file. The extension name might be quoted
+// with m4 brackets (eg. PHP_NEW_EXTENSION([redis], ...)).
+var phpNewExtensionRegexp = regexp.MustCompile(`PHP_NEW_EXTENSION\(\s*\[?\s*([A-Za-z][A-Za-z0-9_]*)\s*\]?\s*,`)
+
+// packageFromConfigM4 builds a Package for the extension in sourceDir when it
+// has no package.xml. Only the name of the extension is known, and it's
+// inferred from the PHP_NEW_EXTENSION() call in its config.m4.
+func (b backend) packageFromConfigM4(sourceDir string) (peclpkg.Package, error) {
+	configPath := filepath.Join(sourceDir, "config.m4")
+	raw, err := b.fs.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return peclpkg.Package{}, xerrors.Errorf("no package.xml provided and no config.m4 found in %s", sourceDir)
+	} else if err != nil {
+		return peclpkg.Package{}, xerrors.Errorf("could not read %s: %w", configPath, err)
+	}
+
+	name, err := extensionNameFromConfigM4(raw)
+	if err != nil {
+		return peclpkg.Package{}, xerrors.Errorf("could not infer the extension name from %s: %w", configPath, err)
+	}
+
+	return peclpkg.Package{Name: name}, nil
+}
+
+// extensionNameFromConfigM4 returns the name of the extension declared by the
+// first PHP_NEW_EXTENSION() call of a config.m4 file. Comments are ignored.
+func extensionNameFromConfigM4(raw []byte) (string, error) {
+	var code strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "dnl") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		code.WriteString(line)
+		code.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	matches := phpNewExtensionRegexp.FindStringSubmatch(code.String())
+	if matches == nil {
+		return "", xerrors.Errorf("no PHP_NEW_EXTENSION() call found")
+	}
+	return matches[1], nil
+}
+
+// hasConfigM4 returns true when there's a config.m4 file in sourceDir.
+func hasConfigM4(fs vfs.FS, sourceDir string) bool {
+	fi, err := fs.Stat(filepath.Join(sourceDir, "config.m4"))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// describePackage returns the name of the package along with its version,
+// when it's known.
+func describePackage(pkg peclpkg.Package) string {
+	if pkg.Version.Release == "" {
+		return pkg.Name
+	}
+	return pkg.Name + " v" + pkg.Version.Release
+}
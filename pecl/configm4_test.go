@@ -0,0 +1,92 @@
+package pecl_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/NiR-/notpecl/pecl"
+)
+
+const redisConfigM4 = `dnl config.m4 for extension redis
+dnl PHP_NEW_EXTENSION(commented, commented.c, $ext_shared)
+
+PHP_ARG_ENABLE(redis, whether to enable redis support,
+[  --enable-redis          Enable redis support])
+
+if test "$PHP_REDIS" != "no"; then
+  PHP_NEW_EXTENSION([redis], redis.c library.c, $ext_shared)
+fi
+`
+
+func initSuccessfullyBuildFromConfigM4TC(t *testing.T) buildTC {
+	return buildTC{
+		files: map[string]interface{}{
+			"/src/redis/config.m4": redisConfigM4,
+		},
+		opts: pecl.BuildOpts{
+			SourceDir:     "/src/redis",
+			InstallDir:    "/installdir",
+			ConfigureArgs: []string{"--enable-redis"},
+		},
+		cmdTester: cmdexec.BuildTesters(
+			cmdexec.ExpectCommandArgs([]string{phpizePath}),
+			cmdexec.ExpectCommandArgs([]string{
+				"./configure",
+				"--enable-redis",
+				"--with-php-config=" + phpconfigPath}),
+			cmdexec.ExpectCommandArgs([]string{"make"}),
+			cmdexec.ExpectCommandArgs([]string{
+				"make",
+				"INSTALL_ROOT=/installdir",
+				"install"})),
+	}
+}
+
+func initSuccessfullyInstallModuleNamedAfterConfigM4TC(t *testing.T) buildTC {
+	tc := initSuccessfullyBuildFromConfigM4TC(t)
+	// As the module has already been built, only make install is executed.
+	tc.files["/src/redis/modules/redis.so"] = "ELF"
+	tc.cmdTester = cmdexec.ExpectCommandArgs([]string{
+		"make",
+		"INSTALL_ROOT=/installdir",
+		"install"})
+	return tc
+}
+
+func initFailToBuildWhenConfigM4DoesNotDeclareAnExtensionTC(t *testing.T) buildTC {
+	return buildTC{
+		files: map[string]interface{}{
+			"/src/redis/config.m4": "dnl PHP_NEW_EXTENSION(redis, redis.c, $ext_shared)\n",
+		},
+		opts: pecl.BuildOpts{
+			SourceDir:  "/src/redis",
+			InstallDir: "/installdir",
+		},
+		expectedErr: fmt.Errorf("failed to build package: could not infer the extension name from /src/redis/config.m4: no PHP_NEW_EXTENSION() call found"),
+	}
+}
+
+func initFailToBuildWithoutPackageXMLNorConfigM4TC(t *testing.T) buildTC {
+	return buildTC{
+		files: map[string]interface{}{
+			"/src/redis/redis.c": "",
+		},
+		opts: pecl.BuildOpts{
+			SourceDir:  "/src/redis",
+			InstallDir: "/installdir",
+		},
+		expectedErr: fmt.Errorf("failed to build package: no package.xml provided and no config.m4 found in /src/redis"),
+	}
+}
+
+func TestBuildWithoutPackageXML(t *testing.T) {
+	testcases := map[string]func(*testing.T) buildTC{
+		"successfully build from config.m4":                          initSuccessfullyBuildFromConfigM4TC,
+		"successfully install module named after config.m4":          initSuccessfullyInstallModuleNamedAfterConfigM4TC,
+		"fail to build when config.m4 does not declare an extension": initFailToBuildWhenConfigM4DoesNotDeclareAnExtensionTC,
+		"fail to build without package.xml nor config.m4":            initFailToBuildWithoutPackageXMLNorConfigM4TC,
+	}
+
+	runBuildTestcases(t, testcases)
+}
@@ -0,0 +1,213 @@
+package pecl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/sirupsen/logrus"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+// DefaultIniPriority is the priority of the ini files written by Enable, when
+// none is provided.
+const DefaultIniPriority = 20
+
+type EnableOpts struct {
+	// Extension is the name of the extension to enable.
+	Extension string
+	// InstallDir is the directory where the extension was installed (see
+	// InstallOpts). Both the module and the scan dir of the PHP installation
+	// are looked up in it.
+	InstallDir string
+	// IniDir is the directory where the ini file is written. It defaults to
+	// the dir scanned by PHP for additional ini files (as reported by
+	// php-config --ini-dir, or php --ini).
+	IniDir string
+	// Priority is used as a prefix of the ini file name (eg. 20-redis.ini),
+	// to control the order in which PHP loads extensions. It should be
+	// between 0 and 99.
+	Priority int
+	// Directives are extra ini directives written after the line loading the
+	// extension (eg. redis.session.locking_enabled=1).
+	Directives map[string]string
+}
+
+// Enable writes an ini file loading the given extension to the scan dir of
+// the PHP installation. Zend extensions (eg. xdebug or opcache) are loaded
+// with zend_extension=, others with extension=. It's idempotent: the ini file
+// isn't touched when it's already up-to-date, and ini files written for the
+// same extension with another priority are removed.
+func (b backend) Enable(ctx context.Context, opts EnableOpts) error {
+	// The name ends up in the path of the ini file and in the glob pattern
+	// matching the stale ones, such that names like * or ../x would remove or
+	// write files they shouldn't.
+	if !extensionNameRegexp.MatchString(opts.Extension) {
+		return xerrors.Errorf("failed to enable %q: invalid extension name", opts.Extension)
+	}
+	if opts.Priority < 0 || opts.Priority > 99 {
+		return xerrors.Errorf("failed to enable %s: priority %d is not between 0 and 99", opts.Extension, opts.Priority)
+	}
+
+	php, err := b.resolvePhpInstallation(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to enable %s: %w", opts.Extension, err)
+	}
+
+	extDir, err := b.queryPhpConfig(ctx, php.phpConfig, "--extension-dir")
+	if err != nil {
+		return xerrors.Errorf("failed to enable %s: %w", opts.Extension, err)
+	}
+	modulePath := filepath.Join(opts.InstallDir, extDir, opts.Extension+".so")
+	isZendExt, err := b.isZendExtension(modulePath)
+	if err != nil {
+		return xerrors.Errorf("failed to enable %s: %w", opts.Extension, err)
+	}
+
+	iniDir := opts.IniDir
+	if iniDir == "" {
+		scanDir, err := b.findIniScanDir(ctx, php)
+		if err != nil {
+			return xerrors.Errorf("failed to enable %s: %w", opts.Extension, err)
+		}
+		iniDir = filepath.Join(opts.InstallDir, scanDir)
+	}
+
+	iniPath := filepath.Join(iniDir, fmt.Sprintf("%02d-%s.ini", opts.Priority, opts.Extension))
+	contents := iniFileContents(opts.Extension, isZendExt, opts.Directives)
+
+	if err := b.removeStaleIniFiles(iniDir, opts.Extension, iniPath); err != nil {
+		return xerrors.Errorf("failed to enable %s: %w", opts.Extension, err)
+	}
+
+	if current, err := b.fs.ReadFile(iniPath); err == nil && bytes.Equal(current, contents) {
+		logrus.Infof("%s is already enabled by %s.", opts.Extension, iniPath)
+		return nil
+	}
+
+	if err := vfs.MkdirAll(b.fs, iniDir, 0755); err != nil {
+		return xerrors.Errorf("failed to enable %s: %w", opts.Extension, err)
+	}
+	if err := b.fs.WriteFile(iniPath, contents, 0644); err != nil {
+		return xerrors.Errorf("failed to enable %s: %w", opts.Extension, err)
+	}
+	logrus.Infof("Enabled %s in %s.", opts.Extension, iniPath)
+
+	return nil
+}
+
+// iniFileContents returns the contents of the ini file enabling the given
+// extension. Directives are sorted by name to produce the same file for the
+// same directives.
+func iniFileContents(extension string, isZendExt bool, directives map[string]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("; Written by notpecl\n")
+
+	if isZendExt {
+		fmt.Fprintf(&buf, "zend_extension=%s.so\n", extension)
+	} else {
+		fmt.Fprintf(&buf, "extension=%s.so\n", extension)
+	}
+
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s=%s\n", name, directives[name])
+	}
+
+	return buf.Bytes()
+}
+
+// removeStaleIniFiles removes the ini files enabling the given extension with
+// another priority than the one of iniPath, such that the extension isn't
+// loaded twice.
+func (b backend) removeStaleIniFiles(iniDir, extension, iniPath string) error {
+	matches, err := b.fs.Glob(filepath.Join(iniDir, "[0-9][0-9]-"+extension+".ini"))
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		if match == iniPath {
+			continue
+		}
+		logrus.Infof("Removing %s as %s is now enabled by %s.", match, extension, iniPath)
+		if err := b.fs.Remove(match); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isZendExtension returns true when the module at the given path is a Zend
+// extension. Like docker-php-ext-enable, it looks for the zend_extension_entry
+// symbol exported by such extensions.
+func (b backend) isZendExtension(modulePath string) (bool, error) {
+	f, err := b.fs.Open(modulePath)
+	if os.IsNotExist(err) {
+		return false, xerrors.Errorf("module %s not found, is the extension installed?", modulePath)
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	module, err := elf.NewFile(f)
+	if err != nil {
+		return false, xerrors.Errorf("could not read module %s: %w", modulePath, err)
+	}
+	symbols, err := module.DynamicSymbols()
+	if err != nil {
+		return false, xerrors.Errorf("could not read symbols of module %s: %w", modulePath, err)
+	}
+
+	for _, sym := range symbols {
+		if sym.Name == "zend_extension_entry" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findIniScanDir returns the dir scanned by PHP for additional ini files. It's
+// first asked to php-config, then to php as php-config --ini-dir is empty when
+// PHP was compiled without a default scan dir.
+func (b backend) findIniScanDir(ctx context.Context, php phpInstallation) (string, error) {
+	if dir, err := b.queryPhpConfig(ctx, php.phpConfig, "--ini-dir"); err == nil && dir != "" {
+		return dir, nil
+	}
+
+	var outbuf bytes.Buffer
+	cmdexec := b.cmdexec.With(cmdexec.Stdout(&outbuf))
+	if err := cmdexec.Run(ctx, php.binary, "--ini"); err != nil {
+		return "", xerrors.Errorf("failed to run %s --ini: %w", php.binary, err)
+	}
+
+	const prefix = "Scan for additional .ini files in:"
+	scanner := bufio.NewScanner(&outbuf)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		// PHP_INI_SCAN_DIR might list several dirs, the ini file is written
+		// to the last one.
+		dirs := filepath.SplitList(strings.TrimSpace(strings.TrimPrefix(line, prefix)))
+		if len(dirs) > 0 && dirs[len(dirs)-1] != "" && dirs[len(dirs)-1] != "(none)" {
+			return dirs[len(dirs)-1], nil
+		}
+	}
+
+	return "", xerrors.Errorf("%s doesn't scan any dir for additional ini files", php.binary)
+}
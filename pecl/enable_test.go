@@ -0,0 +1,208 @@
+package pecl_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+const phpExtensionDir = "/usr/lib/php/20190902"
+
+type enableTC struct {
+	files       map[string]interface{}
+	cmdExec     cmdexec.CmdExecutor
+	opts        pecl.EnableOpts
+	fsTests     []interface{}
+	expectedErr error
+}
+
+func newEnableExecutor(iniDir string) cmdexec.CmdExecutor {
+	executor, _ := cmdexec.NewTestExecutor()
+	return executor.With(
+		fakePHP("7.4.3"),
+		cmdexec.FakeOn([]string{phpconfigPath, "--extension-dir"},
+			cmdexec.FakeStdout(phpExtensionDir+"\n")),
+		cmdexec.FakeOn([]string{phpconfigPath, "--ini-dir"},
+			cmdexec.FakeStdout(iniDir+"\n")))
+}
+
+func initSuccessfullyEnableRedisTC(t *testing.T) enableTC {
+	return enableTC{
+		files: map[string]interface{}{
+			phpExtensionDir + "/redis.so": loadRawTestdata(t, "testdata/extension.so"),
+		},
+		cmdExec: newEnableExecutor("/etc/php/conf.d"),
+		opts: pecl.EnableOpts{
+			Extension: "redis",
+			Priority:  pecl.DefaultIniPriority,
+		},
+		fsTests: []interface{}{
+			vfst.TestPath("/etc/php/conf.d/20-redis.ini",
+				vfst.TestModeIsRegular,
+				vfst.TestContentsString("; Written by notpecl\nextension=redis.so\n")),
+		},
+	}
+}
+
+func initSuccessfullyEnableZendExtensionInInstallDirTC(t *testing.T) enableTC {
+	return enableTC{
+		files: map[string]interface{}{
+			"/installdir" + phpExtensionDir + "/xdebug.so": loadRawTestdata(t, "testdata/zend-extension.so"),
+		},
+		cmdExec: newEnableExecutor("/etc/php/conf.d"),
+		opts: pecl.EnableOpts{
+			Extension:  "xdebug",
+			InstallDir: "/installdir",
+			Priority:   5,
+			Directives: map[string]string{
+				"xdebug.mode":        "debug",
+				"xdebug.client_host": "host.docker.internal",
+			},
+		},
+		fsTests: []interface{}{
+			vfst.TestPath("/installdir/etc/php/conf.d/05-xdebug.ini",
+				vfst.TestModeIsRegular,
+				vfst.TestContentsString("; Written by notpecl\nzend_extension=xdebug.so\nxdebug.client_host=host.docker.internal\nxdebug.mode=debug\n")),
+		},
+	}
+}
+
+func initSuccessfullyEnableRedisAgainWithAnotherPriorityTC(t *testing.T) enableTC {
+	tc := initSuccessfullyEnableRedisTC(t)
+	tc.files["/etc/php/conf.d/20-redis.ini"] = "; Written by notpecl\nextension=redis.so\n"
+	tc.files["/etc/php/conf.d/20-redis-sessions.ini"] = "redis.session.locking_enabled=1\n"
+	tc.opts.Priority = 30
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/etc/php/conf.d/20-redis.ini", vfst.TestDoesNotExist),
+		vfst.TestPath("/etc/php/conf.d/20-redis-sessions.ini", vfst.TestModeIsRegular),
+		vfst.TestPath("/etc/php/conf.d/30-redis.ini",
+			vfst.TestContentsString("; Written by notpecl\nextension=redis.so\n")),
+	}
+	return tc
+}
+
+func initSuccessfullyEnableRedisInScanDirReportedByPhpTC(t *testing.T) enableTC {
+	tc := initSuccessfullyEnableRedisTC(t)
+	tc.cmdExec = newEnableExecutor("").With(
+		cmdexec.FakeOn([]string{"php", "--ini"},
+			cmdexec.FakeStdout("Configuration File (php.ini) Path: /usr/local/etc/php\n"+
+				"Loaded Configuration File:         (none)\n"+
+				"Scan for additional .ini files in: /usr/local/etc/php/conf.d\n")))
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/usr/local/etc/php/conf.d/20-redis.ini", vfst.TestModeIsRegular),
+	}
+	return tc
+}
+
+func initSuccessfullyEnableRedisInGivenIniDirTC(t *testing.T) enableTC {
+	tc := initSuccessfullyEnableRedisTC(t)
+	tc.opts.IniDir = "/opt/php/conf.d"
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/opt/php/conf.d/20-redis.ini", vfst.TestModeIsRegular),
+		vfst.TestPath("/etc/php/conf.d", vfst.TestDoesNotExist),
+	}
+	return tc
+}
+
+func initFailToEnableExtensionNotInstalledTC(t *testing.T) enableTC {
+	tc := initSuccessfullyEnableRedisTC(t)
+	tc.opts.Extension = "apcu"
+	tc.expectedErr = fmt.Errorf("failed to enable apcu: module %s/apcu.so not found, is the extension installed?", phpExtensionDir)
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/etc/php/conf.d", vfst.TestDoesNotExist),
+	}
+	return tc
+}
+
+func initFailToEnableWithAnInvalidPriorityTC(t *testing.T) enableTC {
+	tc := initSuccessfullyEnableRedisTC(t)
+	tc.opts.Priority = 100
+	tc.expectedErr = fmt.Errorf("failed to enable redis: priority 100 is not between 0 and 99")
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/etc/php/conf.d", vfst.TestDoesNotExist),
+	}
+	return tc
+}
+
+func initFailToEnableAnExtensionNamedWithAGlobTC(t *testing.T) enableTC {
+	return enableTC{
+		files: map[string]interface{}{
+			phpExtensionDir + "/*.so":      loadRawTestdata(t, "testdata/extension.so"),
+			"/etc/php/conf.d/20-redis.ini": "; Written by notpecl\nextension=redis.so\n",
+		},
+		cmdExec: newEnableExecutor("/etc/php/conf.d"),
+		opts: pecl.EnableOpts{
+			Extension: "*",
+			Priority:  pecl.DefaultIniPriority,
+		},
+		expectedErr: fmt.Errorf("failed to enable \"*\": invalid extension name"),
+		fsTests: []interface{}{
+			vfst.TestPath("/etc/php/conf.d/20-redis.ini", vfst.TestModeIsRegular),
+			vfst.TestPath("/etc/php/conf.d/20-*.ini", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToEnableAnExtensionNamedWithDotDotTC(t *testing.T) enableTC {
+	tc := initSuccessfullyEnableRedisTC(t)
+	tc.files["/usr/lib/php/x.so"] = loadRawTestdata(t, "testdata/extension.so")
+	tc.opts.Extension = "../x"
+	tc.expectedErr = fmt.Errorf("failed to enable \"../x\": invalid extension name")
+	tc.fsTests = []interface{}{
+		vfst.TestPath("/etc/php/x.ini", vfst.TestDoesNotExist),
+		vfst.TestPath("/etc/php/conf.d/20-..", vfst.TestDoesNotExist),
+	}
+	return tc
+}
+
+func TestEnable(t *testing.T) {
+	testcases := map[string]func(*testing.T) enableTC{
+		"successfully enable redis":                                 initSuccessfullyEnableRedisTC,
+		"successfully enable a zend extension in install dir":       initSuccessfullyEnableZendExtensionInInstallDirTC,
+		"successfully enable redis again with another priority":     initSuccessfullyEnableRedisAgainWithAnotherPriorityTC,
+		"successfully enable redis in the scan dir reported by php": initSuccessfullyEnableRedisInScanDirReportedByPhpTC,
+		"successfully enable redis in the given ini dir":            initSuccessfullyEnableRedisInGivenIniDirTC,
+		"fail to enable an extension that is not installed":         initFailToEnableExtensionNotInstalledTC,
+		"fail to enable with an invalid priority":                   initFailToEnableWithAnInvalidPriorityTC,
+		"fail to enable an extension named with a glob":             initFailToEnableAnExtensionNamedWithAGlobTC,
+		"fail to enable an extension named with ..":                 initFailToEnableAnExtensionNamedWithDotDotTC,
+	}
+
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+			fs, cleanup, err := vfst.NewTestFS(tc.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			backend := pecl.New(
+				pecl.WithFS(fs),
+				pecl.WithCmdExec(tc.cmdExec),
+				pecl.WithPhpConfigPath(phpconfigPath))
+
+			err = backend.Enable(context.Background(), tc.opts)
+			if tc.expectedErr != nil {
+				if err == nil || err.Error() != tc.expectedErr.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				vfst.RunTests(t, fs, "preserved files", tc.fsTests...)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			vfst.RunTests(t, fs, "ini files", tc.fsTests...)
+		})
+	}
+}
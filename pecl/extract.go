@@ -0,0 +1,353 @@
+package pecl
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+// ExtractLimits bounds the size of the archives extracted by Download.
+type ExtractLimits struct {
+	// MaxFileSize is the maximum size of a single file, in bytes.
+	MaxFileSize int64
+	// MaxTotalSize is the maximum size of all the files of an archive, in
+	// bytes.
+	MaxTotalSize int64
+	// MaxEntries is the maximum number of entries in an archive.
+	MaxEntries int
+}
+
+// DefaultExtractLimits are the ExtractLimits used unless WithExtractLimits()
+// is passed to New(). They're far above the size of any extension published
+// on pecl.php.net.
+var DefaultExtractLimits = ExtractLimits{
+	MaxFileSize:  64 << 20,
+	MaxTotalSize: 512 << 20,
+	MaxEntries:   20000,
+}
+
+// WithExtractLimits returns a BackendOpt that could be used with New() to
+// change the limits enforced when extracting archives.
+func WithExtractLimits(limits ExtractLimits) BackendOpt {
+	return func(b *backend) {
+		b.extractLimits = limits
+	}
+}
+
+const extractBufferSize = 32 * 1024
+
+// extractor extracts a tgz archive to a destination dir. Entries of the
+// archive are placed relative to the destination dir, after stripping
+// dirPrefix. Entries that would end up outside of the destination dir are
+// rejected. Symlinks are only extracted when they point inside the
+// destination dir, and they're never followed when extracting the
+// subsequent entries. Hard links are extracted as copies.
+type extractor struct {
+	fs      vfs.FS
+	destDir string
+	prefix  string
+	limits  ExtractLimits
+	buf     []byte
+	total   int64
+	entries int
+}
+
+func (b backend) extract(ctx context.Context, extDir, dirPrefix string, r io.Reader) error {
+	gzipr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzipr.Close()
+
+	if err := vfs.MkdirAll(b.fs, extDir, 0750); err != nil {
+		return err
+	}
+
+	e := extractor{
+		fs:      b.fs,
+		destDir: filepath.Clean(extDir),
+		prefix:  dirPrefix,
+		limits:  b.extractLimits,
+		buf:     make([]byte, extractBufferSize),
+	}
+	tarr := tar.NewReader(gzipr)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		headers, err := tarr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := e.extractEntry(tarr, headers); err != nil {
+			return xerrors.Errorf("could not extract %s: %w", headers.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (e *extractor) extractEntry(tarr *tar.Reader, headers *tar.Header) error {
+	e.entries++
+	if e.limits.MaxEntries > 0 && e.entries > e.limits.MaxEntries {
+		return xerrors.Errorf("archive has more than %d entries", e.limits.MaxEntries)
+	}
+
+	target, err := e.targetPath(headers.Name)
+	if err != nil {
+		return err
+	}
+	if target == e.destDir {
+		return nil
+	}
+	if err := e.checkParents(target); err != nil {
+		return err
+	}
+
+	switch headers.Typeflag {
+	case tar.TypeDir:
+		return e.extractDir(target, headers)
+	case tar.TypeReg, tar.TypeRegA:
+		return e.extractFile(target, headers, tarr)
+	case tar.TypeSymlink:
+		return e.extractSymlink(target, headers)
+	case tar.TypeLink:
+		return e.extractHardLink(target, headers)
+	default:
+		logrus.Debugf("Skipping %s: unsupported entry type %q.", headers.Name, headers.Typeflag)
+		return nil
+	}
+}
+
+// targetPath returns the path where the entry with the given name should be
+// extracted. An error is returned when it'd end up outside of destDir.
+func (e *extractor) targetPath(name string) (string, error) {
+	name = strings.TrimPrefix(name, e.prefix)
+	if name != "" && name == strings.TrimSuffix(e.prefix, "/") {
+		name = ""
+	}
+	return joinWithinDir(e.destDir, name)
+}
+
+func (e *extractor) isInDestDir(path string) bool {
+	return isInDir(path, e.destDir)
+}
+
+// joinWithinDir joins the slash-separated name to dir. An error is returned
+// when name is absolute or when the resulting path is outside of dir.
+func joinWithinDir(dir, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", xerrors.Errorf("absolute paths are not allowed")
+	}
+
+	dir = filepath.Clean(dir)
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if !isInDir(target, dir) {
+		return "", xerrors.Errorf("path escapes the extraction dir")
+	}
+	return target, nil
+}
+
+// isInDir returns true when the cleaned path is dir or is below it.
+func isInDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// checkParents ensures none of the parent dirs of target, below destDir, is a
+// symlink, such that entries can't be written through a symlink.
+func (e *extractor) checkParents(target string) error {
+	for dir := filepath.Dir(target); dir != e.destDir && e.isInDestDir(dir); dir = filepath.Dir(dir) {
+		fi, err := e.fs.Lstat(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return xerrors.Errorf("parent dir %s is a symlink", dir)
+		}
+	}
+	return nil
+}
+
+// removeExisting removes any file or symlink at path, such that extracted
+// entries never write through a previously extracted symlink.
+func (e *extractor) removeExisting(path string) error {
+	fi, err := e.fs.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return xerrors.Errorf("a directory already exists at this path")
+	}
+	return e.fs.Remove(path)
+}
+
+func (e *extractor) extractDir(target string, headers *tar.Header) error {
+	if err := vfs.MkdirAll(e.fs, target, 0750); err != nil {
+		return err
+	}
+	return e.fs.Chmod(target, dirMode(headers.Mode))
+}
+
+func (e *extractor) extractFile(target string, headers *tar.Header, r io.Reader) error {
+	if err := e.reserve(headers.Size); err != nil {
+		return err
+	}
+	if err := vfs.MkdirAll(e.fs, filepath.Dir(target), 0750); err != nil {
+		return err
+	}
+	if err := e.removeExisting(target); err != nil {
+		return err
+	}
+
+	logrus.Debugf("Unpacking %s (%d bytes)...", headers.Name, headers.Size)
+	return e.writeFile(target, fileMode(headers.Mode), io.LimitReader(r, headers.Size), headers.Size)
+}
+
+func (e *extractor) writeFile(target string, mode os.FileMode, r io.Reader, size int64) error {
+	f, err := e.fs.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	written, err := io.CopyBuffer(f, r, e.buf)
+	if err != nil {
+		return xerrors.Errorf("failed to write extracted file: %w", err)
+	}
+	if written != size {
+		return xerrors.Errorf("file is %d bytes long, but only %d read from tar archive", size, written)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// The mode passed to OpenFile is subject to the umask.
+	return e.fs.Chmod(target, mode)
+}
+
+func (e *extractor) extractSymlink(target string, headers *tar.Header) error {
+	if filepath.IsAbs(headers.Linkname) || strings.HasPrefix(headers.Linkname, "/") {
+		return xerrors.Errorf("symlink to absolute path %s is not allowed", headers.Linkname)
+	}
+
+	// The link target is resolved component by component, such that links
+	// going through another symlink (which could point anywhere once
+	// combined with ..) are rejected. Components followed by .. have to be
+	// dirs already extracted, otherwise a subsequent entry could turn them
+	// into symlinks (eg. l -> d/../../x extracted before d -> .).
+	resolved := filepath.Dir(target)
+	depth := 0
+	for _, component := range strings.Split(filepath.ToSlash(headers.Linkname), "/") {
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			if depth > 0 {
+				if fi, err := e.fs.Lstat(resolved); err != nil || !fi.IsDir() {
+					return xerrors.Errorf("symlink to %s goes through %s, which is not an extracted dir", headers.Linkname, resolved)
+				}
+				depth--
+			}
+			resolved = filepath.Dir(resolved)
+		default:
+			depth++
+			resolved = filepath.Join(resolved, component)
+			if fi, err := e.fs.Lstat(resolved); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				return xerrors.Errorf("symlink to %s goes through another symlink", headers.Linkname)
+			}
+		}
+		if !e.isInDestDir(resolved) {
+			return xerrors.Errorf("symlink to %s escapes the extraction dir", headers.Linkname)
+		}
+	}
+
+	if err := vfs.MkdirAll(e.fs, filepath.Dir(target), 0750); err != nil {
+		return err
+	}
+	if err := e.removeExisting(target); err != nil {
+		return err
+	}
+	return e.fs.Symlink(headers.Linkname, target)
+}
+
+func (e *extractor) extractHardLink(target string, headers *tar.Header) error {
+	source, err := e.targetPath(headers.Linkname)
+	if err != nil {
+		return xerrors.Errorf("hard link to %s: %w", headers.Linkname, err)
+	}
+	if err := e.checkParents(source); err != nil {
+		return xerrors.Errorf("hard link to %s: %w", headers.Linkname, err)
+	}
+
+	fi, err := e.fs.Lstat(source)
+	if err != nil {
+		return xerrors.Errorf("hard link to %s: %w", headers.Linkname, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return xerrors.Errorf("hard link to %s: only links to regular files are supported", headers.Linkname)
+	}
+	if err := e.reserve(fi.Size()); err != nil {
+		return err
+	}
+
+	src, err := e.fs.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := vfs.MkdirAll(e.fs, filepath.Dir(target), 0750); err != nil {
+		return err
+	}
+	if err := e.removeExisting(target); err != nil {
+		return err
+	}
+
+	logrus.Debugf("Copying %s to %s...", headers.Linkname, headers.Name)
+	return e.writeFile(target, fi.Mode().Perm(), src, fi.Size())
+}
+
+// reserve checks that a file of the given size could be extracted without
+// exceeding the limits.
+func (e *extractor) reserve(size int64) error {
+	if e.limits.MaxFileSize > 0 && size > e.limits.MaxFileSize {
+		return xerrors.Errorf("file is %d bytes long, more than the limit of %d bytes", size, e.limits.MaxFileSize)
+	}
+	e.total += size
+	if e.limits.MaxTotalSize > 0 && e.total > e.limits.MaxTotalSize {
+		return xerrors.Errorf("archive is more than %d bytes long once extracted", e.limits.MaxTotalSize)
+	}
+	return nil
+}
+
+// fileMode returns the mode of extracted files: permissions from the archive
+// are preserved (such that scripts stay executable), except setuid/setgid
+// bits and write permissions for group and others. The owner can always read
+// and write the file.
+func fileMode(mode int64) os.FileMode {
+	return os.FileMode(mode).Perm()&0755 | 0600
+}
+
+// dirMode is like fileMode but for directories: the owner can always list,
+// traverse and write them.
+func dirMode(mode int64) os.FileMode {
+	return os.FileMode(mode).Perm()&0755 | 0700
+}
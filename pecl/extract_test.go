@@ -0,0 +1,274 @@
+package pecl_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+// tarEntry describes an entry of the archives built by newTestArchive.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	linkname string
+	body     string
+}
+
+func newTestArchive(t *testing.T, entries []tarEntry) []byte {
+	var buf bytes.Buffer
+	gzipw := gzip.NewWriter(&buf)
+	tarw := tar.NewWriter(gzipw)
+
+	for _, entry := range entries {
+		headers := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Mode:     entry.mode,
+			Linkname: entry.linkname,
+			Size:     int64(len(entry.body)),
+		}
+		if entry.typeflag != tar.TypeReg {
+			headers.Size = 0
+		}
+		if err := tarw.WriteHeader(headers); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarw.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tarw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type extractTC struct {
+	entries     []tarEntry
+	limits      *pecl.ExtractLimits
+	fsTests     []interface{}
+	expectedErr error
+}
+
+func initSuccessfullyExtractFilesDirsAndLinksTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "zip-1.15.5/", typeflag: tar.TypeDir, mode: 0755},
+			{name: "zip-1.15.5/configure", typeflag: tar.TypeReg, mode: 0775, body: "#!/bin/sh\n"},
+			{name: "zip-1.15.5/php_zip.c", typeflag: tar.TypeReg, mode: 04666, body: "int main() {}\n"},
+			{name: "zip-1.15.5/lib/", typeflag: tar.TypeDir, mode: 0700},
+			{name: "zip-1.15.5/lib/zip.h", typeflag: tar.TypeSymlink, linkname: "../php_zip.c"},
+			{name: "zip-1.15.5/lib/zip.c", typeflag: tar.TypeLink, linkname: "zip-1.15.5/php_zip.c"},
+			{name: "zip-1.15.5/fifo", typeflag: tar.TypeFifo, mode: 0644},
+		},
+		fsTests: []interface{}{
+			vfst.TestPath("/tmp/zip-1.15.5/configure",
+				vfst.TestModeIsRegular,
+				vfst.TestModePerm(0755),
+				vfst.TestContentsString("#!/bin/sh\n")),
+			vfst.TestPath("/tmp/zip-1.15.5/php_zip.c",
+				vfst.TestModeIsRegular,
+				vfst.TestModePerm(0644)),
+			vfst.TestPath("/tmp/zip-1.15.5/lib",
+				vfst.TestIsDir,
+				vfst.TestModePerm(0700)),
+			vfst.TestPath("/tmp/zip-1.15.5/lib/zip.h",
+				vfst.TestModeType(os.ModeSymlink),
+				vfst.TestSymlinkTarget("../php_zip.c")),
+			vfst.TestPath("/tmp/zip-1.15.5/lib/zip.c",
+				vfst.TestModeIsRegular,
+				vfst.TestContentsString("int main() {}\n")),
+			vfst.TestPath("/tmp/zip-1.15.5/fifo", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToExtractEntryEscapingDestDirTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "zip-1.15.5/../../evil.sh", typeflag: tar.TypeReg, mode: 0755, body: "rm -rf /\n"},
+		},
+		expectedErr: fmt.Errorf("could not decompress zip v1.15.5: could not extract zip-1.15.5/../../evil.sh: path escapes the extraction dir"),
+		fsTests: []interface{}{
+			vfst.TestPath("/evil.sh", vfst.TestDoesNotExist),
+			vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToExtractEntryWithAbsolutePathTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "/etc/evil.conf", typeflag: tar.TypeReg, mode: 0644, body: "evil"},
+		},
+		expectedErr: fmt.Errorf("could not decompress zip v1.15.5: could not extract /etc/evil.conf: absolute paths are not allowed"),
+		fsTests: []interface{}{
+			vfst.TestPath("/etc/evil.conf", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToExtractSymlinkEscapingDestDirTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "zip-1.15.5/passwd", typeflag: tar.TypeSymlink, linkname: "../../etc/passwd"},
+		},
+		expectedErr: fmt.Errorf("could not decompress zip v1.15.5: could not extract zip-1.15.5/passwd: symlink to ../../etc/passwd escapes the extraction dir"),
+	}
+}
+
+func initFailToExtractSymlinkThroughDirNotExtractedYetTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "zip-1.15.5/lib/", typeflag: tar.TypeDir, mode: 0755},
+			{name: "zip-1.15.5/lib/evil.sh", typeflag: tar.TypeSymlink, linkname: "d/../../evil.sh"},
+			{name: "zip-1.15.5/lib/d", typeflag: tar.TypeSymlink, linkname: "."},
+		},
+		expectedErr: fmt.Errorf("could not decompress zip v1.15.5: could not extract zip-1.15.5/lib/evil.sh: symlink to d/../../evil.sh goes through /tmp/zip-1.15.5/lib/d, which is not an extracted dir"),
+		fsTests: []interface{}{
+			vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToExtractAbsoluteSymlinkTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "zip-1.15.5/passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+		},
+		expectedErr: fmt.Errorf("could not decompress zip v1.15.5: could not extract zip-1.15.5/passwd: symlink to absolute path /etc/passwd is not allowed"),
+	}
+}
+
+func initFailToWriteThroughSymlinkTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "zip-1.15.5/lib", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "zip-1.15.5/lib/evil.c", typeflag: tar.TypeReg, mode: 0644, body: "evil"},
+		},
+		expectedErr: fmt.Errorf("could not decompress zip v1.15.5: could not extract zip-1.15.5/lib/evil.c: parent dir /tmp/zip-1.15.5/lib is a symlink"),
+		fsTests: []interface{}{
+			vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToVerifyFileOutsideOfDestDirTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "package.xml", typeflag: tar.TypeReg, mode: 0644, body: `<?xml version="1.0" encoding="UTF-8"?>
+<package version="2.0">
+ <name>zip</name>
+ <contents>
+  <dir name="src">
+   <file name="../../evil.sh" role="src" md5sum="d41d8cd98f00b204e9800998ecf8427e"/>
+  </dir>
+ </contents>
+</package>
+`},
+		},
+		expectedErr: fmt.Errorf("failed to download zip v1.15.5: could not verify ../evil.sh: path escapes the extraction dir"),
+		fsTests: []interface{}{
+			vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToExtractFileBiggerThanLimitTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "zip-1.15.5/php_zip.c", typeflag: tar.TypeReg, mode: 0644, body: "0123456789"},
+		},
+		limits:      &pecl.ExtractLimits{MaxFileSize: 8},
+		expectedErr: fmt.Errorf("could not decompress zip v1.15.5: could not extract zip-1.15.5/php_zip.c: file is 10 bytes long, more than the limit of 8 bytes"),
+		fsTests: []interface{}{
+			vfst.TestPath("/tmp/zip-1.15.5", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToExtractArchiveBiggerThanLimitTC(t *testing.T) extractTC {
+	return extractTC{
+		entries: []tarEntry{
+			{name: "zip-1.15.5/php_zip.c", typeflag: tar.TypeReg, mode: 0644, body: "0123456789"},
+			{name: "zip-1.15.5/php_zip.h", typeflag: tar.TypeReg, mode: 0644, body: "0123456789"},
+		},
+		limits:      &pecl.ExtractLimits{MaxTotalSize: 15},
+		expectedErr: fmt.Errorf("could not decompress zip v1.15.5: could not extract zip-1.15.5/php_zip.h: archive is more than 15 bytes long once extracted"),
+	}
+}
+
+func TestDownloadExtractsArchivesSafely(t *testing.T) {
+	testcases := map[string]func(*testing.T) extractTC{
+		"successfully extract files, dirs and links":              initSuccessfullyExtractFilesDirsAndLinksTC,
+		"fail to extract entry escaping the dest dir":             initFailToExtractEntryEscapingDestDirTC,
+		"fail to extract entry with an absolute path":             initFailToExtractEntryWithAbsolutePathTC,
+		"fail to extract symlink escaping the dest dir":           initFailToExtractSymlinkEscapingDestDirTC,
+		"fail to extract symlink through a dir not extracted yet": initFailToExtractSymlinkThroughDirNotExtractedYetTC,
+		"fail to extract absolute symlink":                        initFailToExtractAbsoluteSymlinkTC,
+		"fail to write through a symlink":                         initFailToWriteThroughSymlinkTC,
+		"fail to verify a file outside of the dest dir":           initFailToVerifyFileOutsideOfDestDirTC,
+		"fail to extract file bigger than the limit":              initFailToExtractFileBiggerThanLimitTC,
+		"fail to extract archive bigger than the limit":           initFailToExtractArchiveBiggerThanLimitTC,
+	}
+
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+			roundTripper := newTestRoundTripper(t, map[string][]byte{
+				"https://pecl.php.net/rest/r/zip/1.15.5.xml": loadRawTestdata(t, "testdata/zip-release-1.15.5.xml"),
+				"https://pecl.php.net/get/zip-1.15.5.tgz":    newTestArchive(t, tc.entries),
+			})
+
+			fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+				"/tmp": &vfst.Dir{Perm: 0750},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %+v", err)
+			}
+			defer cleanup()
+
+			client := peclapi.NewClient(
+				peclapi.WithHttpClient(newTestClient(roundTripper)),
+				peclapi.WithFS(fs))
+
+			opts := []pecl.BackendOpt{pecl.WithClient(client), pecl.WithFS(fs)}
+			if tc.limits != nil {
+				opts = append(opts, pecl.WithExtractLimits(*tc.limits))
+			}
+			backend := pecl.New(opts...)
+
+			_, err = backend.Download(context.Background(), pecl.DownloadOpts{
+				Extension:   "zip",
+				Version:     "1.15.5",
+				DownloadDir: "/tmp",
+			})
+			if tc.expectedErr != nil {
+				if err == nil || err.Error() != tc.expectedErr.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			vfst.RunTests(t, fs, "extracted files", tc.fsTests...)
+		})
+	}
+}
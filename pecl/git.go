@@ -0,0 +1,222 @@
+package pecl
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/sirupsen/logrus"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+const gitSourcePrefix = "git+"
+
+// gitCommitRegexp matches full commit hashes, either SHA-1 or SHA-256 ones.
+var gitCommitRegexp = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// GitSource is a git repository and a ref to check out, from which an
+// extension could be installed.
+type GitSource struct {
+	// URL is the URL of the repository, or the path to a local repository.
+	URL string
+	// Ref is the branch, the tag or the commit to check out. It's empty when
+	// the default branch of the repository should be used.
+	Ref string
+}
+
+func (s GitSource) String() string {
+	if s.Ref == "" {
+		return gitSourcePrefix + s.URL
+	}
+	return gitSourcePrefix + s.URL + "#" + s.Ref
+}
+
+// IsGitSource returns true when the given source is a git source (see
+// ParseGitSource).
+func IsGitSource(source string) bool {
+	return strings.HasPrefix(source, gitSourcePrefix)
+}
+
+// ParseGitSource parses a git source in the format git+<url>[#<ref>], like
+// git+https://github.com/phpredis/phpredis.git#5.3.7. The url could also be
+// the path to a local repository (eg. git+/srv/git/redis.git#develop).
+func ParseGitSource(source string) (GitSource, error) {
+	if !IsGitSource(source) {
+		return GitSource{}, xerrors.Errorf("invalid git source %q: it should start with %s", source, gitSourcePrefix)
+	}
+
+	var parsed GitSource
+	parsed.URL = strings.TrimPrefix(source, gitSourcePrefix)
+	if idx := strings.LastIndex(parsed.URL, "#"); idx != -1 {
+		parsed.Ref = parsed.URL[idx+1:]
+		parsed.URL = parsed.URL[:idx]
+
+		if parsed.Ref == "" {
+			return parsed, xerrors.Errorf("invalid git source %q: empty ref after #", source)
+		}
+	}
+	if parsed.URL == "" {
+		return parsed, xerrors.Errorf("invalid git source %q: empty repository url", source)
+	}
+
+	return parsed, nil
+}
+
+// checkoutDir returns the dir, relative to the download dir, where the
+// repository is cloned. It's named after the repository and a digest of its
+// URL, such that forks don't share the same dir.
+func (s GitSource) checkoutDir() string {
+	name := strings.TrimSuffix(path.Base(strings.TrimRight(s.URL, "/")), ".git")
+	digest := sha256.Sum256([]byte(s.URL))
+	return filepath.Join("git", name+"-"+hex.EncodeToString(digest[:4]))
+}
+
+// isLocal returns true when the URL of the repository is a local path.
+func (s GitSource) isLocal() bool {
+	return !strings.Contains(s.URL, "://") && !strings.Contains(s.URL, "@")
+}
+
+// prepareGitSource clones the repository of the given git source to
+// downloadDir, or fetches it when it has already been cloned, and checks out
+// the requested ref. Local changes and untracked files in the checkout dir
+// are discarded.
+func (b backend) prepareGitSource(ctx context.Context, source, downloadDir string) (preparedSource, error) {
+	gitSrc, err := ParseGitSource(source)
+	if err != nil {
+		return preparedSource{}, err
+	}
+	if gitSrc.isLocal() {
+		// The repository is cloned from another working dir.
+		if gitSrc.URL, err = filepath.Abs(gitSrc.URL); err != nil {
+			return preparedSource{}, xerrors.Errorf("could not fetch %s: %w", source, err)
+		}
+	}
+
+	checkoutDir := filepath.Join(downloadDir, gitSrc.checkoutDir())
+	// Sources pinned to a commit (see LoadSourcePackage) are only fetched
+	// when the commit isn't in the checkout dir yet.
+	if b.hasGitCommit(ctx, checkoutDir, gitSrc.Ref) {
+		logrus.Debugf("Commit %s already fetched to %s.", gitSrc.Ref, checkoutDir)
+	} else if err := b.fetchGitRepository(ctx, gitSrc, checkoutDir); err != nil {
+		return preparedSource{}, xerrors.Errorf("could not fetch %s: %w", source, err)
+	}
+
+	rawCheckoutDir, err := b.fs.RawPath(checkoutDir)
+	if err != nil {
+		return preparedSource{}, xerrors.Errorf("could not fetch %s: %w", source, err)
+	}
+	git := b.cmdexec.With(
+		cmdexec.BaseDir(rawCheckoutDir),
+		cmdexec.Stderr(os.Stderr))
+
+	commit, err := resolveGitRef(ctx, git, gitSrc.Ref)
+	if err != nil {
+		return preparedSource{}, xerrors.Errorf("could not check out %s: %w", source, err)
+	}
+	if err := git.Run(ctx, "git", "checkout", "--quiet", "--force", "--detach", commit); err != nil {
+		return preparedSource{}, xerrors.Errorf("could not check out %s: %w", source, err)
+	}
+	if err := git.Run(ctx, "git", "clean", "--quiet", "-ffdx"); err != nil {
+		return preparedSource{}, xerrors.Errorf("could not check out %s: %w", source, err)
+	}
+	logrus.Debugf("Checked out %s at commit %s.", source, commit)
+
+	pkg, xmlPath, err := b.loadSourceDirPackage(checkoutDir)
+	if err != nil {
+		return preparedSource{}, xerrors.Errorf("could not load %s: %w", source, err)
+	}
+
+	// The checkout dir is not managed such that it's kept, and only fetched
+	// on subsequent installs.
+	return preparedSource{
+		pkg:            pkg,
+		sourceDir:      checkoutDir,
+		packageXmlPath: xmlPath,
+		commit:         commit,
+	}, nil
+}
+
+// hasGitCommit returns true when ref is the full hash of a commit already
+// fetched to checkoutDir.
+func (b backend) hasGitCommit(ctx context.Context, checkoutDir, ref string) bool {
+	if !gitCommitRegexp.MatchString(ref) {
+		return false
+	}
+	if fi, err := b.fs.Stat(filepath.Join(checkoutDir, ".git")); err != nil || !fi.IsDir() {
+		return false
+	}
+	rawCheckoutDir, err := b.fs.RawPath(checkoutDir)
+	if err != nil {
+		return false
+	}
+
+	var outbuf bytes.Buffer
+	git := b.cmdexec.With(
+		cmdexec.BaseDir(rawCheckoutDir),
+		cmdexec.Stdout(&outbuf))
+	err = git.Run(ctx, "git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	return err == nil && strings.TrimSpace(outbuf.String()) == ref
+}
+
+// fetchGitRepository clones the repository to checkoutDir, or fetches its
+// branches and tags when checkoutDir already contains a clone.
+func (b backend) fetchGitRepository(ctx context.Context, gitSrc GitSource, checkoutDir string) error {
+	rawCheckoutDir, err := b.fs.RawPath(checkoutDir)
+	if err != nil {
+		return err
+	}
+
+	if fi, err := b.fs.Stat(filepath.Join(checkoutDir, ".git")); err == nil && fi.IsDir() {
+		logrus.Debugf("Fetching %s to %s...", gitSrc.URL, checkoutDir)
+		git := b.cmdexec.With(
+			cmdexec.BaseDir(rawCheckoutDir),
+			cmdexec.Stderr(os.Stderr))
+		return git.Run(ctx, "git", "fetch", "--quiet", "--force", "--tags", "--prune", "origin")
+	}
+
+	if err := b.fs.RemoveAll(checkoutDir); err != nil {
+		return err
+	}
+	if err := vfs.MkdirAll(b.fs, filepath.Dir(checkoutDir), 0750); err != nil {
+		return err
+	}
+
+	logrus.Debugf("Cloning %s to %s...", gitSrc.URL, checkoutDir)
+	git := b.cmdexec.With(cmdexec.Stderr(os.Stderr))
+	return git.Run(ctx, "git", "clone", "--quiet", "--no-checkout", "--", gitSrc.URL, rawCheckoutDir)
+}
+
+// resolveGitRef returns the hash of the commit the given ref points to. Refs
+// are first looked up among the remote branches, such that branches fetched
+// again are resolved to their latest commit, then among tags and commits.
+func resolveGitRef(ctx context.Context, git cmdexec.CmdExecutor, ref string) (string, error) {
+	candidates := []string{"origin/HEAD"}
+	if ref != "" {
+		candidates = []string{"origin/" + ref, ref}
+	}
+
+	for _, candidate := range candidates {
+		var outbuf bytes.Buffer
+		err := git.With(cmdexec.Stdout(&outbuf)).Run(ctx,
+			"git", "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		if commit := strings.TrimSpace(outbuf.String()); err == nil && commit != "" {
+			return commit, nil
+		}
+	}
+
+	if ref == "" {
+		return "", xerrors.Errorf("could not find the default branch of the repository")
+	}
+	return "", xerrors.Errorf("could not find any branch, tag or commit named %q", ref)
+}
@@ -0,0 +1,176 @@
+package pecl_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/go-test/deep"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+type parseGitSourceTC struct {
+	source      string
+	expected    pecl.GitSource
+	expectedErr error
+}
+
+func TestParseGitSource(t *testing.T) {
+	testcases := map[string]parseGitSourceTC{
+		"parse a git source with a ref": {
+			source: "git+https://github.com/phpredis/phpredis.git#5.3.7",
+			expected: pecl.GitSource{
+				URL: "https://github.com/phpredis/phpredis.git",
+				Ref: "5.3.7",
+			},
+		},
+		"parse a git source without ref": {
+			source: "git+ssh://git@github.com/phpredis/phpredis.git",
+			expected: pecl.GitSource{
+				URL: "ssh://git@github.com/phpredis/phpredis.git",
+			},
+		},
+		"parse a git source pointing to a local repository": {
+			source: "git+/srv/git/redis.git#develop",
+			expected: pecl.GitSource{
+				URL: "/srv/git/redis.git",
+				Ref: "develop",
+			},
+		},
+		"fail when the ref is empty": {
+			source:      "git+https://github.com/phpredis/phpredis.git#",
+			expectedErr: fmt.Errorf("invalid git source \"git+https://github.com/phpredis/phpredis.git#\": empty ref after #"),
+		},
+		"fail when the url is empty": {
+			source:      "git+#develop",
+			expectedErr: fmt.Errorf("invalid git source \"git+#develop\": empty repository url"),
+		},
+		"fail when the git+ prefix is missing": {
+			source:      "https://github.com/phpredis/phpredis.git",
+			expectedErr: fmt.Errorf("invalid git source \"https://github.com/phpredis/phpredis.git\": it should start with git+"),
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := pecl.ParseGitSource(tc.source)
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(parsed, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+			if parsed.String() != tc.source {
+				t.Fatalf("Expected String(): %s - Got: %s", tc.source, parsed.String())
+			}
+		})
+	}
+}
+
+const (
+	zipCommit      = "4f1b2c0e5a0c8bd0d9a4a6c6f1a4f3c2e1d0b9a8"
+	zipCheckoutDir = "/tmp/git/zip-df084882"
+)
+
+func newGitExecutor() (cmdexec.CmdExecutor, *cmdexec.Recorder) {
+	executor, recorder := cmdexec.NewTestExecutor()
+	executor = executor.With(
+		fakePHP("7.4.3"),
+		cmdexec.FakeOn([]string{"git", "rev-parse", "--verify", "--quiet", "v1.15.5^{commit}"},
+			cmdexec.FakeStdout(zipCommit+"\n")),
+	)
+	return executor, recorder
+}
+
+func initSuccessfullyInstallZipFromGitTC(t *testing.T) installTC {
+	executor, recorder := newGitExecutor()
+
+	return installTC{
+		httpClient: newTestClient(newTestRoundTripper(t, map[string][]byte{})),
+		cmdExec:    executor,
+		recorder:   recorder,
+		cmdTester: cmdexec.BuildTesters(
+			cmdexec.ExpectCommandArgs([]string{"git", "fetch", "--quiet", "--force", "--tags", "--prune", "origin"}),
+			cmdexec.ExpectCommandArgs([]string{"git", "rev-parse", "--verify", "--quiet", "origin/v1.15.5^{commit}"}),
+			cmdexec.ExpectCommandArgs([]string{"git", "checkout", "--quiet", "--force", "--detach", zipCommit}),
+			cmdexec.ExpectCommandArgs([]string{"git", "clean", "--quiet", "-ffdx"}),
+			newZipBuildTester()),
+		opts: pecl.InstallOpts{
+			DownloadOpts: pecl.DownloadOpts{
+				DownloadDir: "/tmp",
+			},
+			Source:     "git+/srv/git/zip.git#v1.15.5",
+			InstallDir: "/installdir",
+			Cleanup:    true,
+		},
+		files: map[string]interface{}{
+			zipCheckoutDir + "/.git":        &vfst.Dir{Perm: 0750},
+			zipCheckoutDir + "/package.xml": loadRawTestdata(t, "testdata/zip-package.xml"),
+		},
+		fsTests: []interface{}{
+			vfst.TestPath(zipCheckoutDir+"/.git", vfst.TestIsDir),
+		},
+	}
+}
+
+func initSuccessfullyInstallZipFromGitCommitAlreadyFetchedTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromGitTC(t)
+	tc.cmdExec = tc.cmdExec.With(
+		cmdexec.FakeOn([]string{"git", "rev-parse", "--verify", "--quiet", zipCommit + "^{commit}"},
+			cmdexec.FakeStdout(zipCommit+"\n")),
+		// The commit being already fetched, the repository shouldn't be
+		// fetched again.
+		cmdexec.FakeOn([]string{"git", "fetch", "--quiet", "--force", "--tags", "--prune", "origin"},
+			cmdexec.FakeExitCode(1)))
+	tc.cmdTester = cmdexec.BuildTesters(
+		cmdexec.ExpectCommandArgs([]string{"git", "rev-parse", "--verify", "--quiet", zipCommit + "^{commit}"}),
+		cmdexec.ExpectCommandArgs([]string{"git", "checkout", "--quiet", "--force", "--detach", zipCommit}),
+		newZipBuildTester())
+	tc.opts.Source = "git+/srv/git/zip.git#" + zipCommit
+	return tc
+}
+
+func initFailToInstallFromGitWhenRefIsNotFoundTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromGitTC(t)
+	tc.opts.Source = "git+/srv/git/zip.git#v9.9.9"
+	tc.expectedErr = fmt.Errorf("failed to install git+/srv/git/zip.git#v9.9.9: could not check out git+/srv/git/zip.git#v9.9.9: could not find any branch, tag or commit named \"v9.9.9\"")
+	return tc
+}
+
+func initSuccessfullyInstallFromGitWithOnlyConfigM4TC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromGitTC(t)
+	delete(tc.files, zipCheckoutDir+"/package.xml")
+	tc.files[zipCheckoutDir+"/config.m4"] = "PHP_NEW_EXTENSION(zip, php_zip.c, $ext_shared)\n"
+	return tc
+}
+
+func initFailToInstallFromGitWithoutPackageXMLNorConfigM4TC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromGitTC(t)
+	delete(tc.files, zipCheckoutDir+"/package.xml")
+	tc.expectedErr = fmt.Errorf("failed to install git+/srv/git/zip.git#v1.15.5: could not load git+/srv/git/zip.git#v1.15.5: no package.xml found in /tmp/git/zip-df084882, nor in its parent directory, and no config.m4 either")
+	return tc
+}
+
+func TestInstallFromGit(t *testing.T) {
+	testcases := map[string]func(*testing.T) installTC{
+		"successfully install zip from a git repository":             initSuccessfullyInstallZipFromGitTC,
+		"successfully install zip from a git commit already fetched": initSuccessfullyInstallZipFromGitCommitAlreadyFetchedTC,
+		"fail to install from git when ref is not found":             initFailToInstallFromGitWhenRefIsNotFoundTC,
+		"successfully install from git with only a config.m4":        initSuccessfullyInstallFromGitWithOnlyConfigM4TC,
+		"fail to install from git without package.xml nor config.m4": initFailToInstallFromGitWithoutPackageXMLNorConfigM4TC,
+	}
+
+	runInstallTestcases(t, testcases)
+}
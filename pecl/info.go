@@ -0,0 +1,67 @@
+package pecl
+
+import (
+	"context"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclpkg"
+	"golang.org/x/xerrors"
+)
+
+// ExtensionInfo gathers the metadata of an extension served by a channel, and
+// those of one of its releases.
+type ExtensionInfo struct {
+	// Package is the description of the extension.
+	Package peclapi.Package
+	// Releases lists all the releases of the extension along with their
+	// stability.
+	Releases peclapi.PackageReleases
+	// Release is the release the version constraint was resolved to.
+	Release peclapi.Release
+	// PackageXML is the package.xml of Release. It contains its dependencies,
+	// its configure options and the changelog of the extension.
+	PackageXML peclpkg.Package
+}
+
+// Latest returns the newest release with the given stability, or an empty
+// string when there's none.
+func (i ExtensionInfo) Latest(stability peclapi.Stability) string {
+	for _, release := range i.Releases.Sort() {
+		if i.Releases[release] == stability {
+			return release
+		}
+	}
+	return ""
+}
+
+// Info resolves the version constraint of the given extension and returns
+// the metadata of the extension and of the release found. Nothing is
+// downloaded but REST metadata.
+func (b backend) Info(
+	ctx context.Context,
+	name,
+	constraint string,
+	minimumStability peclapi.Stability,
+) (ExtensionInfo, error) {
+	var info ExtensionInfo
+
+	extVersion, err := b.ResolveConstraint(ctx, name, constraint, minimumStability)
+	if err != nil {
+		return info, err
+	}
+
+	if info.Package, err = b.apiClient.DescribePackage(ctx, name); err != nil {
+		return info, xerrors.Errorf("could not get info about %s: %w", name, err)
+	}
+	if info.Releases, err = b.apiClient.ListReleases(ctx, name); err != nil {
+		return info, xerrors.Errorf("could not get info about %s: %w", name, err)
+	}
+	if info.Release, err = b.apiClient.DescribeRelease(ctx, name, extVersion); err != nil {
+		return info, xerrors.Errorf("could not get info about %s: %w", name, err)
+	}
+	if info.PackageXML, err = b.apiClient.DescribeReleasePackage(ctx, name, extVersion); err != nil {
+		return info, xerrors.Errorf("could not get info about %s: %w", name, err)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,98 @@
+package pecl_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/go-test/deep"
+)
+
+type infoTC struct {
+	httpClient       *http.Client
+	constraint       string
+	minimumStability peclapi.Stability
+	expectedErr      error
+}
+
+func initSuccessfullyGetInfoAboutRedisTC(t *testing.T) infoTC {
+	roundTripper := newTestRoundTripper(t, map[string][]byte{
+		"https://pecl.php.net/rest/r/redis/allreleases.xml":   loadRawTestdata(t, "testdata/redis-releases.xml"),
+		"https://pecl.php.net/rest/p/redis/info.xml":          loadRawTestdata(t, "testdata/redis-info.xml"),
+		"https://pecl.php.net/rest/r/redis/5.1.1.xml":         loadRawTestdata(t, "testdata/redis-release-5.1.1.xml"),
+		"https://pecl.php.net/rest/r/redis/package.5.1.1.xml": loadRawTestdata(t, "testdata/redis-package.xml"),
+	})
+
+	return infoTC{
+		httpClient:       newTestClient(roundTripper),
+		constraint:       "~5.1.0",
+		minimumStability: peclapi.Stable,
+	}
+}
+
+func initFailToGetInfoWhenNoVersionMatchesTC(t *testing.T) infoTC {
+	roundTripper := newTestRoundTripper(t, map[string][]byte{
+		"https://pecl.php.net/rest/r/redis/allreleases.xml": loadRawTestdata(t, "testdata/redis-releases.xml"),
+	})
+
+	return infoTC{
+		httpClient:       newTestClient(roundTripper),
+		constraint:       "^4.0",
+		minimumStability: peclapi.Stable,
+		expectedErr:      fmt.Errorf("could not find a version of redis satisfying \"^4.0\""),
+	}
+}
+
+func TestInfo(t *testing.T) {
+	testcases := map[string]func(*testing.T) infoTC{
+		"successfully get info about redis":        initSuccessfullyGetInfoAboutRedisTC,
+		"fail to get info when no version matches": initFailToGetInfoWhenNoVersionMatchesTC,
+	}
+
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
+			backend := pecl.New(pecl.WithClient(client))
+
+			info, err := backend.Info(context.Background(), "redis", tc.constraint, tc.minimumStability)
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if info.Package.Category != "Database" {
+				t.Errorf("Expected category: Database - Got: %s", info.Package.Category)
+			}
+			if info.Release.Version != "5.1.1" || info.PackageXML.Version.Release != "5.1.1" {
+				t.Errorf("Expected release 5.1.1 - Got: %s (package.xml: %s)", info.Release.Version, info.PackageXML.Version.Release)
+			}
+
+			latest := map[string]string{}
+			for _, stability := range []peclapi.Stability{peclapi.Stable, peclapi.Beta, peclapi.Alpha, peclapi.Devel} {
+				latest[stability.String()] = info.Latest(stability)
+			}
+			expected := map[string]string{
+				"stable": "5.2.0",
+				"beta":   "5.1.0RC2",
+				"alpha":  "5.2.0RC2",
+				"devel":  "",
+			}
+			if diff := deep.Equal(latest, expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
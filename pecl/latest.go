@@ -0,0 +1,47 @@
+package pecl
+
+import (
+	"context"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"golang.org/x/xerrors"
+)
+
+// LatestReleases are the newest releases of an extension, as found by
+// FindLatestReleases.
+type LatestReleases struct {
+	// Matching is the newest release satisfying both the version constraint
+	// and the minimum stability. It's empty when there's none.
+	Matching string
+	// Latest is the newest release with at least the minimum stability,
+	// whatever the version constraint. It's empty when there's none.
+	Latest string
+}
+
+// FindLatestReleases lists the releases of an extension and returns the
+// newest one satisfying the version constraint and the minimum stability, as
+// well as the newest one satisfying only the minimum stability. Like
+// ResolveConstraint, releases that aren't available offline are ignored when
+// the backend is offline.
+func (b backend) FindLatestReleases(
+	ctx context.Context,
+	name,
+	constraint string,
+	minimumStability peclapi.Stability,
+) (LatestReleases, error) {
+	var latest LatestReleases
+
+	extVersions, err := b.apiClient.ListReleases(ctx, name)
+	if err != nil {
+		return latest, xerrors.Errorf("could not find the latest releases of %s: %w", name, err)
+	}
+
+	if latest.Matching, err = b.newestRelease(ctx, name, extVersions, constraint, minimumStability); err != nil {
+		return latest, xerrors.Errorf("could not find the latest releases of %s: %w", name, err)
+	}
+	if latest.Latest, err = b.newestRelease(ctx, name, extVersions, "*", minimumStability); err != nil {
+		return latest, xerrors.Errorf("could not find the latest releases of %s: %w", name, err)
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,116 @@
+package pecl_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/go-test/deep"
+)
+
+type findLatestReleasesTC struct {
+	httpClient       *http.Client
+	backendOpts      []pecl.BackendOpt
+	constraint       string
+	minimumStability peclapi.Stability
+	expected         pecl.LatestReleases
+	expectedErr      error
+}
+
+func newRedisReleasesClient(t *testing.T) *http.Client {
+	roundTripper := newTestRoundTripper(t, map[string][]byte{
+		"https://pecl.php.net/rest/r/redis/allreleases.xml": loadRawTestdata(t, "testdata/redis-releases.xml"),
+	})
+	return newTestClient(roundTripper)
+}
+
+func initSuccessfullyFindLatestReleasesOfRedisTC(t *testing.T) findLatestReleasesTC {
+	return findLatestReleasesTC{
+		httpClient:       newRedisReleasesClient(t),
+		constraint:       "~5.1.0",
+		minimumStability: peclapi.Stable,
+		expected: pecl.LatestReleases{
+			Matching: "5.1.1",
+			Latest:   "5.2.0",
+		},
+	}
+}
+
+func initSuccessfullyFindLatestReleasesWhenNoneMatchesTC(t *testing.T) findLatestReleasesTC {
+	return findLatestReleasesTC{
+		httpClient:       newRedisReleasesClient(t),
+		constraint:       "^4.0",
+		minimumStability: peclapi.Stable,
+		expected: pecl.LatestReleases{
+			Latest: "5.2.0",
+		},
+	}
+}
+
+func initSuccessfullyFindLatestReleasesAvailableOfflineTC(t *testing.T) findLatestReleasesTC {
+	roundTripper := offlineRoundTripper{
+		"https://pecl.php.net/rest/r/redis/allreleases.xml": loadRawTestdata(t, "testdata/redis-releases.xml"),
+		"https://pecl.php.net/rest/r/redis/5.1.1.xml":       loadRawTestdata(t, "testdata/redis-release-5.1.1.xml"),
+	}
+
+	return findLatestReleasesTC{
+		httpClient:       &http.Client{Transport: roundTripper},
+		backendOpts:      []pecl.BackendOpt{pecl.WithOffline()},
+		constraint:       "~5.1.0",
+		minimumStability: peclapi.Stable,
+		expected: pecl.LatestReleases{
+			Matching: "5.1.1",
+			Latest:   "5.1.1",
+		},
+	}
+}
+
+func initFailToFindLatestReleasesWhenClientFailsTC(t *testing.T) findLatestReleasesTC {
+	roundTripper := newFailingTestRoundTripper(t, fmt.Errorf("some error"))
+
+	return findLatestReleasesTC{
+		httpClient:       &http.Client{Transport: roundTripper},
+		constraint:       "*",
+		minimumStability: peclapi.Stable,
+		expectedErr:      fmt.Errorf("could not find the latest releases of redis: Get \"https://pecl.php.net/rest/r/redis/allreleases.xml\": some error"),
+	}
+}
+
+func TestFindLatestReleases(t *testing.T) {
+	testcases := map[string]func(*testing.T) findLatestReleasesTC{
+		"successfully find the latest releases of redis":          initSuccessfullyFindLatestReleasesOfRedisTC,
+		"successfully find the latest releases when none matches": initSuccessfullyFindLatestReleasesWhenNoneMatchesTC,
+		"successfully find the latest releases available offline": initSuccessfullyFindLatestReleasesAvailableOfflineTC,
+		"fail to find the latest releases when API client fails":  initFailToFindLatestReleasesWhenClientFailsTC,
+	}
+
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
+			backend := pecl.New(append(tc.backendOpts, pecl.WithClient(client))...)
+
+			latest, err := backend.FindLatestReleases(context.Background(), "redis", tc.constraint, tc.minimumStability)
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(latest, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package pecl
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/NiR-/notpecl/peclpkg"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// preparedSource is the source code of an extension, ready to be built.
+type preparedSource struct {
+	pkg            peclpkg.Package
+	sourceDir      string
+	packageXmlPath string
+	// managed indicates whether sourceDir has been extracted or checked out
+	// by notpecl, and thus could be removed once the extension is installed.
+	managed bool
+	// commit is the hash of the commit checked out, for git sources.
+	commit string
+}
+
+// LoadSourcePackage loads the package.xml of the given source, as accepted by
+// InstallOpts.Source. For source dirs and git sources, the package.xml is
+// looked up in the dir itself and then in its parent dir. When there's none,
+// a Package with only a Name, inferred from config.m4, is returned. Git
+// sources are fetched to downloadDir first.
+//
+// It also returns the source to pass to Install: git sources are pinned to
+// the commit checked out (ie. git+<url>#<commit>), such that they're not
+// fetched again, and such that the package installed is the one loaded.
+// Other sources are returned unchanged.
+func (b backend) LoadSourcePackage(ctx context.Context, source, downloadDir string) (peclpkg.Package, string, error) {
+	if IsGitSource(source) {
+		src, err := b.prepareGitSource(ctx, source, downloadDir)
+		if err != nil {
+			return src.pkg, source, err
+		}
+		// prepareGitSource already validated the source.
+		gitSrc, _ := ParseGitSource(source)
+		gitSrc.Ref = src.commit
+		return src.pkg, gitSrc.String(), nil
+	}
+
+	fi, err := b.fs.Stat(source)
+	if err != nil {
+		return peclpkg.Package{}, source, xerrors.Errorf("could not load %s: %w", source, err)
+	}
+
+	if fi.IsDir() {
+		pkg, _, err := b.loadSourceDirPackage(source)
+		return pkg, source, err
+	}
+
+	pkg, err := b.readArchivePackageXML(source)
+	if err != nil {
+		return pkg, source, xerrors.Errorf("could not load %s: %w", source, err)
+	}
+	return pkg, source, nil
+}
+
+// prepareSource makes the extension at the given source ready to be built.
+func (b backend) prepareSource(ctx context.Context, source, downloadDir string) (preparedSource, error) {
+	if IsGitSource(source) {
+		return b.prepareGitSource(ctx, source, downloadDir)
+	}
+	return b.prepareLocalSource(ctx, source, downloadDir)
+}
+
+// prepareLocalSource makes the extension at the given path ready to be built.
+// Release archives are extracted to downloadDir and their content is checked
+// against their package.xml, just like downloaded releases. Source dirs are
+// built in place.
+func (b backend) prepareLocalSource(ctx context.Context, source, downloadDir string) (preparedSource, error) {
+	fi, err := b.fs.Stat(source)
+	if err != nil {
+		return preparedSource{}, xerrors.Errorf("could not load %s: %w", source, err)
+	}
+
+	if fi.IsDir() {
+		pkg, xmlPath, err := b.loadSourceDirPackage(source)
+		if err != nil {
+			return preparedSource{}, err
+		}
+
+		return preparedSource{
+			pkg:            pkg,
+			sourceDir:      source,
+			packageXmlPath: xmlPath,
+		}, nil
+	}
+
+	pkg, err := b.readArchivePackageXML(source)
+	if err != nil {
+		return preparedSource{}, xerrors.Errorf("could not load %s: %w", source, err)
+	}
+
+	dirPrefix := fmt.Sprintf("%s-%s/", pkg.Name, pkg.Version.Release)
+	extDir := filepath.Join(downloadDir, dirPrefix)
+
+	// Unlike downloaded releases, local archives could be rebuilt with the
+	// same version number, so previously extracted files are never reused.
+	if err := b.fs.RemoveAll(extDir); err != nil {
+		return preparedSource{}, xerrors.Errorf("could not extract %s: %w", source, err)
+	}
+
+	archive, err := b.fs.Open(source)
+	if err != nil {
+		return preparedSource{}, xerrors.Errorf("could not extract %s: %w", source, err)
+	}
+	defer archive.Close()
+
+	logrus.Debugf("Extracting %s to %s...", source, extDir)
+	if err := b.extract(ctx, extDir, dirPrefix, archive); err != nil {
+		b.removeExtractedFiles(extDir)
+		return preparedSource{}, xerrors.Errorf("could not decompress %s: %w", source, err)
+	}
+	if err := b.verifyExtractedFiles(extDir); err != nil {
+		b.removeExtractedFiles(extDir)
+		return preparedSource{}, xerrors.Errorf("could not extract %s: %w", source, err)
+	}
+
+	return preparedSource{
+		pkg:            pkg,
+		sourceDir:      extDir,
+		packageXmlPath: filepath.Join(extDir, "package.xml"),
+		managed:        true,
+	}, nil
+}
+
+// loadSourceDirPackage loads the package.xml of the given source dir, looked
+// up in the dir itself and then in its parent dir. When there's none, the
+// package is inferred from the config.m4 of the source dir and the returned
+// path to the package.xml is empty.
+func (b backend) loadSourceDirPackage(sourceDir string) (peclpkg.Package, string, error) {
+	for _, xmlPath := range []string{
+		filepath.Join(sourceDir, "package.xml"),
+		filepath.Join(sourceDir, "..", "package.xml"),
+	} {
+		if _, err := b.fs.Stat(xmlPath); err == nil {
+			pkg, err := b.loadPackageXML(xmlPath)
+			return pkg, xmlPath, err
+		}
+	}
+
+	if !hasConfigM4(b.fs, sourceDir) {
+		return peclpkg.Package{}, "", xerrors.Errorf("no package.xml found in %s, nor in its parent directory, and no config.m4 either", sourceDir)
+	}
+
+	logrus.Debugf("No package.xml found in %s, falling back to its config.m4.", sourceDir)
+	pkg, err := b.packageFromConfigM4(sourceDir)
+	return pkg, "", err
+}
+
+func (b backend) loadPackageXML(xmlPath string) (peclpkg.Package, error) {
+	rawPath, err := b.fs.RawPath(xmlPath)
+	if err != nil {
+		return peclpkg.Package{}, xerrors.Errorf("failed to load %s: %w", xmlPath, err)
+	}
+
+	pkg, err := peclpkg.LoadPackageXMLFromFile(rawPath)
+	if err != nil {
+		return pkg, xerrors.Errorf("failed to load %s: %w", xmlPath, err)
+	}
+	return pkg, nil
+}
+
+// readArchivePackageXML reads the package.xml at the root of the release
+// archive at the given path, without extracting it.
+func (b backend) readArchivePackageXML(archivePath string) (peclpkg.Package, error) {
+	f, err := b.fs.Open(archivePath)
+	if err != nil {
+		return peclpkg.Package{}, err
+	}
+	defer f.Close()
+
+	gzipr, err := gzip.NewReader(f)
+	if err != nil {
+		return peclpkg.Package{}, xerrors.Errorf("not a tgz archive: %w", err)
+	}
+	defer gzipr.Close()
+
+	tarr := tar.NewReader(gzipr)
+	for {
+		headers, err := tarr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return peclpkg.Package{}, err
+		}
+		if headers.Name != "package.xml" || headers.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		pkg, err := peclpkg.LoadPackageXML(io.LimitReader(tarr, headers.Size))
+		if err != nil {
+			return pkg, xerrors.Errorf("failed to load package.xml: %w", err)
+		}
+		if pkg.Name == "" || pkg.Version.Release == "" {
+			return pkg, xerrors.Errorf("package.xml doesn't declare the name and the version of the package")
+		}
+		return pkg, nil
+	}
+
+	return peclpkg.Package{}, xerrors.Errorf("no package.xml found at the root of the archive")
+}
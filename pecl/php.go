@@ -0,0 +1,166 @@
+package pecl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// phpInstallation is the PHP installation extensions are built for: its
+// php-config and phpize scripts, and the php binary used to check the
+// dependencies of the extensions.
+type phpInstallation struct {
+	phpConfig string
+	phpize    string
+	binary    string
+	version   string
+}
+
+// resolvePhpInstallation finds the PHP installation targeted by the backend
+// (see WithPhpConfigPath and WithPhpBinary):
+//
+//   - php-config is the one explicitly set, or the one living next to the php
+//     binary explicitly set (eg. /usr/bin/php-config7.4 for /usr/bin/php7.4),
+//     or the one found in PATH otherwise;
+//   - phpize is found in the bin dir of php-config --prefix, with the same
+//     suffix as php-config;
+//   - the php binary is the one explicitly set, or php-config --php-binary.
+//
+// It fails when php-config and the php binary report different versions, as
+// dependencies would otherwise be checked against another PHP version than
+// the one the extension is built for.
+func (b backend) resolvePhpInstallation(ctx context.Context) (phpInstallation, error) {
+	var php phpInstallation
+	var err error
+
+	php.phpConfig = b.phpConfigPath
+	if php.phpConfig == "" && b.phpBinary != "" {
+		php.phpConfig = phpConfigNextTo(b.phpBinary)
+	}
+	if php.phpConfig == "" {
+		if php.phpConfig, err = exec.LookPath("php-config"); err != nil {
+			return php, xerrors.Errorf("could not find php-config: %w", err)
+		}
+	}
+
+	prefix, err := b.queryPhpConfig(ctx, php.phpConfig, "--prefix")
+	if err != nil {
+		return php, err
+	} else if prefix == "" {
+		return php, xerrors.Errorf("%s --prefix returned an empty prefix", php.phpConfig)
+	}
+	suffix := strings.TrimPrefix(filepath.Base(php.phpConfig), "php-config")
+	php.phpize = filepath.Join(prefix, "bin", "phpize"+suffix)
+
+	php.binary = b.phpBinary
+	if php.binary == "" {
+		// php-config --php-binary is only supported since PHP 5.4, so php is
+		// looked up in PATH when it fails.
+		binary, err := b.queryPhpConfig(ctx, php.phpConfig, "--php-binary")
+		if err != nil || binary == "" {
+			binary = "php"
+		}
+		php.binary = binary
+	}
+
+	configVersion, err := b.queryPhpConfig(ctx, php.phpConfig, "--version")
+	if err != nil {
+		return php, err
+	}
+	if php.version, err = b.currentPHPVersion(ctx, php.binary); err != nil {
+		return php, xerrors.Errorf("could not get the version of %s: %w", php.binary, err)
+	}
+	if configVersion != php.version {
+		return php, xerrors.Errorf(
+			"%s is for PHP %s but %s is PHP %s: they should belong to the same PHP installation",
+			php.phpConfig, configVersion, php.binary, php.version)
+	}
+
+	logrus.Debugf("Building for PHP %s (php-config: %s, phpize: %s, php: %s).",
+		php.version, php.phpConfig, php.phpize, php.binary)
+
+	return php, nil
+}
+
+// PHPVersion returns the version of the PHP installation extensions are built
+// for (see WithPhpConfigPath and WithPhpBinary).
+func (b backend) PHPVersion(ctx context.Context) (string, error) {
+	php, err := b.resolvePhpInstallation(ctx)
+	if err != nil {
+		return "", err
+	}
+	return php.version, nil
+}
+
+// queryPhpConfig runs php-config with the given flag and returns what it
+// printed.
+func (b backend) queryPhpConfig(ctx context.Context, phpConfig, flag string) (string, error) {
+	var outbuf bytes.Buffer
+	cmdexec := b.cmdexec.With(cmdexec.Stdout(&outbuf))
+
+	if err := cmdexec.Run(ctx, phpConfig, flag); err != nil {
+		return "", xerrors.Errorf("failed to run %s %s: %w", phpConfig, flag, err)
+	}
+
+	return strings.TrimSpace(outbuf.String()), nil
+}
+
+// phpConfigNextTo returns the path to the php-config script living in the
+// same dir as the given php binary, with the same version suffix (eg.
+// /usr/bin/php-config7.4 for /usr/bin/php7.4), or an empty string when
+// there's none.
+func phpConfigNextTo(phpBinary string) string {
+	base := filepath.Base(phpBinary)
+	if !strings.HasPrefix(base, "php") {
+		return ""
+	}
+
+	candidate := filepath.Join(filepath.Dir(phpBinary), "php-config"+strings.TrimPrefix(base, "php"))
+	path, err := exec.LookPath(candidate)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+func (b backend) currentPHPVersion(ctx context.Context, phpBinary string) (string, error) {
+	var outbuf bytes.Buffer
+	cmdexec := b.cmdexec.With(cmdexec.Stdout(&outbuf))
+
+	err := cmdexec.Run(ctx, phpBinary, "-r", "echo json_encode(PHP_VERSION);")
+	if err != nil {
+		return "", err
+	}
+
+	var phpVersion string
+	if err := json.Unmarshal(outbuf.Bytes(), &phpVersion); err != nil {
+		return "", err
+	}
+
+	return phpVersion, nil
+}
+
+func (b backend) isExtensionEnabled(ctx context.Context, phpBinary, name string) (bool, error) {
+	var outbuf bytes.Buffer
+	cmdexec := b.cmdexec.With(cmdexec.Stdout(&outbuf))
+	err := cmdexec.Run(ctx, phpBinary, "-r",
+		fmt.Sprintf("echo json_encode(extension_loaded('%s'));", name))
+	if err != nil {
+		return false, err
+	}
+
+	var val bool
+	if err := json.Unmarshal(outbuf.Bytes(), &val); err != nil {
+		return false, err
+	}
+
+	return val, nil
+}
@@ -0,0 +1,62 @@
+package pecl_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/NiR-/notpecl/pecl"
+)
+
+const phpVersionCode = "echo json_encode(PHP_VERSION);"
+
+func initSuccessfullyInstallWithPhpBinaryTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromSourceDirTC(t)
+	tc.backendOpts = []pecl.BackendOpt{
+		pecl.WithPhpBinary("/opt/php74/bin/php"),
+	}
+	tc.cmdExec = tc.cmdExec.With(
+		cmdexec.FakeOn([]string{"/opt/php74/bin/php", "-r", phpVersionCode},
+			cmdexec.FakeStdout("\"7.4.3\"")))
+	tc.cmdTester = cmdexec.BuildTesters(
+		cmdexec.ExpectCommandArgs([]string{"/opt/php74/bin/php", "-r", phpVersionCode}),
+		newZipBuildTester())
+	return tc
+}
+
+func initSuccessfullyInstallWithPhpBinaryFromPhpConfigTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromSourceDirTC(t)
+	tc.cmdExec = tc.cmdExec.With(
+		cmdexec.FakeOn([]string{phpconfigPath, "--php-binary"},
+			cmdexec.FakeStdout("/usr/bin/php7.4\n")),
+		cmdexec.FakeOn([]string{"/usr/bin/php7.4", "-r", phpVersionCode},
+			cmdexec.FakeStdout("\"7.4.3\"")))
+	tc.cmdTester = cmdexec.BuildTesters(
+		cmdexec.ExpectCommandArgs([]string{"/usr/bin/php7.4", "-r", phpVersionCode}),
+		newZipBuildTester())
+	return tc
+}
+
+func initFailToInstallWhenPhpAndPhpConfigVersionsDifferTC(t *testing.T) installTC {
+	tc := initSuccessfullyInstallZipFromSourceDirTC(t)
+	tc.cmdExec, tc.recorder = cmdexec.NewTestExecutor()
+	tc.cmdExec = tc.cmdExec.With(
+		cmdexec.FakeOn([]string{phpconfigPath, "--prefix"},
+			cmdexec.FakeStdout("/usr\n")),
+		cmdexec.FakeOn([]string{phpconfigPath, "--version"},
+			cmdexec.FakeStdout("8.1.2\n")),
+		cmdexec.FakeOn([]string{"php", "-r", phpVersionCode},
+			cmdexec.FakeStdout("\"7.4.3\"")))
+	tc.expectedErr = fmt.Errorf("failed to install zip: failed to build zip: %s is for PHP 8.1.2 but php is PHP 7.4.3: they should belong to the same PHP installation", phpconfigPath)
+	return tc
+}
+
+func TestInstallForPhpInstallation(t *testing.T) {
+	testcases := map[string]func(*testing.T) installTC{
+		"successfully install with a php binary":                   initSuccessfullyInstallWithPhpBinaryTC,
+		"successfully install with the php binary from php-config": initSuccessfullyInstallWithPhpBinaryFromPhpConfigTC,
+		"fail to install when php and php-config versions differ":  initFailToInstallWhenPhpAndPhpConfigVersionsDifferTC,
+	}
+
+	runInstallTestcases(t, testcases)
+}
@@ -0,0 +1,95 @@
+package pecl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/NiR-/notpecl/installed"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/sirupsen/logrus"
+)
+
+// recordInstall records the extension installed in the registry of the state
+// dir (see WithStateDir). It does nothing when no state dir is set.
+func (b backend) recordInstall(ctx context.Context, opts InstallOpts, src preparedSource, configureArgs []string) error {
+	if b.stateDir == "" {
+		return nil
+	}
+
+	php, err := b.resolvePhpInstallation(ctx)
+	if err != nil {
+		return err
+	}
+	phpAPI, err := b.queryPhpConfig(ctx, php.phpConfig, "--phpapi")
+	if err != nil {
+		return err
+	}
+	extDir, err := b.queryPhpConfig(ctx, php.phpConfig, "--extension-dir")
+	if err != nil {
+		return err
+	}
+
+	modulePath := filepath.Join(opts.InstallDir, extDir, opts.Extension+".so")
+	digest, err := b.fileSha256(modulePath)
+	if err != nil {
+		return err
+	}
+
+	ext := installed.Extension{
+		Name:          opts.Extension,
+		Version:       opts.Version,
+		Channel:       opts.Channel,
+		Constraint:    opts.Constraint,
+		ConfigureArgs: configureArgs,
+		PHPVersion:    php.version,
+		PHPAPI:        phpAPI,
+		PhpConfig:     php.phpConfig,
+		InstallDir:    opts.InstallDir,
+		ModulePath:    modulePath,
+		Sha256:        digest,
+		InstalledAt:   time.Now().UTC(),
+	}
+	if opts.MinimumStability != peclapi.Unknown {
+		ext.MinimumStability = opts.MinimumStability.String()
+	}
+	if opts.Source != "" {
+		ext.Version = src.pkg.Version.Release
+		ext.Channel = ""
+		ext.Constraint = ""
+		ext.MinimumStability = ""
+		ext.Source = opts.Source
+		ext.Commit = src.commit
+	}
+
+	registryPath := filepath.Join(b.stateDir, installed.Filename)
+	r, err := installed.LoadFromFile(b.fs, registryPath)
+	if err != nil {
+		return err
+	}
+	r.Record(ext)
+	if err := r.WriteToFile(b.fs, registryPath); err != nil {
+		return err
+	}
+
+	logrus.Debugf("Recorded %s as installed in %s.", modulePath, registryPath)
+	return nil
+}
+
+// fileSha256 returns the sha256 digest (hex-encoded) of the given file.
+func (b backend) fileSha256(path string) (string, error) {
+	f, err := b.fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,173 @@
+package pecl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/NiR-/notpecl/installed"
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/go-test/deep"
+	"github.com/twpayne/go-vfs"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+const (
+	stateDir      = "/var/lib/notpecl"
+	moduleContent = "ELF module"
+	// moduleSha256 is the sha256 digest of moduleContent.
+	moduleSha256 = "a2016223018f426d864fa94c3fe9287db45a0248f4b2bd011e33685abde17db3"
+)
+
+// testRegistry returns a vfst.PathTest checking the extensions recorded in the
+// registry at the given path. InstalledAt is only checked to be recent.
+func testRegistry(expected []installed.Extension) func(*testing.T, vfs.FS, string) {
+	return func(t *testing.T, fs vfs.FS, path string) {
+		r, err := installed.LoadFromFile(fs, path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		got := r.List()
+		for i := range got {
+			if time.Since(got[i].InstalledAt) > time.Minute {
+				t.Errorf("Expected %s to be installed recently - Got: %s", got[i].Name, got[i].InstalledAt)
+			}
+			got[i].InstalledAt = time.Time{}
+		}
+		if diff := deep.Equal(got, expected); diff != nil {
+			t.Fatal(diff)
+		}
+	}
+}
+
+func withRecordingFakes(tc installTC) installTC {
+	tc.backendOpts = append(tc.backendOpts, pecl.WithStateDir(stateDir))
+	tc.cmdExec = tc.cmdExec.With(
+		cmdexec.FakeOn([]string{phpconfigPath, "--phpapi"},
+			cmdexec.FakeStdout("20190902\n")),
+		cmdexec.FakeOn([]string{phpconfigPath, "--extension-dir"},
+			cmdexec.FakeStdout(phpExtensionDir+"\n")))
+	if tc.files == nil {
+		tc.files = map[string]interface{}{}
+	}
+	// make install being faked, the module is put there beforehand.
+	tc.files["/installdir"+phpExtensionDir+"/zip.so"] = moduleContent
+	return tc
+}
+
+func initSuccessfullyRecordZipDownloadedFromPeclTC(t *testing.T) installTC {
+	tc := withRecordingFakes(initSuccessfullyInstallZipTC(t))
+	tc.opts.Channel = "pecl.php.net"
+	tc.opts.Constraint = "~1.15.0"
+	tc.opts.MinimumStability = peclapi.Stable
+	tc.fsTests = []interface{}{
+		vfst.TestPath(stateDir+"/installed.json", testRegistry([]installed.Extension{
+			{
+				Name:             "zip",
+				Version:          "1.15.5",
+				Channel:          "pecl.php.net",
+				Constraint:       "~1.15.0",
+				MinimumStability: "stable",
+				PHPVersion:       "7.4.3",
+				PHPAPI:           "20190902",
+				PhpConfig:        phpconfigPath,
+				InstallDir:       "/installdir",
+				ModulePath:       "/installdir" + phpExtensionDir + "/zip.so",
+				Sha256:           moduleSha256,
+			},
+		})),
+	}
+	return tc
+}
+
+func initSuccessfullyRecordRedisWithConfigureArgsTC(t *testing.T) installTC {
+	tc := withRecordingFakes(initSuccessfullyInstallRedisWithConfigureOptionsTC(t))
+	tc.files["/installdir"+phpExtensionDir+"/redis.so"] = moduleContent
+	tc.fsTests = []interface{}{
+		vfst.TestPath(stateDir+"/installed.json", testRegistry([]installed.Extension{
+			{
+				Name:    "redis",
+				Version: "5.1.1",
+				ConfigureArgs: []string{
+					"--enable-redis-lzf",
+					"--enable-redis-igbinary=yes",
+					"--enable-redis-zstd=no",
+				},
+				PHPVersion: "7.4.3",
+				PHPAPI:     "20190902",
+				PhpConfig:  phpconfigPath,
+				InstallDir: "/installdir",
+				ModulePath: "/installdir" + phpExtensionDir + "/redis.so",
+				Sha256:     moduleSha256,
+			},
+		})),
+	}
+	return tc
+}
+
+func initSuccessfullyRecordZipInstalledFromSourceDirTC(t *testing.T) installTC {
+	tc := withRecordingFakes(initSuccessfullyInstallZipFromSourceDirTC(t))
+	tc.opts.Channel = "pecl.php.net"
+	tc.opts.Constraint = "*"
+	tc.opts.MinimumStability = peclapi.Stable
+	tc.fsTests = []interface{}{
+		vfst.TestPath(stateDir+"/installed.json", testRegistry([]installed.Extension{
+			{
+				Name:       "zip",
+				Version:    "1.15.5",
+				Source:     "/src/zip",
+				PHPVersion: "7.4.3",
+				PHPAPI:     "20190902",
+				PhpConfig:  phpconfigPath,
+				InstallDir: "/installdir",
+				ModulePath: "/installdir" + phpExtensionDir + "/zip.so",
+				Sha256:     moduleSha256,
+			},
+		})),
+	}
+	return tc
+}
+
+func initSuccessfullyRecordZipInstalledFromGitTC(t *testing.T) installTC {
+	tc := withRecordingFakes(initSuccessfullyInstallZipFromGitTC(t))
+	tc.fsTests = []interface{}{
+		vfst.TestPath(stateDir+"/installed.json", testRegistry([]installed.Extension{
+			{
+				Name:       "zip",
+				Version:    "1.15.5",
+				Source:     "git+/srv/git/zip.git#v1.15.5",
+				Commit:     zipCommit,
+				PHPVersion: "7.4.3",
+				PHPAPI:     "20190902",
+				PhpConfig:  phpconfigPath,
+				InstallDir: "/installdir",
+				ModulePath: "/installdir" + phpExtensionDir + "/zip.so",
+				Sha256:     moduleSha256,
+			},
+		})),
+	}
+	return tc
+}
+
+func initSuccessfullyInstallZipWhenRegistryIsCorruptedTC(t *testing.T) installTC {
+	tc := withRecordingFakes(initSuccessfullyInstallZipTC(t))
+	tc.files[stateDir+"/installed.json"] = "{not json"
+	tc.fsTests = []interface{}{
+		vfst.TestPath(stateDir+"/installed.json", vfst.TestContentsString("{not json")),
+	}
+	return tc
+}
+
+func TestInstallRecordsInstalledExtensions(t *testing.T) {
+	testcases := map[string]func(*testing.T) installTC{
+		"successfully record zip downloaded from pecl":            initSuccessfullyRecordZipDownloadedFromPeclTC,
+		"successfully record redis with its configure args":       initSuccessfullyRecordRedisWithConfigureArgsTC,
+		"successfully record zip installed from a source dir":     initSuccessfullyRecordZipInstalledFromSourceDirTC,
+		"successfully record zip installed from git":              initSuccessfullyRecordZipInstalledFromGitTC,
+		"successfully install zip when the registry is corrupted": initSuccessfullyInstallZipWhenRegistryIsCorruptedTC,
+	}
+
+	runInstallTestcases(t, testcases)
+}
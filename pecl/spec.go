@@ -0,0 +1,94 @@
+package pecl
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"golang.org/x/xerrors"
+)
+
+// ExtensionSpec represents an extension as requested by users, that is an
+// extension name with an optional channel, an optional version constraint and
+// an optional minimum stability.
+type ExtensionSpec struct {
+	// Channel is the name or the alias of the channel serving the extension.
+	// It's empty when the extension comes from the default pecl channel.
+	Channel string
+	// Name is the name of the extension.
+	Name string
+	// Constraint is a version constraint in Composer format. It defaults to
+	// "*" when no constraint is provided.
+	Constraint string
+	// MinimumStability is the minimum stability accepted when resolving the
+	// version constraint.
+	MinimumStability peclapi.Stability
+}
+
+func (s ExtensionSpec) String() string {
+	spec := s.Name + ":" + s.Constraint + "@" + s.MinimumStability.String()
+	if s.Channel != "" {
+		spec = s.Channel + "/" + spec
+	}
+	return spec
+}
+
+var (
+	extensionNameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	channelNameRegexp   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// ParseExtensionSpec parses an extension spec in the format
+// [channel/]name[:constraint][@stability]. The defaultStability is used when
+// the spec doesn't contain any stability suffix. An error is returned when the
+// spec is malformed or when the stability isn't supported.
+func ParseExtensionSpec(spec string, defaultStability peclapi.Stability) (ExtensionSpec, error) {
+	parsed := ExtensionSpec{
+		Constraint:       "*",
+		MinimumStability: defaultStability,
+	}
+
+	rest := spec
+	if idx := strings.LastIndex(rest, "@"); idx != -1 {
+		rawStability := rest[idx+1:]
+		if rawStability == "" {
+			return parsed, xerrors.Errorf("invalid extension spec %q: empty stability after @", spec)
+		}
+
+		parsed.MinimumStability = peclapi.StabilityFromString(rawStability)
+		if parsed.MinimumStability == peclapi.Unknown {
+			return parsed, xerrors.Errorf("invalid extension spec %q: unsupported stability %q", spec, rawStability)
+		}
+		rest = rest[:idx]
+	}
+
+	if idx := strings.Index(rest, "/"); idx != -1 {
+		parsed.Channel = rest[:idx]
+		rest = rest[idx+1:]
+
+		if parsed.Channel == "" {
+			return parsed, xerrors.Errorf("invalid extension spec %q: empty channel before /", spec)
+		}
+		if !channelNameRegexp.MatchString(parsed.Channel) {
+			return parsed, xerrors.Errorf("invalid extension spec %q: %q is not a valid channel name", spec, parsed.Channel)
+		}
+	}
+
+	segments := strings.SplitN(rest, ":", 2)
+	parsed.Name = segments[0]
+	if len(segments) == 2 {
+		parsed.Constraint = strings.TrimSpace(segments[1])
+		if parsed.Constraint == "" {
+			return parsed, xerrors.Errorf("invalid extension spec %q: empty version constraint after :", spec)
+		}
+	}
+
+	if parsed.Name == "" {
+		return parsed, xerrors.Errorf("invalid extension spec %q: empty extension name", spec)
+	}
+	if !extensionNameRegexp.MatchString(parsed.Name) {
+		return parsed, xerrors.Errorf("invalid extension spec %q: %q is not a valid extension name", spec, parsed.Name)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,126 @@
+package pecl_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/go-test/deep"
+)
+
+type parseExtensionSpecTC struct {
+	spec        string
+	expected    pecl.ExtensionSpec
+	expectedErr error
+}
+
+func TestParseExtensionSpec(t *testing.T) {
+	testcases := map[string]parseExtensionSpecTC{
+		"parse an extension name without constraint": {
+			spec: "redis",
+			expected: pecl.ExtensionSpec{
+				Name:             "redis",
+				Constraint:       "*",
+				MinimumStability: peclapi.Stable,
+			},
+		},
+		"parse an extension name with a constraint": {
+			spec: "redis:~5.1.0",
+			expected: pecl.ExtensionSpec{
+				Name:             "redis",
+				Constraint:       "~5.1.0",
+				MinimumStability: peclapi.Stable,
+			},
+		},
+		"parse an extension name with a constraint and a stability": {
+			spec: "yaml:2.0.0RC8@beta",
+			expected: pecl.ExtensionSpec{
+				Name:             "yaml",
+				Constraint:       "2.0.0RC8",
+				MinimumStability: peclapi.Beta,
+			},
+		},
+		"parse an extension name with a stability but no constraint": {
+			spec: "uv@alpha",
+			expected: pecl.ExtensionSpec{
+				Name:             "uv",
+				Constraint:       "*",
+				MinimumStability: peclapi.Alpha,
+			},
+		},
+		"parse an extension with a channel": {
+			spec: "pecl.example.com/internal_ext:^1.2@beta",
+			expected: pecl.ExtensionSpec{
+				Channel:          "pecl.example.com",
+				Name:             "internal_ext",
+				Constraint:       "^1.2",
+				MinimumStability: peclapi.Beta,
+			},
+		},
+		"parse an extension with a channel alias": {
+			spec: "internal/internal_ext",
+			expected: pecl.ExtensionSpec{
+				Channel:          "internal",
+				Name:             "internal_ext",
+				Constraint:       "*",
+				MinimumStability: peclapi.Stable,
+			},
+		},
+		"fail when the channel is empty": {
+			spec:        "/redis",
+			expectedErr: fmt.Errorf("invalid extension spec \"/redis\": empty channel before /"),
+		},
+		"fail when the channel name is not valid": {
+			spec:        "-pecl/redis",
+			expectedErr: fmt.Errorf("invalid extension spec \"-pecl/redis\": \"-pecl\" is not a valid channel name"),
+		},
+		"fail when the extension name contains a slash": {
+			spec:        "pecl.example.com/sub/redis",
+			expectedErr: fmt.Errorf("invalid extension spec \"pecl.example.com/sub/redis\": \"sub/redis\" is not a valid extension name"),
+		},
+		"fail when the extension name is empty": {
+			spec:        ":~5.1.0",
+			expectedErr: fmt.Errorf("invalid extension spec \":~5.1.0\": empty extension name"),
+		},
+		"fail when the constraint is empty": {
+			spec:        "redis:@beta",
+			expectedErr: fmt.Errorf("invalid extension spec \"redis:@beta\": empty version constraint after :"),
+		},
+		"fail when the stability is empty": {
+			spec:        "redis:~5.1.0@",
+			expectedErr: fmt.Errorf("invalid extension spec \"redis:~5.1.0@\": empty stability after @"),
+		},
+		"fail when the stability is not supported": {
+			spec:        "redis:~5.1.0@rc",
+			expectedErr: fmt.Errorf("invalid extension spec \"redis:~5.1.0@rc\": unsupported stability \"rc\""),
+		},
+		"fail when the extension name is not valid": {
+			spec:        "red is",
+			expectedErr: fmt.Errorf("invalid extension spec \"red is\": \"red is\" is not a valid extension name"),
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			spec, err := pecl.ParseExtensionSpec(tc.spec, peclapi.Stable)
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(spec, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package pecl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NiR-/notpecl/installed"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+type UninstallOpts struct {
+	// Extension is the name of the extension to uninstall.
+	Extension string
+	// InstallDir is the directory where the extension was installed (see
+	// InstallOpts). When the extension was recorded as installed, it's only
+	// used to choose between the installations recorded for the same PHP
+	// installation.
+	InstallDir string
+	// IniDir is the directory where the ini file enabling the extension was
+	// written (see EnableOpts). It defaults to the dir scanned by PHP for
+	// additional ini files.
+	IniDir string
+	// DryRun makes Uninstall return the files it would remove without
+	// removing them.
+	DryRun bool
+}
+
+// Uninstall removes the module of an extension, the headers installed along
+// with it and the ini files enabling it. The module is looked up in the
+// registry of installed extensions (see WithStateDir), for the PHP
+// installation targeted by the backend, or in php-config --extension-dir when
+// it wasn't recorded as installed. It returns the list of files and dirs
+// removed, or that would be removed when opts.DryRun is true.
+func (b backend) Uninstall(ctx context.Context, opts UninstallOpts) ([]string, error) {
+	// The name ends up in paths removed recursively (eg. the headers dir),
+	// such that an empty name or one like .. would remove far more than the
+	// extension.
+	if !extensionNameRegexp.MatchString(opts.Extension) {
+		return nil, xerrors.Errorf("failed to uninstall %q: invalid extension name", opts.Extension)
+	}
+
+	php, err := b.resolvePhpInstallation(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+	}
+
+	extDir, err := b.queryPhpConfig(ctx, php.phpConfig, "--extension-dir")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+	}
+	includeDir, err := b.queryPhpConfig(ctx, php.phpConfig, "--include-dir")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+	}
+
+	var registry installed.Registry
+	registryPath := filepath.Join(b.stateDir, installed.Filename)
+	if b.stateDir != "" {
+		if registry, err = installed.LoadFromFile(b.fs, registryPath); err != nil {
+			return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+		}
+	}
+
+	moduleSubpath := filepath.Join(extDir, opts.Extension+".so")
+	modulePath := filepath.Join(opts.InstallDir, moduleSubpath)
+	record, err := findInstallRecord(registry, opts, php.version, modulePath)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+	}
+
+	// Headers and ini files are looked up in the same install dir as the
+	// module recorded.
+	installDir := opts.InstallDir
+	if record != nil {
+		modulePath = record.ModulePath
+		if strings.HasSuffix(modulePath, moduleSubpath) {
+			installDir = strings.TrimSuffix(modulePath, moduleSubpath)
+		}
+	}
+
+	var toRemove []string
+	moduleExists, err := b.pathExists(modulePath)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+	}
+	if moduleExists {
+		toRemove = append(toRemove, modulePath)
+	}
+
+	// Core and bundled extensions (eg. json or pdo) have headers but no
+	// module, such that the headers alone don't mean the extension was
+	// installed by notpecl.
+	if record == nil && !moduleExists {
+		return nil, xerrors.Errorf("failed to uninstall %s: module %s not found, is the extension installed?", opts.Extension, modulePath)
+	}
+
+	headersDir := filepath.Join(installDir, includeDir, "ext", opts.Extension)
+	if exists, err := b.pathExists(headersDir); err != nil {
+		return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+	} else if exists {
+		toRemove = append(toRemove, headersDir)
+	}
+
+	iniFiles, err := b.findIniFiles(ctx, php, opts, installDir)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+	}
+	toRemove = append(toRemove, iniFiles...)
+
+	if opts.DryRun {
+		return toRemove, nil
+	}
+
+	for _, path := range toRemove {
+		if err := b.fs.RemoveAll(path); err != nil {
+			return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+		}
+		logrus.Infof("Removed %s.", path)
+	}
+
+	if record != nil {
+		registry.Remove(record.ModulePath)
+		if err := registry.WriteToFile(b.fs, registryPath); err != nil {
+			return nil, xerrors.Errorf("failed to uninstall %s: %w", opts.Extension, err)
+		}
+	}
+
+	return toRemove, nil
+}
+
+// pathExists returns true when there's a file, a dir or a symlink at path.
+func (b backend) pathExists(path string) (bool, error) {
+	_, err := b.fs.Lstat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// findInstallRecord returns the installation of the extension recorded for
+// the given PHP version, or nil when there's none. When several were recorded
+// (ie. the extension was installed in several install dirs), the one at the
+// default modulePath is picked.
+func findInstallRecord(
+	registry installed.Registry,
+	opts UninstallOpts,
+	phpVersion string,
+	modulePath string,
+) (*installed.Extension, error) {
+	var records []installed.Extension
+	for _, ext := range registry.Lookup(opts.Extension) {
+		if ext.PHPVersion != phpVersion {
+			continue
+		}
+		if opts.InstallDir != "" && !isWithinDir(ext.ModulePath, opts.InstallDir) {
+			continue
+		}
+		records = append(records, ext)
+	}
+
+	if len(records) == 1 {
+		return &records[0], nil
+	}
+	for i := range records {
+		if records[i].ModulePath == modulePath {
+			return &records[i], nil
+		}
+	}
+	if len(records) > 1 {
+		return nil, xerrors.Errorf(
+			"%s is installed several times for PHP %s, use an install dir to select the one to uninstall",
+			opts.Extension, phpVersion)
+	}
+
+	return nil, nil
+}
+
+// findIniFiles returns the ini files written by Enable for the extension,
+// whatever their priority. No ini files are returned when PHP doesn't scan
+// any dir for additional ini files and no IniDir is provided.
+func (b backend) findIniFiles(ctx context.Context, php phpInstallation, opts UninstallOpts, installDir string) ([]string, error) {
+	iniDir := opts.IniDir
+	if iniDir == "" {
+		scanDir, err := b.findIniScanDir(ctx, php)
+		if err != nil {
+			logrus.Debugf("Not looking for ini files enabling %s: %v", opts.Extension, err)
+			return nil, nil
+		}
+		iniDir = filepath.Join(installDir, scanDir)
+	}
+
+	return b.fs.Glob(filepath.Join(iniDir, "[0-9][0-9]-"+opts.Extension+".ini"))
+}
@@ -0,0 +1,286 @@
+package pecl_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/NiR-/notpecl/cmdexec"
+	"github.com/NiR-/notpecl/installed"
+	"github.com/NiR-/notpecl/pecl"
+	"github.com/go-test/deep"
+	"github.com/twpayne/go-vfs"
+	"github.com/twpayne/go-vfs/vfst"
+	"golang.org/x/xerrors"
+)
+
+const phpIncludeDir = "/usr/include/php"
+
+type uninstallTC struct {
+	files           map[string]interface{}
+	backendOpts     []pecl.BackendOpt
+	opts            pecl.UninstallOpts
+	expectedRemoved []string
+	fsTests         []interface{}
+	expectedErr     error
+}
+
+var (
+	redisInstalledForPhp74 = installed.Extension{
+		Name:        "redis",
+		Version:     "5.1.1",
+		Channel:     "pecl.php.net",
+		PHPVersion:  "7.4.3",
+		PHPAPI:      "20190902",
+		ModulePath:  phpExtensionDir + "/redis.so",
+		Sha256:      moduleSha256,
+		InstalledAt: time.Date(2020, 4, 12, 10, 30, 0, 0, time.UTC),
+	}
+	redisInstalledForPhp80 = installed.Extension{
+		Name:        "redis",
+		Version:     "5.3.2",
+		Channel:     "pecl.php.net",
+		PHPVersion:  "8.0.1",
+		PHPAPI:      "20200930",
+		ModulePath:  "/usr/lib/php/20200930/redis.so",
+		Sha256:      moduleSha256,
+		InstalledAt: time.Date(2020, 4, 12, 10, 30, 0, 0, time.UTC),
+	}
+)
+
+func newUninstallExecutor() cmdexec.CmdExecutor {
+	return newEnableExecutor("/etc/php/conf.d").With(
+		cmdexec.FakeOn([]string{phpconfigPath, "--include-dir"},
+			cmdexec.FakeStdout(phpIncludeDir+"\n")))
+}
+
+func marshalRegistry(t *testing.T, extensions ...installed.Extension) string {
+	raw, err := json.Marshal(installed.Registry{Extensions: extensions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+// testRegistryContains returns a vfst.PathTest checking the registry at the
+// given path contains exactly the given extensions.
+func testRegistryContains(expected ...installed.Extension) func(*testing.T, vfs.FS, string) {
+	return func(t *testing.T, fs vfs.FS, path string) {
+		r, err := installed.LoadFromFile(fs, path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if diff := deep.Equal(r.List(), expected); diff != nil {
+			t.Fatal(diff)
+		}
+	}
+}
+
+func initSuccessfullyUninstallRecordedRedisTC(t *testing.T) uninstallTC {
+	return uninstallTC{
+		files: map[string]interface{}{
+			stateDir + "/installed.json":                     marshalRegistry(t, redisInstalledForPhp74, redisInstalledForPhp80),
+			phpExtensionDir + "/redis.so":                    moduleContent,
+			"/usr/lib/php/20200930/redis.so":                 moduleContent,
+			phpIncludeDir + "/ext/redis/php_redis.h":         "",
+			phpIncludeDir + "/ext/redis/redis_commands.h":    "",
+			"/etc/php/conf.d/20-redis.ini":                   "; Written by notpecl\nextension=redis.so\n",
+			"/etc/php/conf.d/20-redis-sessions.ini":          "redis.session.locking_enabled=1\n",
+			"/etc/php/conf.d/docker-php-ext-redis-extra.ini": "",
+		},
+		backendOpts: []pecl.BackendOpt{pecl.WithStateDir(stateDir)},
+		opts: pecl.UninstallOpts{
+			Extension: "redis",
+		},
+		expectedRemoved: []string{
+			phpExtensionDir + "/redis.so",
+			phpIncludeDir + "/ext/redis",
+			"/etc/php/conf.d/20-redis.ini",
+		},
+		fsTests: []interface{}{
+			vfst.TestPath(phpExtensionDir+"/redis.so", vfst.TestDoesNotExist),
+			vfst.TestPath(phpIncludeDir+"/ext/redis", vfst.TestDoesNotExist),
+			vfst.TestPath("/etc/php/conf.d/20-redis.ini", vfst.TestDoesNotExist),
+			vfst.TestPath("/etc/php/conf.d/20-redis-sessions.ini", vfst.TestModeIsRegular),
+			vfst.TestPath("/etc/php/conf.d/docker-php-ext-redis-extra.ini", vfst.TestModeIsRegular),
+			vfst.TestPath("/usr/lib/php/20200930/redis.so", vfst.TestModeIsRegular),
+			vfst.TestPath(stateDir+"/installed.json", testRegistryContains(redisInstalledForPhp80)),
+		},
+	}
+}
+
+func initSuccessfullyDryRunUninstallOfRedisTC(t *testing.T) uninstallTC {
+	tc := initSuccessfullyUninstallRecordedRedisTC(t)
+	tc.opts.DryRun = true
+	tc.fsTests = []interface{}{
+		vfst.TestPath(phpExtensionDir+"/redis.so", vfst.TestModeIsRegular),
+		vfst.TestPath(phpIncludeDir+"/ext/redis/php_redis.h", vfst.TestModeIsRegular),
+		vfst.TestPath("/etc/php/conf.d/20-redis.ini", vfst.TestModeIsRegular),
+		vfst.TestPath(stateDir+"/installed.json",
+			testRegistryContains(redisInstalledForPhp74, redisInstalledForPhp80)),
+	}
+	return tc
+}
+
+func initSuccessfullyUninstallRedisRecordedInAnInstallDirTC(t *testing.T) uninstallTC {
+	record := redisInstalledForPhp74
+	record.ModulePath = "/installdir" + phpExtensionDir + "/redis.so"
+
+	return uninstallTC{
+		files: map[string]interface{}{
+			stateDir + "/installed.json":                             marshalRegistry(t, record, redisInstalledForPhp80),
+			"/installdir" + phpExtensionDir + "/redis.so":            moduleContent,
+			"/installdir" + phpIncludeDir + "/ext/redis/php_redis.h": "",
+			"/installdir/etc/php/conf.d/30-redis.ini":                "; Written by notpecl\nextension=redis.so\n",
+			"/etc/php/conf.d/20-redis.ini":                           "; Written by notpecl\nextension=redis.so\n",
+		},
+		backendOpts: []pecl.BackendOpt{pecl.WithStateDir(stateDir)},
+		opts: pecl.UninstallOpts{
+			Extension: "redis",
+		},
+		expectedRemoved: []string{
+			"/installdir" + phpExtensionDir + "/redis.so",
+			"/installdir" + phpIncludeDir + "/ext/redis",
+			"/installdir/etc/php/conf.d/30-redis.ini",
+		},
+		fsTests: []interface{}{
+			vfst.TestPath("/installdir"+phpExtensionDir+"/redis.so", vfst.TestDoesNotExist),
+			vfst.TestPath("/installdir/etc/php/conf.d/30-redis.ini", vfst.TestDoesNotExist),
+			vfst.TestPath("/etc/php/conf.d/20-redis.ini", vfst.TestModeIsRegular),
+			vfst.TestPath(stateDir+"/installed.json", testRegistryContains(redisInstalledForPhp80)),
+		},
+	}
+}
+
+func initSuccessfullyUninstallRedisNotRecordedTC(t *testing.T) uninstallTC {
+	return uninstallTC{
+		files: map[string]interface{}{
+			phpExtensionDir + "/redis.so":  moduleContent,
+			"/etc/php/conf.d/20-redis.ini": "; Written by notpecl\nextension=redis.so\n",
+		},
+		opts: pecl.UninstallOpts{
+			Extension: "redis",
+		},
+		expectedRemoved: []string{
+			phpExtensionDir + "/redis.so",
+			"/etc/php/conf.d/20-redis.ini",
+		},
+		fsTests: []interface{}{
+			vfst.TestPath(phpExtensionDir+"/redis.so", vfst.TestDoesNotExist),
+			vfst.TestPath("/etc/php/conf.d/20-redis.ini", vfst.TestDoesNotExist),
+		},
+	}
+}
+
+func initFailToUninstallAnExtensionNotInstalledTC(t *testing.T) uninstallTC {
+	return uninstallTC{
+		files: map[string]interface{}{
+			stateDir + "/installed.json": marshalRegistry(t, redisInstalledForPhp80),
+		},
+		backendOpts: []pecl.BackendOpt{pecl.WithStateDir(stateDir)},
+		opts: pecl.UninstallOpts{
+			Extension: "redis",
+		},
+		expectedErr: xerrors.Errorf("failed to uninstall redis: module %s/redis.so not found, is the extension installed?", phpExtensionDir),
+	}
+}
+
+func initFailToUninstallABundledExtensionWithOnlyHeadersTC(t *testing.T) uninstallTC {
+	return uninstallTC{
+		files: map[string]interface{}{
+			phpIncludeDir + "/ext/json/php_json.h": "",
+			"/etc/php/conf.d/20-json.ini":          "extension=json.so\n",
+		},
+		opts: pecl.UninstallOpts{
+			Extension: "json",
+		},
+		expectedErr: xerrors.Errorf("failed to uninstall json: module %s/json.so not found, is the extension installed?", phpExtensionDir),
+		fsTests: []interface{}{
+			vfst.TestPath(phpIncludeDir+"/ext/json/php_json.h", vfst.TestModeIsRegular),
+			vfst.TestPath("/etc/php/conf.d/20-json.ini", vfst.TestModeIsRegular),
+		},
+	}
+}
+
+func initFailToUninstallAnExtensionWithAnEmptyNameTC(t *testing.T) uninstallTC {
+	return uninstallTC{
+		files: map[string]interface{}{
+			phpIncludeDir + "/ext/standard/php_string.h": "",
+		},
+		opts: pecl.UninstallOpts{
+			Extension: "",
+		},
+		expectedErr: xerrors.Errorf("failed to uninstall \"\": invalid extension name"),
+		fsTests: []interface{}{
+			vfst.TestPath(phpIncludeDir+"/ext/standard/php_string.h", vfst.TestModeIsRegular),
+		},
+	}
+}
+
+func initFailToUninstallAnExtensionNamedDotDotTC(t *testing.T) uninstallTC {
+	return uninstallTC{
+		files: map[string]interface{}{
+			phpIncludeDir + "/main/php.h": "",
+		},
+		opts: pecl.UninstallOpts{
+			Extension: "..",
+		},
+		expectedErr: xerrors.Errorf("failed to uninstall \"..\": invalid extension name"),
+		fsTests: []interface{}{
+			vfst.TestPath(phpIncludeDir+"/main/php.h", vfst.TestModeIsRegular),
+		},
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	testcases := map[string]func(*testing.T) uninstallTC{
+		"successfully uninstall redis recorded as installed":      initSuccessfullyUninstallRecordedRedisTC,
+		"successfully dry-run the uninstall of redis":             initSuccessfullyDryRunUninstallOfRedisTC,
+		"successfully uninstall redis recorded in an install dir": initSuccessfullyUninstallRedisRecordedInAnInstallDirTC,
+		"successfully uninstall redis not recorded as installed":  initSuccessfullyUninstallRedisNotRecordedTC,
+		"fail to uninstall an extension not installed":            initFailToUninstallAnExtensionNotInstalledTC,
+		"fail to uninstall a bundled extension with only headers": initFailToUninstallABundledExtensionWithOnlyHeadersTC,
+		"fail to uninstall an extension with an empty name":       initFailToUninstallAnExtensionWithAnEmptyNameTC,
+		"fail to uninstall an extension named ..":                 initFailToUninstallAnExtensionNamedDotDotTC,
+	}
+
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+			fs, cleanup, err := vfst.NewTestFS(tc.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			opts := append([]pecl.BackendOpt{
+				pecl.WithFS(fs),
+				pecl.WithCmdExec(newUninstallExecutor()),
+				pecl.WithPhpConfigPath(phpconfigPath),
+			}, tc.backendOpts...)
+			backend := pecl.New(opts...)
+
+			removed, err := backend.Uninstall(context.Background(), tc.opts)
+			if tc.expectedErr != nil {
+				if err == nil || err.Error() != tc.expectedErr.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				vfst.RunTests(t, fs, "preserved files", tc.fsTests...)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(removed, tc.expectedRemoved); diff != nil {
+				t.Fatal(diff)
+			}
+			vfst.RunTests(t, fs, "uninstalled files", tc.fsTests...)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package pecl
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/NiR-/notpecl/peclpkg"
+	"github.com/sirupsen/logrus"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+// IntegrityError is returned by Download when the extracted files don't match
+// the <contents> section of the package.xml of the release.
+type IntegrityError struct {
+	// Missing lists the files declared by package.xml but not found in the
+	// archive.
+	Missing []string
+	// Extra lists the files found in the archive but not declared by
+	// package.xml.
+	Extra []string
+	// Corrupted lists the files whose md5sum doesn't match the one declared
+	// by package.xml.
+	Corrupted []string
+}
+
+func (err IntegrityError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "extracted files don't match package.xml (%d mismatches):",
+		len(err.Missing)+len(err.Extra)+len(err.Corrupted))
+
+	for _, f := range err.Missing {
+		fmt.Fprintf(&b, "\n  - missing: %s", f)
+	}
+	for _, f := range err.Extra {
+		fmt.Fprintf(&b, "\n  - extra: %s", f)
+	}
+	for _, f := range err.Corrupted {
+		fmt.Fprintf(&b, "\n  - corrupted: %s", f)
+	}
+
+	return b.String()
+}
+
+func (err IntegrityError) empty() bool {
+	return len(err.Missing) == 0 && len(err.Extra) == 0 && len(err.Corrupted) == 0
+}
+
+// packageXMLFiles are the files shipped in release archives next to the
+// source dir, and thus not listed in <contents>.
+var packageXMLFiles = map[string]struct{}{
+	"package.xml":  {},
+	"package2.xml": {},
+}
+
+// verifyExtractedFiles checks the files extracted to extDir against the
+// <contents> section of extDir/package.xml. An IntegrityError listing every
+// mismatch is returned when they don't match.
+func (b backend) verifyExtractedFiles(extDir string) error {
+	pkgXmlPath := filepath.Join(extDir, "package.xml")
+	if _, err := b.fs.Stat(pkgXmlPath); os.IsNotExist(err) {
+		logrus.Warnf("Could not verify the files extracted to %s: package.xml not found.", extDir)
+		return nil
+	}
+
+	xmlPath, err := b.fs.RawPath(pkgXmlPath)
+	if err != nil {
+		return xerrors.Errorf("could not verify extracted files: %w", err)
+	}
+	pkg, err := peclpkg.LoadPackageXMLFromFile(xmlPath)
+	if err != nil {
+		return xerrors.Errorf("could not verify extracted files: failed to load package.xml: %w", err)
+	}
+
+	var report IntegrityError
+	declared := map[string]struct{}{}
+
+	for _, f := range pkg.Contents.Files() {
+		declared[f.Name] = struct{}{}
+
+		// Names come from the archive, and are thus checked like the
+		// entries extracted.
+		path, err := joinWithinDir(extDir, f.Name)
+		if err != nil {
+			return xerrors.Errorf("could not verify %s: %w", f.Name, err)
+		}
+
+		actual, err := b.md5sum(path)
+		if os.IsNotExist(err) {
+			report.Missing = append(report.Missing, f.Name)
+			continue
+		} else if err != nil {
+			return xerrors.Errorf("could not verify %s: %w", f.Name, err)
+		}
+
+		if f.MD5Sum != "" && !strings.EqualFold(f.MD5Sum, actual) {
+			report.Corrupted = append(report.Corrupted,
+				fmt.Sprintf("%s (expected md5 %s, got %s)", f.Name, f.MD5Sum, actual))
+		}
+	}
+
+	err = vfs.Walk(b.fs, extDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(extDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if _, ok := packageXMLFiles[rel]; ok {
+			return nil
+		}
+		if _, ok := declared[rel]; !ok {
+			report.Extra = append(report.Extra, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("could not verify extracted files: %w", err)
+	}
+
+	if report.empty() {
+		logrus.Debugf("All files extracted to %s match package.xml.", extDir)
+		return nil
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.Corrupted)
+	return report
+}
+
+func (b backend) md5sum(path string) (string, error) {
+	f, err := b.fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,85 @@
+package peclapi
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// DefaultChannel is the name of the channel used when none is specified.
+const DefaultChannel = "pecl.php.net"
+
+// Channel represents the description of a PEAR channel, as served at
+// /channel.xml by channel servers.
+// See https://pear.php.net/dtd/channel-1.0.xsd.
+type Channel struct {
+	Name           string           `xml:"name"`
+	SuggestedAlias string           `xml:"suggestedalias"`
+	Summary        string           `xml:"summary"`
+	BaseURLs       []ChannelBaseURL `xml:"servers>primary>rest>baseurl"`
+}
+
+// ChannelBaseURL is a REST base URL advertised by a channel, along with the
+// version of the REST API served (eg. REST1.0).
+type ChannelBaseURL struct {
+	Type string `xml:"type,attr"`
+	URL  string `xml:",chardata"`
+}
+
+// RESTBaseURL returns the base URL of the REST API of the channel, without
+// trailing slash. Client only uses REST1.0 and REST1.1 endpoints, so one of
+// these is required. An error is returned if the channel doesn't advertise
+// any of them.
+func (c Channel) RESTBaseURL() (string, error) {
+	for _, apiType := range []string{"REST1.0", "REST1.1"} {
+		for _, baseURL := range c.BaseURLs {
+			if baseURL.Type == apiType && strings.TrimSpace(baseURL.URL) != "" {
+				return strings.TrimRight(strings.TrimSpace(baseURL.URL), "/"), nil
+			}
+		}
+	}
+	return "", xerrors.Errorf("channel %s doesn't advertise any REST1.0 or REST1.1 base URL", c.Name)
+}
+
+// DiscoverChannel fetches the description of the given channel. The channel
+// is either a channel name (eg. pecl.php.net), in which case its description
+// is fetched from https://{channel}/channel.xml, or the full URL of a
+// channel.xml file. It returns an error if the request fails, if the
+// endpoint returns a non-200 status code or if the channel doesn't advertise
+// a usable REST base URL.
+func (c Client) DiscoverChannel(ctx context.Context, channel string) (Channel, error) {
+	var ch Channel
+
+	url := channel
+	if !strings.Contains(channel, "://") {
+		url = fmt.Sprintf("https://%s/channel.xml", channel)
+	}
+
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return ch, xerrors.Errorf("could not discover channel %s: %w", channel, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return ch, xerrors.Errorf("could not discover channel %s: channel.xml not found", channel)
+	}
+	if resp.StatusCode != 200 {
+		return ch, xerrors.Errorf("could not discover channel %s: expected status code 200, got %d", channel, resp.StatusCode)
+	}
+
+	decoder := xml.NewDecoder(resp.Body)
+	decoder.CharsetReader = charsetReader
+
+	if err := decoder.Decode(&ch); err != nil {
+		return ch, xerrors.Errorf("could not discover channel %s: %w", channel, err)
+	}
+	if _, err := ch.RESTBaseURL(); err != nil {
+		return ch, xerrors.Errorf("could not discover channel %s: %w", channel, err)
+	}
+
+	return ch, nil
+}
@@ -0,0 +1,118 @@
+package peclapi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/go-test/deep"
+)
+
+type discoverChannelTC struct {
+	channel         string
+	httpClient      *http.Client
+	expected        peclapi.Channel
+	expectedBaseURL string
+	expectedErr     error
+}
+
+func initDiscoverChannelByNameTC(t *testing.T) discoverChannelTC {
+	expectedURL := "https://pecl.example.com/channel.xml"
+	body := loadTestdata(t, "testdata/channel.xml")
+	roundTripper := newTestRoundTripper(t, expectedURL, 200, body)
+
+	return discoverChannelTC{
+		channel:    "pecl.example.com",
+		httpClient: newTestClient(roundTripper),
+		expected: peclapi.Channel{
+			Name:           "pecl.example.com",
+			SuggestedAlias: "internal",
+			Summary:        "Internal PHP extensions",
+			BaseURLs: []peclapi.ChannelBaseURL{
+				{Type: "REST1.0", URL: "https://pecl.example.com/rest/"},
+				{Type: "REST1.1", URL: "https://pecl.example.com/rest/"},
+			},
+		},
+		expectedBaseURL: "https://pecl.example.com/rest",
+	}
+}
+
+func initDiscoverChannelByURLTC(t *testing.T) discoverChannelTC {
+	expectedURL := "http://pear.internal:8080/channel.xml"
+	body := loadTestdata(t, "testdata/channel.xml")
+	roundTripper := newTestRoundTripper(t, expectedURL, 200, body)
+
+	tc := initDiscoverChannelByNameTC(t)
+	tc.channel = expectedURL
+	tc.httpClient = newTestClient(roundTripper)
+
+	return tc
+}
+
+func initFailToDiscoverChannelWhenNotFoundTC(t *testing.T) discoverChannelTC {
+	expectedURL := "https://pecl.example.com/channel.xml"
+	roundTripper := newTestRoundTripper(t, expectedURL, 404, "")
+
+	return discoverChannelTC{
+		channel:     "pecl.example.com",
+		httpClient:  newTestClient(roundTripper),
+		expectedErr: fmt.Errorf("could not discover channel pecl.example.com: channel.xml not found"),
+	}
+}
+
+func initFailToDiscoverChannelWithoutRESTBaseURLTC(t *testing.T) discoverChannelTC {
+	expectedURL := "https://pecl.example.com/channel.xml"
+	body := loadTestdata(t, "testdata/channel-without-rest.xml")
+	roundTripper := newTestRoundTripper(t, expectedURL, 200, body)
+
+	return discoverChannelTC{
+		channel:     "pecl.example.com",
+		httpClient:  newTestClient(roundTripper),
+		expectedErr: fmt.Errorf("could not discover channel pecl.example.com: channel pecl.example.com doesn't advertise any REST1.0 or REST1.1 base URL"),
+	}
+}
+
+func TestDiscoverChannel(t *testing.T) {
+	testcases := map[string]func(*testing.T) discoverChannelTC{
+		"successfully discover a channel by name":    initDiscoverChannelByNameTC,
+		"successfully discover a channel by URL":     initDiscoverChannelByURLTC,
+		"fail when channel.xml is not found":         initFailToDiscoverChannelWhenNotFoundTC,
+		"fail when the channel has no REST base URL": initFailToDiscoverChannelWithoutRESTBaseURLTC,
+	}
+
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
+
+			ch, err := client.DiscoverChannel(context.Background(), tc.channel)
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(ch, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+
+			baseURL, err := ch.RESTBaseURL()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if baseURL != tc.expectedBaseURL {
+				t.Fatalf("Expected base URL: %s - Got: %s", tc.expectedBaseURL, baseURL)
+			}
+		})
+	}
+}
@@ -1,34 +1,38 @@
 // peclapi package implements a HTTP-based client for the REST API initially
-// used by pecl. It only supports the default pecl channel served at
-// https://pecl.php.net/rest/.
+// used by pecl. By default, it queries the default pecl channel served at
+// https://pecl.php.net/rest/, but any PEAR-compatible channel server can be
+// used through WithBaseURI(). The REST base URL of a channel can be found with
+// DiscoverChannel().
 // Note that this API is quite dated and might return some unexpected results
 // (eg. redis extension is in the Database category but ListPackagesInCategory("Database")
 // won't return redis).
 package peclapi
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"sort"
 
+	"github.com/NiR-/notpecl/peclpkg"
 	"github.com/mcuadros/go-version"
+	"github.com/twpayne/go-vfs"
 	"golang.org/x/text/encoding/ianaindex"
 	"golang.org/x/text/transform"
 	"golang.org/x/xerrors"
 )
 
 // NewClient creates a new HTTP-based client for the API hosted at
-// pecl.php.net/rest/. It takes ClientOpt as arguments. These could be used to
-// set Client's internal properties (baseURI or httpClient).
+// pecl.php.net/rest/ (unless another base URI is provided). It takes
+// ClientOpt as arguments. These could be used to set Client's internal
+// properties (baseURI, httpClient, retryPolicy or fs).
 func NewClient(opts ...ClientOpt) Client {
 	c := Client{
 		baseURI:    "https://pecl.php.net/rest",
 		httpClient: &http.Client{},
+		fs:         vfs.HostOSFS,
 	}
 	for _, opt := range opts {
 		opt(&c)
@@ -42,8 +46,10 @@ type ClientOpt func(*Client)
 
 // Client represents the HTTP-based client for https://pecl.php.net/rest/.
 type Client struct {
-	baseURI    string
-	httpClient *http.Client
+	baseURI     string
+	httpClient  *http.Client
+	retryPolicy RetryPolicy
+	fs          vfs.FS
 }
 
 // WithBaseURI returns a ClientOpt that could be passed to NewClient to set the
@@ -62,6 +68,40 @@ func WithHttpClient(httpClient *http.Client) ClientOpt {
 	}
 }
 
+// get sends a GET request to the given URL. The request is canceled when ctx
+// is done and it's retried according to the retry policy of the Client. When
+// the retry policy tells to give up, the last response or error is returned.
+func (c Client) get(ctx context.Context, url string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.getOnce(ctx, url, nil)
+		if !c.retryPolicy.shouldRetry(ctx, attempt, resp, err) {
+			return resp, err
+		}
+
+		delay := c.retryPolicy.Delay(attempt, resp)
+		logRetry(url, attempt, c.retryPolicy, delay, resp, err)
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c Client) getOnce(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	return c.httpClient.Do(req)
+}
+
 type packageList struct {
 	Packages []string `xml:"p"`
 }
@@ -69,9 +109,9 @@ type packageList struct {
 // ListPackages returns the list of packages available at the endpoint
 // /p/packages.xml. It returns an error if the HTTP request fails or if the
 // endpoint returns a non-200 status code.
-func (c Client) ListPackages() ([]string, error) {
+func (c Client) ListPackages(ctx context.Context) ([]string, error) {
 	url := fmt.Sprintf("%s/p/packages.xml", c.baseURI)
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return []string{}, err
 	}
@@ -95,9 +135,9 @@ func (c Client) ListPackages() ([]string, error) {
 // ListPackagesInCategory returns the list of packages in the given category,
 // as served by the endpoint /c/{category}/package.xml. It returns an error if
 // the request fails or if th endpoint returns a non-200 status code.
-func (c Client) ListPackagesInCategory(category string) ([]string, error) {
+func (c Client) ListPackagesInCategory(ctx context.Context, category string) ([]string, error) {
 	url := fmt.Sprintf("%s/c/%s/packages.xml", c.baseURI, category)
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return []string{}, err
 	}
@@ -138,11 +178,11 @@ type Package struct {
 // DescribePackage returns the details of a given package as served by the
 // endpoint /p/{packageName}/info.xml. It returns an error if the request
 // fails or if the endpoint returns a non-200 status code.
-func (c Client) DescribePackage(pkgName string) (Package, error) {
+func (c Client) DescribePackage(ctx context.Context, pkgName string) (Package, error) {
 	var pkg Package
 
 	url := fmt.Sprintf("%s/p/%s/info.xml", c.baseURI, pkgName)
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return pkg, xerrors.Errorf("could not describe package %s: %w", pkgName, err)
 	}
@@ -256,11 +296,11 @@ func (s versionSlice) Swap(i, j int) {
 // for a given package, as served by the endpoint /r/{packageName}/allreleases.xml.
 // It returns an error if the request fails or if the endpoint returns a
 // non-200 status code.
-func (c Client) ListReleases(pkgName string) (PackageReleases, error) {
+func (c Client) ListReleases(ctx context.Context, pkgName string) (PackageReleases, error) {
 	releases := make(PackageReleases)
 
 	url := fmt.Sprintf("%s/r/%s/allreleases.xml", c.baseURI, pkgName)
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return releases, err
 	}
@@ -291,11 +331,11 @@ func (c Client) ListReleases(pkgName string) (PackageReleases, error) {
 // package, as served by the endpoint /r/{packageName}/{release}.xml. It
 // returns an error if the request fails or if the endpoint returns a
 // non-200 status code.
-func (c Client) DescribeRelease(pkgName, pkgVersion string) (Release, error) {
+func (c Client) DescribeRelease(ctx context.Context, pkgName, pkgVersion string) (Release, error) {
 	var release Release
 
 	url := fmt.Sprintf("%s/r/%s/%s.xml", c.baseURI, pkgName, pkgVersion)
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return release, err
 	}
@@ -318,6 +358,33 @@ func (c Client) DescribeRelease(pkgName, pkgVersion string) (Release, error) {
 	return release, nil
 }
 
+// DescribeReleasePackage returns the package.xml of a given release of a
+// given package, as served by the endpoint /r/{packageName}/package.{release}.xml.
+// It returns an error if the request fails or if the endpoint returns a
+// non-200 status code.
+func (c Client) DescribeReleasePackage(ctx context.Context, pkgName, pkgVersion string) (peclpkg.Package, error) {
+	url := fmt.Sprintf("%s/r/%s/package.%s.xml", c.baseURI, pkgName, pkgVersion)
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return peclpkg.Package{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return peclpkg.Package{}, xerrors.Errorf("could not load package.xml of %s release %s: release not found", pkgName, pkgVersion)
+	}
+	if resp.StatusCode != 200 {
+		return peclpkg.Package{}, xerrors.Errorf("could not load package.xml of %s release %s: expected status code 200, got %d", pkgName, pkgVersion, resp.StatusCode)
+	}
+
+	pkg, err := peclpkg.LoadPackageXML(resp.Body)
+	if err != nil {
+		return pkg, xerrors.Errorf("could not load package.xml of %s release %s: %w", pkgName, pkgVersion, err)
+	}
+
+	return pkg, nil
+}
+
 // Release represents the details of a specific release of a package.
 // See https://pear.php.net/dtd/rest.release.xsd.
 type Release struct {
@@ -334,47 +401,14 @@ type Release struct {
 	PackageXML   string `xml:"x"`
 }
 
-// DownloadRelease downloads a given package release and returns an
-// io.ReadCloser from which a tgz can be read. An error is returned if the HTTP
-// request fails, if a bad status code is returned or if the downloaded file is
-// not an application/x-gzip.
-func (c Client) DownloadRelease(release Release) (io.Reader, error) {
-	if release.PartialURI == "" {
-		return nil, xerrors.Errorf("empty PartialURI")
-	}
-
+// ArchiveURL returns the URL where the tgz archive of the release can be
+// downloaded.
+func (r Release) ArchiveURL() string {
 	// PartialURI is truly partial: that is, the URI doesn't contain the file
 	// extension. As it looks like pecl.php.net always uses .tgz extension,
 	// it's appended to the PartialURI to obtain the URL where the archive
 	// can be downloaded.
-	url := fmt.Sprintf("%s.tgz", release.PartialURI)
-	resp, err := c.httpClient.Get(url)
-	if err != nil {
-		return nil, xerrors.Errorf("could not download %s v%s: %w", release.Package, release.Version, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, xerrors.Errorf("could not download %s v%s: expected status code 200, got %d", release.Package, release.Version, resp.StatusCode)
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, xerrors.Errorf("could not download %s v%s: %w", release.Package, release.Version, err)
-	}
-
-	rawr := bufio.NewReaderSize(bytes.NewReader(body), int(resp.ContentLength))
-	testBytes, err := rawr.Peek(64)
-	if err != nil {
-		return nil, xerrors.Errorf("could not peek the first 64 bytes of the downloaded file: %w", err)
-	}
-
-	contentType := http.DetectContentType(testBytes)
-	if contentType != "application/x-gzip" {
-		return nil, xerrors.Errorf("the file downloaded at %s isn't a gzip file", url)
-	}
-
-	return rawr, nil
+	return fmt.Sprintf("%s.tgz", r.PartialURI)
 }
 
 func charsetReader(charset string, input io.Reader) (io.Reader, error) {
@@ -1,15 +1,15 @@
 package peclapi_test
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"testing"
 
 	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclpkg"
 	"github.com/go-test/deep"
 )
 
@@ -113,7 +113,7 @@ func TestListPackages(t *testing.T) {
 			tc := tcinit(t)
 			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
 
-			list, err := client.ListPackages()
+			list, err := client.ListPackages(context.Background())
 			if tc.expectedErr != nil {
 				if err == nil || tc.expectedErr.Error() != err.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
@@ -190,7 +190,7 @@ func TestListPackagesInCategory(t *testing.T) {
 			tc := tcinit(t)
 			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
 
-			list, err := client.ListPackagesInCategory("Database")
+			list, err := client.ListPackagesInCategory(context.Background(), "Database")
 			if tc.expectedErr != nil {
 				if err == nil || tc.expectedErr.Error() != err.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
@@ -279,7 +279,7 @@ func TestDescribePackage(t *testing.T) {
 			tc := tcinit(t)
 			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
 
-			pkg, err := client.DescribePackage("redis")
+			pkg, err := client.DescribePackage(context.Background(), "redis")
 			if tc.expectedErr != nil {
 				if err.Error() != tc.expectedErr.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
@@ -370,7 +370,7 @@ func TestListReleases(t *testing.T) {
 			tc := tcinit(t)
 			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
 
-			releases, err := client.ListReleases("redis")
+			releases, err := client.ListReleases(context.Background(), "redis")
 			if tc.expectedErr != nil {
 				if err == nil || tc.expectedErr.Error() != err.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
@@ -510,7 +510,7 @@ func TestDescribeRelease(t *testing.T) {
 			tc := tcinit(t)
 			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
 
-			release, err := client.DescribeRelease("redis", "5.2.0")
+			release, err := client.DescribeRelease(context.Background(), "redis", "5.2.0")
 			if tc.expectedErr != nil {
 				if err == nil || tc.expectedErr.Error() != err.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
@@ -528,99 +528,46 @@ func TestDescribeRelease(t *testing.T) {
 	}
 }
 
-type downloadReleaseTC struct {
-	release     peclapi.Release
+type describeReleasePackageTC struct {
 	httpClient  *http.Client
-	expected    io.Reader
 	expectedErr error
 }
 
-func initSuccessfullyDownloadReleaseTC(t *testing.T) downloadReleaseTC {
-	expectedURL := "https://pecl.php.net/get/redis-5.1.1.tgz"
-	body := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
-
-	roundTripper := func(req *http.Request) *http.Response {
-		if req.URL.String() != expectedURL {
-			t.Fatalf("Expected URL: %s - Got: %s", expectedURL, req.URL)
-		}
-
-		return &http.Response{
-			StatusCode:    200,
-			Body:          ioutil.NopCloser(bytes.NewBuffer(body)),
-			ContentLength: int64(len(body)),
-		}
-	}
+func initSuccessfullyDescribeReleasePackageTC(t *testing.T) describeReleasePackageTC {
+	expectedURL := "https://pecl.php.net/rest/r/redis/package.5.1.1.xml"
+	body := loadTestdata(t, "testdata/redis-package-5.1.1.xml")
+	roundTripper := newTestRoundTripper(t, expectedURL, 200, body)
 
-	return downloadReleaseTC{
-		release: peclapi.Release{
-			Package:    "redis",
-			Version:    "5.1.1",
-			PartialURI: "https://pecl.php.net/get/redis-5.1.1",
-		},
+	return describeReleasePackageTC{
 		httpClient: newTestClient(roundTripper),
-		expected:   bufio.NewReader(bytes.NewBuffer(body)),
 	}
 }
 
-func initFailToDownloadReleaseWhenStatusCodeIsNot200TC(t *testing.T) downloadReleaseTC {
-	expectedURL := "https://pecl.php.net/get/redis-5.1.1.tgz"
-
-	roundTripper := func(req *http.Request) *http.Response {
-		if req.URL.String() != expectedURL {
-			t.Fatalf("Expected URL: %s - Got: %s", expectedURL, req.URL)
-		}
-
-		return &http.Response{
-			StatusCode: 500,
-			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
-		}
-	}
+func initFailToDescribeReleasePackageWhenEndpointReturns404TC(t *testing.T) describeReleasePackageTC {
+	expectedURL := "https://pecl.php.net/rest/r/redis/package.5.1.1.xml"
+	roundTripper := newTestRoundTripper(t, expectedURL, 404, "")
 
-	return downloadReleaseTC{
-		release: peclapi.Release{
-			Package:    "redis",
-			Version:    "5.1.1",
-			PartialURI: "https://pecl.php.net/get/redis-5.1.1",
-		},
+	return describeReleasePackageTC{
 		httpClient:  newTestClient(roundTripper),
-		expectedErr: fmt.Errorf("could not download redis v5.1.1: expected status code 200, got 500"),
+		expectedErr: fmt.Errorf("could not load package.xml of redis release 5.1.1: release not found"),
 	}
 }
 
-func initFailToDownloadReleaseWhenDownloadedFileHasWrongMimeType(t *testing.T) downloadReleaseTC {
-	expectedURL := "https://pecl.php.net/get/redis-5.1.1.tgz"
-	// redis-5.2.0.xml is used here because it's more than 64-bytes long and
-	// this test requires any file that's not a gzip file.
-	body := loadRawTestdata(t, "testdata/redis-5.2.0.xml")
-
-	roundTripper := func(req *http.Request) *http.Response {
-		if req.URL.String() != expectedURL {
-			t.Fatalf("Expected URL: %s - Got: %s", expectedURL, req.URL)
-		}
-
-		return &http.Response{
-			StatusCode:    200,
-			Body:          ioutil.NopCloser(bytes.NewBuffer(body)),
-			ContentLength: int64(len(body)),
-		}
-	}
+func initFailToDescribeReleasePackageWhenEndpointFailsTC(t *testing.T) describeReleasePackageTC {
+	expectedURL := "https://pecl.php.net/rest/r/redis/package.5.1.1.xml"
+	roundTripper := newTestRoundTripper(t, expectedURL, 500, "")
 
-	return downloadReleaseTC{
-		release: peclapi.Release{
-			Package:    "redis",
-			Version:    "5.1.1",
-			PartialURI: "https://pecl.php.net/get/redis-5.1.1",
-		},
+	return describeReleasePackageTC{
 		httpClient:  newTestClient(roundTripper),
-		expectedErr: fmt.Errorf("the file downloaded at https://pecl.php.net/get/redis-5.1.1.tgz isn't a gzip file"),
+		expectedErr: fmt.Errorf("could not load package.xml of redis release 5.1.1: expected status code 200, got 500"),
 	}
 }
 
-func TestDownloadRelease(t *testing.T) {
-	testcases := map[string]func(*testing.T) downloadReleaseTC{
-		"successfully download release":                  initSuccessfullyDownloadReleaseTC,
-		"fails when status code is not 200":              initFailToDownloadReleaseWhenStatusCodeIsNot200TC,
-		"fails when downloaded file has wrong mime type": initFailToDownloadReleaseWhenDownloadedFileHasWrongMimeType,
+func TestDescribeReleasePackage(t *testing.T) {
+	testcases := map[string]func(*testing.T) describeReleasePackageTC{
+		"successfully describe the package of redis v5.1.1":          initSuccessfullyDescribeReleasePackageTC,
+		"fail to describe release package when endpoint returns 404": initFailToDescribeReleasePackageWhenEndpointReturns404TC,
+		"fail to describe release package when endpoint fails":       initFailToDescribeReleasePackageWhenEndpointFailsTC,
 	}
 
 	for tcname := range testcases {
@@ -632,9 +579,9 @@ func TestDownloadRelease(t *testing.T) {
 			tc := tcinit(t)
 			client := peclapi.NewClient(peclapi.WithHttpClient(tc.httpClient))
 
-			tarr, err := client.DownloadRelease(tc.release)
+			pkg, err := client.DescribeReleasePackage(context.Background(), "redis", "5.1.1")
 			if tc.expectedErr != nil {
-				if err == nil || err.Error() != tc.expectedErr.Error() {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
 					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
 				}
 				return
@@ -643,7 +590,10 @@ func TestDownloadRelease(t *testing.T) {
 				t.Fatalf("Unexpected error: %v", err)
 			}
 
-			if diff := deep.Equal(tarr, tc.expected); diff != nil {
+			if pkg.Name != "redis" || pkg.Version.Release != "5.1.1" {
+				t.Fatalf("Expected package redis v5.1.1 - Got: %s v%s", pkg.Name, pkg.Version.Release)
+			}
+			if diff := deep.Equal(pkg.Dependencies.Required.PHP, peclpkg.PHPConstraint{Min: "7.0.0", Max: "7.9.99"}); diff != nil {
 				t.Fatal(diff)
 			}
 		})
@@ -0,0 +1,169 @@
+package peclapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+// WithFS returns a ClientOpt that could be passed to NewClient to change the
+// vfs.FS where release archives are downloaded.
+func WithFS(fs vfs.FS) ClientOpt {
+	return func(c *Client) {
+		c.fs = fs
+	}
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// DownloadRelease downloads the archive of a given package release to a
+// temporary file in downloadDir and returns an io.ReadCloser from which the
+// tgz can be read. The archive is streamed to disk rather than buffered in
+// memory.
+//
+// The temporary file is named after the archive, with a .part suffix. When a
+// previous download of the same archive was interrupted, it's resumed with a
+// HTTP Range request (servers ignoring Range headers make the download start
+// over). Failed downloads are retried, and resumed, according to the retry
+// policy of the Client. The temporary file is removed when the returned
+// io.ReadCloser is closed. It also implements io.Seeker.
+//
+// An error is returned if the HTTP request fails, if a bad status code is
+// returned or if the downloaded file is not a gzip file.
+func (c Client) DownloadRelease(ctx context.Context, release Release, downloadDir string) (io.ReadCloser, error) {
+	if release.PartialURI == "" {
+		return nil, xerrors.Errorf("empty PartialURI")
+	}
+
+	url := release.ArchiveURL()
+	partPath := filepath.Join(downloadDir, path.Base(url)+".part")
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.downloadTo(ctx, url, partPath)
+		if err == nil && resp == nil {
+			break
+		}
+		if !c.retryPolicy.shouldRetry(ctx, attempt, resp, err) {
+			if err != nil {
+				return nil, xerrors.Errorf("could not download %s v%s: %w", release.Package, release.Version, err)
+			}
+			return nil, xerrors.Errorf("could not download %s v%s: expected status code 200, got %d", release.Package, release.Version, resp.StatusCode)
+		}
+
+		delay := c.retryPolicy.Delay(attempt, resp)
+		logRetry(url, attempt, c.retryPolicy, delay, resp, err)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, xerrors.Errorf("could not download %s v%s: %w", release.Package, release.Version, err)
+		}
+	}
+
+	f, err := c.fs.Open(partPath)
+	if err != nil {
+		return nil, xerrors.Errorf("could not download %s v%s: %w", release.Package, release.Version, err)
+	}
+
+	// Archives are checked once fully downloaded, such that archives
+	// resumed from a previous download are checked too.
+	head := make([]byte, len(gzipMagic))
+	if _, err := io.ReadFull(f, head); err != nil || !bytes.Equal(head, gzipMagic) {
+		f.Close()
+		c.fs.Remove(partPath)
+		return nil, xerrors.Errorf("the file downloaded at %s isn't a gzip file", url)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, xerrors.Errorf("could not download %s v%s: %w", release.Package, release.Version, err)
+	}
+
+	return downloadedArchive{File: f, fs: c.fs, path: partPath}, nil
+}
+
+// downloadTo downloads the file at url to path, resuming from the current
+// size of the file at path, if any. When the server returns an unexpected
+// status code, the response is returned (with its body closed) along with a
+// nil error. Both the returned response and error are nil when the download
+// succeeds.
+func (c Client) downloadTo(ctx context.Context, url, path string) (*http.Response, error) {
+	f, err := c.fs.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := fi.Size()
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := c.getOnce(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		logrus.Debugf("Resuming download of %s from byte %d.", url, offset)
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	case http.StatusOK:
+		if err := f.Truncate(0); err != nil {
+			return nil, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// No Range header was sent: the download already started over or the
+		// server is misbehaving. The response is returned such that the
+		// download isn't started over endlessly.
+		if offset == 0 {
+			return resp, nil
+		}
+		// The partial file is as big as (or bigger than) the archive, there's
+		// no way to know whether it's valid so the download starts over, only
+		// once as the partial file is now empty.
+		logrus.Debugf("Could not resume download of %s, starting over.", url)
+		if err := f.Truncate(0); err != nil {
+			return nil, err
+		}
+		f.Close()
+		return c.downloadTo(ctx, url, path)
+	default:
+		return resp, nil
+	}
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return nil, err
+	}
+
+	return nil, f.Close()
+}
+
+// downloadedArchive is a downloaded archive, removed when it's closed.
+type downloadedArchive struct {
+	*os.File
+	fs   vfs.FS
+	path string
+}
+
+func (a downloadedArchive) Close() error {
+	err := a.File.Close()
+	if rmErr := a.fs.Remove(a.path); rmErr != nil && err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,251 @@
+package peclapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+const (
+	archiveURL = "https://pecl.php.net/get/redis-5.1.1.tgz"
+	partPath   = "/downloads/redis-5.1.1.tgz.part"
+)
+
+var redisRelease = peclapi.Release{
+	Package:    "redis",
+	Version:    "5.1.1",
+	PartialURI: "https://pecl.php.net/get/redis-5.1.1",
+}
+
+// failingReader returns the content of r and then fails with err.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (fr failingReader) Read(p []byte) (int, error) {
+	n, err := fr.r.Read(p)
+	if err == io.EOF {
+		return n, fr.err
+	}
+	return n, err
+}
+
+// rangeRoundTripper checks the Range header of each request and returns the
+// responses in order.
+func rangeRoundTripper(t *testing.T, expectedRanges []string, resps []*http.Response) testRoundTripper {
+	var calls int
+	return func(req *http.Request) *http.Response {
+		if req.URL.String() != archiveURL {
+			t.Fatalf("Expected URL: %s - Got: %s", archiveURL, req.URL)
+		}
+		if calls >= len(resps) {
+			t.Fatalf("Unexpected request #%d", calls+1)
+		}
+		if req.Header.Get("Range") != expectedRanges[calls] {
+			t.Fatalf("Expected Range header: %q - Got: %q", expectedRanges[calls], req.Header.Get("Range"))
+		}
+
+		resp := resps[calls]
+		calls++
+		return resp
+	}
+}
+
+func newResponse(statusCode int, body io.Reader, contentLength int64) *http.Response {
+	return &http.Response{
+		StatusCode:    statusCode,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(body),
+		ContentLength: contentLength,
+	}
+}
+
+type downloadReleaseTC struct {
+	roundTripper testRoundTripper
+	retryPolicy  peclapi.RetryPolicy
+	partFile     []byte
+	expected     []byte
+	expectedErr  error
+}
+
+func initSuccessfullyDownloadReleaseTC(t *testing.T) downloadReleaseTC {
+	body := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{""}, []*http.Response{
+			newResponse(200, bytes.NewReader(body), int64(len(body))),
+		}),
+		expected: body,
+	}
+}
+
+func initSuccessfullyDownloadReleaseWithoutContentLengthTC(t *testing.T) downloadReleaseTC {
+	body := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{""}, []*http.Response{
+			newResponse(200, bytes.NewReader(body), -1),
+		}),
+		expected: body,
+	}
+}
+
+func initResumeInterruptedDownloadTC(t *testing.T) downloadReleaseTC {
+	body := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{"bytes=100-"}, []*http.Response{
+			newResponse(206, bytes.NewReader(body[100:]), int64(len(body)-100)),
+		}),
+		partFile: body[:100],
+		expected: body,
+	}
+}
+
+func initStartOverWhenServerIgnoresRangeTC(t *testing.T) downloadReleaseTC {
+	body := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{"bytes=5-"}, []*http.Response{
+			newResponse(200, bytes.NewReader(body), int64(len(body))),
+		}),
+		partFile: []byte("stale"),
+		expected: body,
+	}
+}
+
+func initStartOverWhenRangeIsNotSatisfiableTC(t *testing.T) downloadReleaseTC {
+	body := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{"bytes=5-", ""}, []*http.Response{
+			newResponse(416, bytes.NewBufferString(""), 0),
+			newResponse(200, bytes.NewReader(body), int64(len(body))),
+		}),
+		partFile: []byte("stale"),
+		expected: body,
+	}
+}
+
+func initFailWhenRangeIsNeverSatisfiableTC(t *testing.T) downloadReleaseTC {
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{"bytes=5-", ""}, []*http.Response{
+			newResponse(416, bytes.NewBufferString(""), 0),
+			newResponse(416, bytes.NewBufferString(""), 0),
+		}),
+		partFile:    []byte("stale"),
+		expectedErr: fmt.Errorf("could not download redis v5.1.1: expected status code 200, got 416"),
+	}
+}
+
+func initResumeDownloadWhenRetryingTC(t *testing.T) downloadReleaseTC {
+	body := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+	interrupted := failingReader{
+		r:   bytes.NewReader(body[:100]),
+		err: errors.New("connection reset by peer"),
+	}
+
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{"", "bytes=100-"}, []*http.Response{
+			newResponse(200, interrupted, int64(len(body))),
+			newResponse(206, bytes.NewReader(body[100:]), int64(len(body)-100)),
+		}),
+		retryPolicy: testRetryPolicy,
+		expected:    body,
+	}
+}
+
+func initFailToDownloadReleaseWhenStatusCodeIsNot200TC(t *testing.T) downloadReleaseTC {
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{""}, []*http.Response{
+			newResponse(500, bytes.NewBufferString(""), 0),
+		}),
+		expectedErr: fmt.Errorf("could not download redis v5.1.1: expected status code 200, got 500"),
+	}
+}
+
+func initFailToDownloadReleaseWhenDownloadedFileIsNotGzipTC(t *testing.T) downloadReleaseTC {
+	body := loadRawTestdata(t, "testdata/redis-5.2.0.xml")
+
+	return downloadReleaseTC{
+		roundTripper: rangeRoundTripper(t, []string{""}, []*http.Response{
+			newResponse(200, bytes.NewReader(body), int64(len(body))),
+		}),
+		expectedErr: fmt.Errorf("the file downloaded at https://pecl.php.net/get/redis-5.1.1.tgz isn't a gzip file"),
+	}
+}
+
+func TestDownloadRelease(t *testing.T) {
+	testcases := map[string]func(*testing.T) downloadReleaseTC{
+		"successfully download release":                        initSuccessfullyDownloadReleaseTC,
+		"successfully download release without Content-Length": initSuccessfullyDownloadReleaseWithoutContentLengthTC,
+		"resume an interrupted download":                       initResumeInterruptedDownloadTC,
+		"start over when the server ignores Range":             initStartOverWhenServerIgnoresRangeTC,
+		"start over when Range is not satisfiable":             initStartOverWhenRangeIsNotSatisfiableTC,
+		"fail when Range is never satisfiable":                 initFailWhenRangeIsNeverSatisfiableTC,
+		"resume the download when retrying":                    initResumeDownloadWhenRetryingTC,
+		"fail when status code is not 200":                     initFailToDownloadReleaseWhenStatusCodeIsNot200TC,
+		"fail when downloaded file is not a gzip file":         initFailToDownloadReleaseWhenDownloadedFileIsNotGzipTC,
+	}
+
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+
+			files := map[string]interface{}{
+				"/downloads": &vfst.Dir{Perm: 0750},
+			}
+			if tc.partFile != nil {
+				files[partPath] = tc.partFile
+			}
+			fs, cleanup, err := vfst.NewTestFS(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			client := peclapi.NewClient(
+				peclapi.WithHttpClient(newTestClient(tc.roundTripper)),
+				peclapi.WithRetryPolicy(tc.retryPolicy),
+				peclapi.WithFS(fs))
+
+			r, err := client.DownloadRelease(context.Background(), redisRelease, "/downloads")
+			if tc.expectedErr != nil {
+				if err == nil || err.Error() != tc.expectedErr.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			raw, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(raw, tc.expected) {
+				t.Fatalf("Downloaded archive doesn't match the expected one.")
+			}
+
+			if err := r.Close(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			vfst.RunTests(t, fs, "temporary file removed",
+				vfst.TestPath(partPath, vfst.TestDoesNotExist))
+		})
+	}
+}
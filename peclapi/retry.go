@@ -0,0 +1,127 @@
+package peclapi
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetryPolicy defines how failed requests are retried. Network errors and
+// responses with a 429 or 5xx status code are retried with an exponential
+// backoff: the nth retry happens after a random delay between d/2 and d,
+// where d = InitialDelay * 2^(n-1), capped at MaxDelay. When the server sends
+// a Retry-After header, its value is used instead (still capped at MaxDelay).
+// The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent, including
+	// the first attempt.
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+// DefaultRetryPolicy is a RetryPolicy suitable for pecl.php.net.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  3,
+	InitialDelay: time.Second,
+	MaxDelay:     30 * time.Second,
+}
+
+// WithRetryPolicy returns a ClientOpt that could be passed to NewClient to
+// set how failed requests are retried. By default, requests aren't retried.
+func WithRetryPolicy(policy RetryPolicy) ClientOpt {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// Delay returns how long to wait before retrying a request after the given
+// attempt (starting at 1) failed. resp is the response received for that
+// attempt, if any.
+func (p RetryPolicy) Delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return p.capDelay(d)
+		}
+	}
+
+	backoff := p.InitialDelay
+	for i := 1; i < attempt && backoff < p.MaxDelay; i++ {
+		backoff *= 2
+	}
+	backoff = p.capDelay(backoff)
+	if backoff <= 1 {
+		return backoff
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
+
+func (p RetryPolicy) capDelay(d time.Duration) time.Duration {
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or a HTTP date.
+func parseRetryAfter(val string) (time.Duration, bool) {
+	if val == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// shouldRetry returns whether a request that got the given response or error
+// at the given attempt should be sent again.
+func (p RetryPolicy) shouldRetry(ctx context.Context, attempt int, resp *http.Response, err error) bool {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	return err != nil || isRetryableStatus(resp.StatusCode)
+}
+
+// sleep waits for the given duration or until ctx is done, in which case it
+// returns ctx's error.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func logRetry(url string, attempt int, policy RetryPolicy, delay time.Duration, resp *http.Response, err error) {
+	reason := "unknown error"
+	if err != nil {
+		reason = err.Error()
+	} else if resp != nil {
+		reason = "status code " + strconv.Itoa(resp.StatusCode)
+	}
+	logrus.Debugf("Attempt %d/%d to fetch %s failed (%s), retrying in %s.",
+		attempt, policy.MaxAttempts, url, reason, delay)
+}
@@ -0,0 +1,261 @@
+package peclapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/go-test/deep"
+)
+
+type attempt struct {
+	statusCode int
+	header     http.Header
+	body       string
+	err        error
+}
+
+// sequenceRoundTripper returns the given attempts in order, one per request.
+type sequenceRoundTripper struct {
+	t        *testing.T
+	attempts []attempt
+	calls    int
+}
+
+func (rt *sequenceRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.calls >= len(rt.attempts) {
+		rt.t.Fatalf("Unexpected request #%d to %s", rt.calls+1, req.URL)
+	}
+	a := rt.attempts[rt.calls]
+	rt.calls++
+
+	if a.err != nil {
+		return nil, a.err
+	}
+	header := a.header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: a.statusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(a.body)),
+	}, nil
+}
+
+var testRetryPolicy = peclapi.RetryPolicy{
+	MaxAttempts:  3,
+	InitialDelay: time.Millisecond,
+	MaxDelay:     5 * time.Millisecond,
+}
+
+type retryTC struct {
+	attempts      []attempt
+	policy        peclapi.RetryPolicy
+	expected      []string
+	expectedErr   error
+	expectedCalls int
+}
+
+func initRetryUntilSuccessTC(t *testing.T) retryTC {
+	body := loadTestdata(t, "testdata/list-packages.xml")
+
+	return retryTC{
+		attempts: []attempt{
+			{statusCode: 503},
+			{err: errors.New("connection reset by peer")},
+			{statusCode: 200, body: body},
+		},
+		policy:        testRetryPolicy,
+		expected:      []string{"amqp", "AOP", "ev"},
+		expectedCalls: 3,
+	}
+}
+
+func initRetryHonorsRetryAfterTC(t *testing.T) retryTC {
+	body := loadTestdata(t, "testdata/list-packages.xml")
+
+	return retryTC{
+		attempts: []attempt{
+			{statusCode: 429, header: http.Header{"Retry-After": []string{"0"}}},
+			{statusCode: 200, body: body},
+		},
+		policy:        testRetryPolicy,
+		expected:      []string{"amqp", "AOP", "ev"},
+		expectedCalls: 2,
+	}
+}
+
+func initGiveUpAfterMaxAttemptsTC(t *testing.T) retryTC {
+	return retryTC{
+		attempts: []attempt{
+			{statusCode: 502},
+			{statusCode: 503},
+			{statusCode: 504},
+		},
+		policy:        testRetryPolicy,
+		expectedErr:   fmt.Errorf("could not list packages: expected status code 200, got 504"),
+		expectedCalls: 3,
+	}
+}
+
+func initDoNotRetryClientErrorsTC(t *testing.T) retryTC {
+	return retryTC{
+		attempts: []attempt{
+			{statusCode: 403},
+		},
+		policy:        testRetryPolicy,
+		expectedErr:   fmt.Errorf("could not list packages: expected status code 200, got 403"),
+		expectedCalls: 1,
+	}
+}
+
+func initDoNotRetryByDefaultTC(t *testing.T) retryTC {
+	return retryTC{
+		attempts: []attempt{
+			{statusCode: 503},
+		},
+		expectedErr:   fmt.Errorf("could not list packages: expected status code 200, got 503"),
+		expectedCalls: 1,
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	testcases := map[string]func(*testing.T) retryTC{
+		"retry network errors and 5xx responses until success": initRetryUntilSuccessTC,
+		"retry 429 responses and honor Retry-After":            initRetryHonorsRetryAfterTC,
+		"give up after max attempts":                           initGiveUpAfterMaxAttemptsTC,
+		"do not retry 4xx responses":                           initDoNotRetryClientErrorsTC,
+		"do not retry by default":                              initDoNotRetryByDefaultTC,
+	}
+
+	for tcname := range testcases {
+		tcinit := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			tc := tcinit(t)
+			rt := &sequenceRoundTripper{t: t, attempts: tc.attempts}
+			client := peclapi.NewClient(
+				peclapi.WithHttpClient(&http.Client{Transport: rt}),
+				peclapi.WithRetryPolicy(tc.policy))
+
+			list, err := client.ListPackages(context.Background())
+			if rt.calls != tc.expectedCalls {
+				t.Fatalf("Expected %d requests - Got: %d", tc.expectedCalls, rt.calls)
+			}
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(list, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyStopsWhenContextIsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := &sequenceRoundTripper{t: t, attempts: []attempt{{statusCode: 503}}}
+	client := peclapi.NewClient(
+		peclapi.WithHttpClient(&http.Client{Transport: rt}),
+		peclapi.WithRetryPolicy(peclapi.RetryPolicy{
+			MaxAttempts:  3,
+			InitialDelay: time.Hour,
+			MaxDelay:     time.Hour,
+		}))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := client.ListPackages(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled error - Got: %v", err)
+	}
+	if rt.calls != 1 {
+		t.Fatalf("Expected 1 request - Got: %d", rt.calls)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := peclapi.RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: time.Second,
+		MaxDelay:     10 * time.Second,
+	}
+
+	testcases := map[string]struct {
+		attempt    int
+		retryAfter string
+		min        time.Duration
+		max        time.Duration
+	}{
+		"first retry": {
+			attempt: 1,
+			min:     500 * time.Millisecond,
+			max:     time.Second,
+		},
+		"third retry": {
+			attempt: 3,
+			min:     2 * time.Second,
+			max:     4 * time.Second,
+		},
+		"backoff is capped at max delay": {
+			attempt: 10,
+			min:     5 * time.Second,
+			max:     10 * time.Second,
+		},
+		"Retry-After in seconds": {
+			attempt:    1,
+			retryAfter: "7",
+			min:        7 * time.Second,
+			max:        7 * time.Second,
+		},
+		"Retry-After is capped at max delay": {
+			attempt:    1,
+			retryAfter: "3600",
+			min:        10 * time.Second,
+			max:        10 * time.Second,
+		},
+		"invalid Retry-After is ignored": {
+			attempt:    1,
+			retryAfter: "soon",
+			min:        500 * time.Millisecond,
+			max:        time.Second,
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{Header: http.Header{}}
+			if tc.retryAfter != "" {
+				resp.Header.Set("Retry-After", tc.retryAfter)
+			}
+
+			delay := policy.Delay(tc.attempt, resp)
+			if delay < tc.min || delay > tc.max {
+				t.Fatalf("Expected a delay between %s and %s - Got: %s", tc.min, tc.max, delay)
+			}
+		})
+	}
+}
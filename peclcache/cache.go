@@ -0,0 +1,362 @@
+// Package peclcache implements an on-disk cache for the responses served by
+// the pecl REST API and for release archives. The cache is content-addressed:
+// response bodies are stored as blobs named after their sha256 digest, and
+// each cached URL is associated to the digest of its body through an entry.
+//
+// The cache is exposed as a http.RoundTripper, such that it can be used by
+// peclapi.Client through a custom http.Client.
+package peclcache
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/twpayne/go-vfs"
+	"golang.org/x/xerrors"
+)
+
+// Kind indicates whether a cache entry is REST metadata or a release archive.
+type Kind string
+
+const (
+	// Metadata entries are XML documents served by the REST API. They expire
+	// after the metadata TTL of the cache.
+	Metadata Kind = "metadata"
+	// Archive entries are release tarballs. As releases are immutable, they
+	// never expire.
+	Archive Kind = "archive"
+)
+
+// KindFromURL returns the Kind of the entry for the given URL.
+func KindFromURL(url string) Kind {
+	if strings.HasSuffix(url, ".tgz") {
+		return Archive
+	}
+	return Metadata
+}
+
+// TagFilename is the name of the tag written to the cache dir, following the
+// Cache Directory Tagging Specification (https://bford.info/cachedir/), such
+// that it's recognized as a cache by Clear and by backup tools.
+const TagFilename = "CACHEDIR.TAG"
+
+const tagContent = "Signature: 8a477f597d28d172789f06886806bc55\n" +
+	"# This file is a cache directory tag created by notpecl.\n"
+
+// DefaultMetadataTTL is the default duration after which cached metadata are
+// considered stale.
+const DefaultMetadataTTL = time.Hour
+
+// Entry associates a cached URL to the digest of its body.
+type Entry struct {
+	URL       string    `json:"url"`
+	Kind      Kind      `json:"kind"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Cache is an on-disk cache rooted at a given directory.
+type Cache struct {
+	fs          vfs.FS
+	dir         string
+	metadataTTL time.Duration
+	now         func() time.Time
+}
+
+// CacheOpt are functions used by New() to set Cache's internal properties.
+type CacheOpt func(*Cache)
+
+// New creates a new Cache rooted at dir. By default, it uses the host
+// filesystem and metadata expire after DefaultMetadataTTL.
+func New(dir string, opts ...CacheOpt) Cache {
+	c := Cache{
+		fs:          vfs.HostOSFS,
+		dir:         dir,
+		metadataTTL: DefaultMetadataTTL,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithFS returns a CacheOpt that could be passed to New() to change the
+// default vfs.FS used.
+func WithFS(fs vfs.FS) CacheOpt {
+	return func(c *Cache) {
+		c.fs = fs
+	}
+}
+
+// WithMetadataTTL returns a CacheOpt that could be passed to New() to change
+// the duration after which cached metadata are considered stale.
+func WithMetadataTTL(ttl time.Duration) CacheOpt {
+	return func(c *Cache) {
+		c.metadataTTL = ttl
+	}
+}
+
+// WithClock returns a CacheOpt that could be passed to New() to change the
+// function used to get the current time.
+func WithClock(now func() time.Time) CacheOpt {
+	return func(c *Cache) {
+		c.now = now
+	}
+}
+
+// Dir returns the directory where the cache is stored.
+func (c Cache) Dir() string {
+	return c.dir
+}
+
+func (c Cache) entriesDir() string {
+	return filepath.Join(c.dir, "entries")
+}
+
+func (c Cache) blobsDir() string {
+	return filepath.Join(c.dir, "blobs", "sha256")
+}
+
+func (c Cache) tagPath() string {
+	return filepath.Join(c.dir, TagFilename)
+}
+
+func (c Cache) tmpDir() string {
+	return filepath.Join(c.dir, "tmp")
+}
+
+func (c Cache) entryPath(url string) string {
+	digest := sha256.Sum256([]byte(url))
+	return filepath.Join(c.entriesDir(), hex.EncodeToString(digest[:])+".json")
+}
+
+func (c Cache) blobPath(digest string) string {
+	return filepath.Join(c.blobsDir(), digest)
+}
+
+// IsExpired returns whether the given entry is stale.
+func (c Cache) IsExpired(e Entry) bool {
+	if e.Kind == Archive {
+		return false
+	}
+	return c.now().Sub(e.FetchedAt) > c.metadataTTL
+}
+
+// Lookup returns the entry associated to the given URL, whether it's still
+// fresh or not. The boolean returned is false when there's no entry for that
+// URL or when the blob it points to is missing.
+func (c Cache) Lookup(url string) (Entry, bool, error) {
+	var e Entry
+
+	raw, err := c.fs.ReadFile(c.entryPath(url))
+	if os.IsNotExist(err) {
+		return e, false, nil
+	} else if err != nil {
+		return e, false, xerrors.Errorf("could not read cache entry for %s: %w", url, err)
+	}
+
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return e, false, xerrors.Errorf("could not read cache entry for %s: %w", url, err)
+	}
+
+	if _, err := c.fs.Stat(c.blobPath(e.Digest)); os.IsNotExist(err) {
+		return e, false, nil
+	} else if err != nil {
+		return e, false, xerrors.Errorf("could not read cache entry for %s: %w", url, err)
+	}
+
+	return e, true, nil
+}
+
+// Open opens the blob of the given entry.
+func (c Cache) Open(e Entry) (*os.File, error) {
+	return c.fs.Open(c.blobPath(e.Digest))
+}
+
+// List returns all the entries in the cache, sorted by URL.
+func (c Cache) List() ([]Entry, error) {
+	infos, err := c.fs.ReadDir(c.entriesDir())
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	} else if err != nil {
+		return nil, xerrors.Errorf("could not list cache entries: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".json" {
+			continue
+		}
+
+		raw, err := c.fs.ReadFile(filepath.Join(c.entriesDir(), info.Name()))
+		if err != nil {
+			return nil, xerrors.Errorf("could not list cache entries: %w", err)
+		}
+
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, xerrors.Errorf("could not list cache entries: %s: %w", info.Name(), err)
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].URL < entries[j].URL
+	})
+
+	return entries, nil
+}
+
+// Clear removes everything the cache wrote to its dir, and the dir itself
+// when nothing else is left in it. Dirs without the tag written by the cache
+// (see TagFilename) are left untouched, such that pointing the cache to the
+// wrong dir doesn't wipe unrelated files.
+func (c Cache) Clear() error {
+	if _, err := c.fs.Stat(c.dir); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := c.fs.Stat(c.tagPath()); os.IsNotExist(err) {
+		return xerrors.Errorf("could not clear the cache: %s doesn't look like a cache dir (no %s found)", c.dir, TagFilename)
+	} else if err != nil {
+		return xerrors.Errorf("could not clear the cache: %w", err)
+	}
+
+	for _, path := range []string{
+		c.entriesDir(),
+		filepath.Dir(c.blobsDir()),
+		c.tmpDir(),
+		c.tagPath(),
+	} {
+		if err := c.fs.RemoveAll(path); err != nil {
+			return xerrors.Errorf("could not clear the cache: %w", err)
+		}
+	}
+
+	left, err := c.fs.ReadDir(c.dir)
+	if err != nil {
+		return xerrors.Errorf("could not clear the cache: %w", err)
+	}
+	if len(left) > 0 {
+		return nil
+	}
+	if err := c.fs.Remove(c.dir); err != nil {
+		return xerrors.Errorf("could not clear the cache: %w", err)
+	}
+	return nil
+}
+
+// Prune removes expired entries, blobs not referenced by any entry and
+// leftovers of interrupted downloads. It returns the number of files removed.
+func (c Cache) Prune() (int, error) {
+	var removed int
+
+	entries, err := c.List()
+	if err != nil {
+		return removed, err
+	}
+
+	referenced := map[string]struct{}{}
+	for _, e := range entries {
+		if c.IsExpired(e) {
+			if err := c.fs.Remove(c.entryPath(e.URL)); err != nil {
+				return removed, xerrors.Errorf("could not prune the cache: %w", err)
+			}
+			removed++
+			continue
+		}
+		referenced[e.Digest] = struct{}{}
+	}
+
+	for _, dir := range []string{c.blobsDir(), c.tmpDir()} {
+		infos, err := c.fs.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return removed, xerrors.Errorf("could not prune the cache: %w", err)
+		}
+
+		for _, info := range infos {
+			if _, ok := referenced[info.Name()]; ok && dir == c.blobsDir() {
+				continue
+			}
+			if err := c.fs.RemoveAll(filepath.Join(dir, info.Name())); err != nil {
+				return removed, xerrors.Errorf("could not prune the cache: %w", err)
+			}
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// createTmpFile creates a new temporary file in the cache dir. Temporary
+// files are moved to the blobs dir once fully written.
+func (c Cache) createTmpFile() (*os.File, string, error) {
+	if err := vfs.MkdirAll(c.fs, c.tmpDir(), 0750); err != nil {
+		return nil, "", err
+	}
+	if err := c.writeTag(); err != nil {
+		return nil, "", err
+	}
+
+	var rnd [8]byte
+	if _, err := rand.Read(rnd[:]); err != nil {
+		return nil, "", err
+	}
+
+	path := filepath.Join(c.tmpDir(), hex.EncodeToString(rnd[:]))
+	f, err := c.fs.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0640)
+	return f, path, err
+}
+
+// writeTag writes the tag identifying the cache dir, unless it already
+// exists.
+func (c Cache) writeTag() error {
+	if _, err := c.fs.Stat(c.tagPath()); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	return c.fs.WriteFile(c.tagPath(), []byte(tagContent), 0640)
+}
+
+// commit moves the temporary file at tmpPath to the blobs dir and writes the
+// entry pointing to it.
+func (c Cache) commit(tmpPath string, e Entry) error {
+	if err := vfs.MkdirAll(c.fs, c.blobsDir(), 0750); err != nil {
+		return err
+	}
+	if err := c.fs.Rename(tmpPath, c.blobPath(e.Digest)); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := vfs.MkdirAll(c.fs, c.entriesDir(), 0750); err != nil {
+		return err
+	}
+
+	// The entry is written to a temporary file first and then renamed, such
+	// that concurrent readers never see partially written entries.
+	tmpEntry, tmpEntryPath, err := c.createTmpFile()
+	if err != nil {
+		return err
+	}
+	_, err = tmpEntry.Write(raw)
+	tmpEntry.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.fs.Rename(tmpEntryPath, c.entryPath(e.URL))
+}
@@ -0,0 +1,241 @@
+package peclcache_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NiR-/notpecl/peclcache"
+	"github.com/twpayne/go-vfs/vfst"
+)
+
+type countingRoundTripper struct {
+	t      *testing.T
+	resps  map[string][]byte
+	status int
+	calls  map[string]int
+}
+
+func newCountingRoundTripper(t *testing.T, status int, resps map[string][]byte) *countingRoundTripper {
+	return &countingRoundTripper{
+		t:      t,
+		resps:  resps,
+		status: status,
+		calls:  map[string]int{},
+	}
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	body, ok := rt.resps[url]
+	if !ok {
+		rt.t.Fatalf("No matching resps found for %s", url)
+	}
+	rt.calls[url]++
+
+	return &http.Response{
+		StatusCode:    rt.status,
+		Body:          ioutil.NopCloser(bytes.NewBuffer(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+func loadRawTestdata(t *testing.T, filepath string) []byte {
+	raw, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func get(t *testing.T, client *http.Client, url string) []byte {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return body
+}
+
+const (
+	releasesURL = "https://pecl.php.net/rest/r/redis/allreleases.xml"
+	archiveURL  = "https://pecl.php.net/get/redis-5.1.1.tgz"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestTransport(t *testing.T) {
+	releases := loadRawTestdata(t, "testdata/redis-releases.xml")
+	tgz := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	clk := &clock{now: time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)}
+	cache := peclcache.New("/cache",
+		peclcache.WithFS(fs),
+		peclcache.WithMetadataTTL(10*time.Minute),
+		peclcache.WithClock(clk.Now))
+
+	upstream := newCountingRoundTripper(t, 200, map[string][]byte{
+		releasesURL: releases,
+		archiveURL:  tgz,
+	})
+	client := &http.Client{Transport: cache.Transport(upstream)}
+
+	for i := 0; i < 2; i++ {
+		if body := get(t, client, releasesURL); !bytes.Equal(body, releases) {
+			t.Fatalf("Unexpected body returned for %s", releasesURL)
+		}
+		if body := get(t, client, archiveURL); !bytes.Equal(body, tgz) {
+			t.Fatalf("Unexpected body returned for %s", archiveURL)
+		}
+	}
+
+	if upstream.calls[releasesURL] != 1 || upstream.calls[archiveURL] != 1 {
+		t.Fatalf("Expected 1 upstream call per URL - Got: %v", upstream.calls)
+	}
+
+	// Once the metadata TTL is elapsed, metadata should be fetched again but
+	// archives should still be served from the cache.
+	clk.now = clk.now.Add(11 * time.Minute)
+	get(t, client, releasesURL)
+	get(t, client, archiveURL)
+
+	if upstream.calls[releasesURL] != 2 || upstream.calls[archiveURL] != 1 {
+		t.Fatalf("Expected 2 upstream calls for metadata and 1 for archive - Got: %v", upstream.calls)
+	}
+
+	entries, err := cache.List()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 cache entries - Got: %d", len(entries))
+	}
+	if entries[0].URL != archiveURL || entries[0].Kind != peclcache.Archive || entries[0].Size != int64(len(tgz)) {
+		t.Fatalf("Unexpected archive entry: %+v", entries[0])
+	}
+	if entries[1].URL != releasesURL || entries[1].Kind != peclcache.Metadata {
+		t.Fatalf("Unexpected metadata entry: %+v", entries[1])
+	}
+}
+
+func TestTransportDoesNotCacheFailedResponses(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	cache := peclcache.New("/cache", peclcache.WithFS(fs))
+	upstream := newCountingRoundTripper(t, 500, map[string][]byte{
+		releasesURL: []byte("Internal Server Error"),
+	})
+	client := &http.Client{Transport: cache.Transport(upstream)}
+
+	get(t, client, releasesURL)
+	get(t, client, releasesURL)
+
+	if upstream.calls[releasesURL] != 2 {
+		t.Fatalf("Expected 2 upstream calls - Got: %d", upstream.calls[releasesURL])
+	}
+}
+
+func TestPruneAndClear(t *testing.T) {
+	releases := loadRawTestdata(t, "testdata/redis-releases.xml")
+	tgz := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	clk := &clock{now: time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)}
+	cache := peclcache.New("/cache",
+		peclcache.WithFS(fs),
+		peclcache.WithMetadataTTL(10*time.Minute),
+		peclcache.WithClock(clk.Now))
+
+	upstream := newCountingRoundTripper(t, 200, map[string][]byte{
+		releasesURL: releases,
+		archiveURL:  tgz,
+	})
+	client := &http.Client{Transport: cache.Transport(upstream)}
+	get(t, client, releasesURL)
+	get(t, client, archiveURL)
+
+	clk.now = clk.now.Add(11 * time.Minute)
+
+	// The expired metadata entry and its blob should be removed.
+	removed, err := cache.Prune()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("Expected 2 files removed - Got: %d", removed)
+	}
+
+	entries, err := cache.List()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].URL != archiveURL {
+		t.Fatalf("Expected only the archive to remain in the cache - Got: %+v", entries)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	vfst.RunTests(t, fs, "cache cleared", vfst.TestPath("/cache", vfst.TestDoesNotExist))
+}
+
+func TestClearLeavesUnrelatedFilesUntouched(t *testing.T) {
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/home/user/.bashrc":            "",
+		"/home/user/blobs/sha256/photo": "",
+		"/cache/CACHEDIR.TAG":           "Signature: 8a477f597d28d172789f06886806bc55\n",
+		"/cache/blobs/sha256/abcdef":    "",
+		"/cache/entries/abcdef.json":    "{}",
+		"/cache/notes.txt":              "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	err = peclcache.New("/home/user", peclcache.WithFS(fs)).Clear()
+	expectedErr := "could not clear the cache: /home/user doesn't look like a cache dir (no CACHEDIR.TAG found)"
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("Expected error: %s\nGot: %v", expectedErr, err)
+	}
+
+	if err := peclcache.New("/cache", peclcache.WithFS(fs)).Clear(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	vfst.RunTests(t, fs, "unrelated files",
+		vfst.TestPath("/home/user/.bashrc", vfst.TestModeIsRegular),
+		vfst.TestPath("/home/user/blobs/sha256/photo", vfst.TestModeIsRegular),
+		vfst.TestPath("/cache/notes.txt", vfst.TestModeIsRegular),
+		vfst.TestPath("/cache/blobs", vfst.TestDoesNotExist),
+		vfst.TestPath("/cache/entries", vfst.TestDoesNotExist),
+		vfst.TestPath("/cache/CACHEDIR.TAG", vfst.TestDoesNotExist))
+}
@@ -0,0 +1,115 @@
+package peclcache
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/twpayne/go-vfs"
+)
+
+// NotAvailableOfflineError is returned by offline transports when the
+// requested URL is neither in the cache nor in the vendor directory.
+type NotAvailableOfflineError struct {
+	URL string
+}
+
+func (err NotAvailableOfflineError) Error() string {
+	return err.URL + " is not available offline"
+}
+
+// OfflineTransport returns a http.RoundTripper that never reaches the
+// network: requests are served from the cache, even when entries are stale,
+// and then from vendor. When vendor is nil or can't serve the request, a
+// NotAvailableOfflineError is returned.
+func (c Cache) OfflineTransport(vendor http.RoundTripper) http.RoundTripper {
+	if vendor == nil {
+		vendor = failingTransport{}
+	}
+	return offlineTransport{
+		transport: transport{cache: c},
+		vendor:    vendor,
+	}
+}
+
+type offlineTransport struct {
+	transport
+	vendor http.RoundTripper
+}
+
+func (t offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet && req.Header.Get("Range") == "" {
+		if resp, ok := t.serveFromCache(req, true); ok {
+			return resp, nil
+		}
+	}
+	return t.vendor.RoundTrip(req)
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, NotAvailableOfflineError{URL: req.URL.String()}
+}
+
+// VendorTransport returns a http.RoundTripper serving files from a vendor
+// directory, without ever reaching the network. The vendor directory mirrors
+// the URLs it serves: https://pecl.php.net/rest/r/redis/allreleases.xml is
+// served from <dir>/pecl.php.net/rest/r/redis/allreleases.xml. As a
+// convenience, release archives can also be put at the root of the vendor
+// directory (eg. <dir>/redis-5.1.1.tgz). A NotAvailableOfflineError is
+// returned for any other file.
+func VendorTransport(fs vfs.FS, dir string) http.RoundTripper {
+	return vendorTransport{fs: fs, dir: dir}
+}
+
+type vendorTransport struct {
+	fs  vfs.FS
+	dir string
+}
+
+func (t vendorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return nil, NotAvailableOfflineError{URL: req.URL.String()}
+	}
+
+	for _, p := range t.candidatePaths(req.URL) {
+		f, err := t.fs.Open(p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if fi.IsDir() {
+			f.Close()
+			continue
+		}
+
+		logrus.Debugf("Serving %s from %s.", req.URL, p)
+		return newCachedResponse(req, Entry{Size: fi.Size()}, f), nil
+	}
+
+	return nil, NotAvailableOfflineError{URL: req.URL.String()}
+}
+
+func (t vendorTransport) candidatePaths(u *url.URL) []string {
+	// path.Clean() on a rooted path removes any .. segment, such that files
+	// outside of the vendor dir can't be served.
+	cleaned := path.Clean("/" + u.Path)
+	paths := []string{
+		filepath.Join(t.dir, u.Host, filepath.FromSlash(cleaned)),
+	}
+	if KindFromURL(u.String()) == Archive {
+		paths = append(paths, filepath.Join(t.dir, path.Base(cleaned)))
+	}
+	return paths
+}
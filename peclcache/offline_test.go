@@ -0,0 +1,81 @@
+package peclcache_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/NiR-/notpecl/peclcache"
+	"github.com/twpayne/go-vfs/vfst"
+	"golang.org/x/xerrors"
+)
+
+type offlineTC struct {
+	url         string
+	expected    []byte
+	expectedErr error
+}
+
+func TestOfflineTransport(t *testing.T) {
+	releases := loadRawTestdata(t, "testdata/redis-releases.xml")
+	tgz := loadRawTestdata(t, "testdata/redis-5.1.1.tgz")
+
+	fs, cleanup, err := vfst.NewTestFS(map[string]interface{}{
+		"/vendor/pecl.php.net/rest/r/redis/allreleases.xml": string(releases),
+		"/vendor/redis-5.1.1.tgz":                           string(tgz),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	cache := peclcache.New("/cache", peclcache.WithFS(fs))
+	vendor := peclcache.VendorTransport(fs, "/vendor")
+	client := &http.Client{Transport: cache.OfflineTransport(vendor)}
+
+	testcases := map[string]offlineTC{
+		"serve metadata from the vendor dir": {
+			url:      releasesURL,
+			expected: releases,
+		},
+		"serve archives from the root of the vendor dir": {
+			url:      archiveURL,
+			expected: tgz,
+		},
+		"fail when the file is not available offline": {
+			url:         "https://pecl.php.net/rest/r/redis/5.2.0.xml",
+			expectedErr: fmt.Errorf("https://pecl.php.net/rest/r/redis/5.2.0.xml is not available offline"),
+		},
+		"fail when the path escapes the vendor dir": {
+			url:         "https://pecl.php.net/../../redis-5.1.1.tgz/../pecl.php.net/rest/r/redis/allreleases.xml/..",
+			expectedErr: fmt.Errorf("https://pecl.php.net/../../redis-5.1.1.tgz/../pecl.php.net/rest/r/redis/allreleases.xml/.. is not available offline"),
+		},
+	}
+
+	for tcname, tc := range testcases {
+		t.Run(tcname, func(t *testing.T) {
+			resp, err := client.Get(tc.url)
+			if tc.expectedErr != nil {
+				var notAvailableErr peclcache.NotAvailableOfflineError
+				if err == nil || !xerrors.As(err, &notAvailableErr) || notAvailableErr.Error() != tc.expectedErr.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(body, tc.expected) {
+				t.Fatalf("Unexpected body returned for %s", tc.url)
+			}
+		})
+	}
+}
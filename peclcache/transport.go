@@ -0,0 +1,188 @@
+package peclcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Transport returns a http.RoundTripper serving GET requests from the cache
+// when a fresh entry exists, and forwarding other requests to next. Successful
+// responses forwarded to next are stored in the cache while they're read.
+func (c Cache) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return transport{cache: c, next: next}
+}
+
+type transport struct {
+	cache Cache
+	next  http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Range requests are used to resume downloads, they can't be served from
+	// (nor stored in) the cache.
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	if resp, ok := t.serveFromCache(req, false); ok {
+		return resp, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	f, tmpPath, err := t.cache.createTmpFile()
+	if err != nil {
+		logrus.Debugf("Could not store %s in the cache: %v", url, err)
+		return resp, nil
+	}
+
+	resp.Body = &cachingBody{
+		ReadCloser: resp.Body,
+		cache:      t.cache,
+		url:        url,
+		tmp:        f,
+		tmpPath:    tmpPath,
+		hasher:     sha256.New(),
+	}
+
+	return resp, nil
+}
+
+// serveFromCache returns a response built from the cache entry associated to
+// the URL of req. Stale entries are only used when allowStale is true.
+func (t transport) serveFromCache(req *http.Request, allowStale bool) (*http.Response, bool) {
+	url := req.URL.String()
+
+	e, ok, err := t.cache.Lookup(url)
+	if err != nil {
+		logrus.Debugf("Could not lookup %s in the cache: %v", url, err)
+		return nil, false
+	}
+	if !ok || (!allowStale && t.cache.IsExpired(e)) {
+		return nil, false
+	}
+
+	f, err := t.cache.Open(e)
+	if err != nil {
+		logrus.Debugf("Could not open cached body for %s: %v", url, err)
+		return nil, false
+	}
+
+	logrus.Debugf("Serving %s from the cache.", url)
+	return newCachedResponse(req, e, f), true
+}
+
+func newCachedResponse(req *http.Request, e Entry, body io.ReadCloser) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          body,
+		ContentLength: e.Size,
+		Request:       req,
+	}
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// cachingBody copies everything read from the underlying response body to a
+// temporary file. Once the body is fully read, the temporary file is moved
+// to the cache. Bodies closed before being fully read aren't cached.
+type cachingBody struct {
+	io.ReadCloser
+	cache     Cache
+	url       string
+	tmp       *os.File
+	tmpPath   string
+	hasher    hash.Hash
+	size      int64
+	head      []byte
+	failed    bool
+	committed bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	for i := 0; i < n && len(b.head) < len(gzipMagic); i++ {
+		b.head = append(b.head, p[i])
+	}
+	if n > 0 && !b.failed {
+		if _, werr := b.tmp.Write(p[:n]); werr != nil {
+			logrus.Debugf("Could not store %s in the cache: %v", b.url, werr)
+			b.failed = true
+		}
+		b.hasher.Write(p[:n])
+		b.size += int64(n)
+	}
+	if err == io.EOF {
+		b.commit()
+	}
+	return n, err
+}
+
+func (b *cachingBody) commit() {
+	if b.committed || b.failed {
+		return
+	}
+	if e := KindFromURL(b.url); e == Archive && !bytes.HasPrefix(b.head, gzipMagic) {
+		logrus.Debugf("Not storing %s in the cache: it's not a gzip file.", b.url)
+		b.failed = true
+		return
+	}
+	b.committed = true
+
+	if err := b.tmp.Close(); err != nil {
+		logrus.Debugf("Could not store %s in the cache: %v", b.url, err)
+		return
+	}
+
+	e := Entry{
+		URL:       b.url,
+		Kind:      KindFromURL(b.url),
+		Digest:    hex.EncodeToString(b.hasher.Sum(nil)),
+		Size:      b.size,
+		FetchedAt: b.cache.now(),
+	}
+	if err := b.cache.commit(b.tmpPath, e); err != nil {
+		logrus.Debugf("Could not store %s in the cache: %v", b.url, err)
+	}
+}
+
+// drainLimit is the maximum number of bytes read from a response body when
+// it's closed before being fully read. XML decoders stop reading right after
+// the root element, so trailing bytes have to be read for the body to be
+// cached.
+const drainLimit = 64 * 1024
+
+func (b *cachingBody) Close() error {
+	if !b.committed && !b.failed {
+		io.CopyN(ioutil.Discard, b, drainLimit)
+	}
+	if !b.committed {
+		b.tmp.Close()
+		b.cache.fs.Remove(b.tmpPath)
+		b.committed = true
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,176 @@
+// Package peclchannel implements the registry of the PEAR channels known by
+// notpecl. The default pecl channel is always known; other channels are added
+// by users and persisted in a JSON config file.
+package peclchannel
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"golang.org/x/xerrors"
+)
+
+// Channel is a channel known by notpecl.
+type Channel struct {
+	// Name is the name of the channel (eg. pecl.php.net).
+	Name string `json:"name"`
+	// Alias is a short name that could be used instead of Name in extension
+	// specs (eg. pecl).
+	Alias string `json:"alias,omitempty"`
+	// Summary is a short description of the channel.
+	Summary string `json:"summary,omitempty"`
+	// BaseURL is the base URL of the REST API of the channel.
+	BaseURL string `json:"base_url"`
+}
+
+// Default is the default pecl channel.
+var Default = Channel{
+	Name:    peclapi.DefaultChannel,
+	Alias:   "pecl",
+	Summary: "PHP Extension Community Library",
+	BaseURL: "https://pecl.php.net/rest",
+}
+
+// FromDiscovery returns the Channel described by the given channel.xml. The
+// alias suggested by the channel is used when alias is empty.
+func FromDiscovery(ch peclapi.Channel, alias string) (Channel, error) {
+	baseURL, err := ch.RESTBaseURL()
+	if err != nil {
+		return Channel{}, err
+	}
+	if alias == "" {
+		alias = ch.SuggestedAlias
+	}
+
+	return Channel{
+		Name:    ch.Name,
+		Alias:   alias,
+		Summary: ch.Summary,
+		BaseURL: baseURL,
+	}, nil
+}
+
+// Registry is the list of channels added by users. The Default channel is
+// implicitly part of every Registry.
+type Registry struct {
+	Channels []Channel `json:"channels"`
+}
+
+// LoadFromFile loads the registry stored at the given path. An empty Registry
+// is returned when the file doesn't exist.
+func LoadFromFile(path string) (Registry, error) {
+	r := Registry{Channels: []Channel{}}
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return r, xerrors.Errorf("could not load channels from %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return r, xerrors.Errorf("could not load channels from %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// WriteToFile writes the registry at the given path. The parent directory is
+// created if needed.
+func (r Registry) WriteToFile(path string) error {
+	raw, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return xerrors.Errorf("could not write channels to %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return xerrors.Errorf("could not write channels to %s: %w", path, err)
+	}
+	if err := ioutil.WriteFile(path, append(raw, '\n'), 0644); err != nil {
+		return xerrors.Errorf("could not write channels to %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// List returns all the known channels: the Default channel first, and then
+// the channels of the registry sorted by name.
+func (r Registry) List() []Channel {
+	channels := make([]Channel, len(r.Channels))
+	copy(channels, r.Channels)
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+
+	return append([]Channel{Default}, channels...)
+}
+
+// Lookup returns the channel with the given name or alias. An empty name
+// designates the Default channel.
+func (r Registry) Lookup(nameOrAlias string) (Channel, bool) {
+	if nameOrAlias == "" {
+		return Default, true
+	}
+	for _, ch := range r.List() {
+		if ch.Name == nameOrAlias || (ch.Alias != "" && ch.Alias == nameOrAlias) {
+			return ch, true
+		}
+	}
+	return Channel{}, false
+}
+
+// Resolve is like Lookup but it returns an error when the channel is unknown.
+func (r Registry) Resolve(nameOrAlias string) (Channel, error) {
+	ch, ok := r.Lookup(nameOrAlias)
+	if !ok {
+		return ch, xerrors.Errorf("unknown channel %q: use \"notpecl channel add\" to add it", nameOrAlias)
+	}
+	return ch, nil
+}
+
+// Add adds a channel to the registry. An error is returned if the name or
+// the alias of the channel is already used by another channel.
+func (r *Registry) Add(ch Channel) error {
+	if ch.Name == "" || ch.BaseURL == "" {
+		return xerrors.Errorf("could not add channel %q: name and base URL are required", ch.Name)
+	}
+	if _, ok := r.Lookup(ch.Name); ok {
+		return xerrors.Errorf("could not add channel %s: channel already exists", ch.Name)
+	}
+	if ch.Alias != "" {
+		if existing, ok := r.Lookup(ch.Alias); ok {
+			return xerrors.Errorf("could not add channel %s: alias %q is already used by %s", ch.Name, ch.Alias, existing.Name)
+		}
+	}
+
+	r.Channels = append(r.Channels, ch)
+	return nil
+}
+
+// Remove removes the channel with the given name or alias from the registry.
+// An error is returned if the channel is unknown or if it's the Default
+// channel.
+func (r *Registry) Remove(nameOrAlias string) error {
+	ch, ok := r.Lookup(nameOrAlias)
+	if !ok {
+		return xerrors.Errorf("could not remove channel %q: unknown channel", nameOrAlias)
+	}
+	if ch.Name == Default.Name {
+		return xerrors.Errorf("could not remove channel %s: it's the default channel", ch.Name)
+	}
+
+	channels := make([]Channel, 0, len(r.Channels))
+	for _, existing := range r.Channels {
+		if existing.Name != ch.Name {
+			channels = append(channels, existing)
+		}
+	}
+	r.Channels = channels
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package peclchannel_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclchannel"
+	"github.com/go-test/deep"
+)
+
+var internalChannel = peclchannel.Channel{
+	Name:    "pecl.example.com",
+	Alias:   "internal",
+	Summary: "Internal PHP extensions",
+	BaseURL: "https://pecl.example.com/rest",
+}
+
+func TestFromDiscovery(t *testing.T) {
+	discovered := peclapi.Channel{
+		Name:           "pecl.example.com",
+		SuggestedAlias: "internal",
+		Summary:        "Internal PHP extensions",
+		BaseURLs: []peclapi.ChannelBaseURL{
+			{Type: "REST1.0", URL: "https://pecl.example.com/rest/"},
+		},
+	}
+
+	ch, err := peclchannel.FromDiscovery(discovered, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := deep.Equal(ch, internalChannel); diff != nil {
+		t.Fatal(diff)
+	}
+
+	ch, err = peclchannel.FromDiscovery(discovered, "acme")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ch.Alias != "acme" {
+		t.Fatalf("Expected alias acme - Got: %s", ch.Alias)
+	}
+}
+
+type addChannelTC struct {
+	registry    peclchannel.Registry
+	channel     peclchannel.Channel
+	expected    []peclchannel.Channel
+	expectedErr error
+}
+
+func TestAdd(t *testing.T) {
+	testcases := map[string]addChannelTC{
+		"successfully add a channel": {
+			registry: peclchannel.Registry{},
+			channel:  internalChannel,
+			expected: []peclchannel.Channel{internalChannel},
+		},
+		"fail when the channel already exists": {
+			registry:    peclchannel.Registry{Channels: []peclchannel.Channel{internalChannel}},
+			channel:     internalChannel,
+			expectedErr: fmt.Errorf("could not add channel pecl.example.com: channel already exists"),
+		},
+		"fail when adding the default channel": {
+			registry:    peclchannel.Registry{},
+			channel:     peclchannel.Default,
+			expectedErr: fmt.Errorf("could not add channel pecl.php.net: channel already exists"),
+		},
+		"fail when the alias is already used": {
+			registry: peclchannel.Registry{},
+			channel: peclchannel.Channel{
+				Name:    "pecl.example.com",
+				Alias:   "pecl",
+				BaseURL: "https://pecl.example.com/rest",
+			},
+			expectedErr: fmt.Errorf("could not add channel pecl.example.com: alias \"pecl\" is already used by pecl.php.net"),
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.registry.Add(tc.channel)
+			if tc.expectedErr != nil {
+				if err == nil || tc.expectedErr.Error() != err.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := deep.Equal(tc.registry.Channels, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestLookupAndRemove(t *testing.T) {
+	r := peclchannel.Registry{Channels: []peclchannel.Channel{internalChannel}}
+
+	for _, nameOrAlias := range []string{"", "pecl", "pecl.php.net"} {
+		if ch, ok := r.Lookup(nameOrAlias); !ok || ch.Name != peclchannel.Default.Name {
+			t.Fatalf("Expected %q to resolve to the default channel - Got: %+v", nameOrAlias, ch)
+		}
+	}
+	if ch, ok := r.Lookup("internal"); !ok || ch.Name != internalChannel.Name {
+		t.Fatalf("Expected internal to resolve to %s - Got: %+v", internalChannel.Name, ch)
+	}
+
+	if err := r.Remove("pecl"); err == nil {
+		t.Fatal("Expected an error when removing the default channel")
+	}
+	if err := r.Remove("internal"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err := r.Resolve("internal")
+	expectedErr := "unknown channel \"internal\": use \"notpecl channel add\" to add it"
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("Expected error: %s\nGot: %v", expectedErr, err)
+	}
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notpecl-channels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "notpecl", "channels.json")
+
+	r, err := peclchannel.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := deep.Equal(r.List(), []peclchannel.Channel{peclchannel.Default}); diff != nil {
+		t.Fatal(diff)
+	}
+
+	if err := r.Add(internalChannel); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.WriteToFile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded, err := peclchannel.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := deep.Equal(loaded, r); diff != nil {
+		t.Fatal(diff)
+	}
+}
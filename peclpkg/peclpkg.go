@@ -5,6 +5,8 @@ import (
 	"encoding/xml"
 	"io"
 	"io/ioutil"
+	"path"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/text/encoding/ianaindex"
@@ -54,14 +56,83 @@ type Package struct {
 	PublishTime   string           `xml:"time"`
 	User          string           `xml:"user"`
 	Email         string           `xml:"email"`
+	Leads         []Maintainer     `xml:"lead"`
+	Developers    []Maintainer     `xml:"developer"`
+	Contributors  []Maintainer     `xml:"contributor"`
+	Helpers       []Maintainer     `xml:"helper"`
 	Version       Version          `xml:"version"`
 	Stability     PackageStability `xml:"stability"`
 	License       License          `xml:"license"`
 	Dependencies  Dependencies     `xml:"dependencies"`
+	Contents      Contents         `xml:"contents"`
 	ExtSrcRelease ExtSrcRelease    `xml:"extsrcrelease"`
 	Changelog     Changelog        `xml:"changelog"`
 }
 
+// Maintainer is a person listed as a lead, a developer, a contributor or a
+// helper of a package.
+type Maintainer struct {
+	Name  string `xml:"name"`
+	User  string `xml:"user"`
+	Email string `xml:"email"`
+	// Active is either "yes" or "no".
+	Active string `xml:"active"`
+}
+
+// Contents is the tree of files shipped with a release, starting at the root
+// dir (usually named "/").
+type Contents struct {
+	Root Dir `xml:"dir"`
+}
+
+// Dir is a directory in the Contents tree. Its Name is relative to its
+// parent dir.
+type Dir struct {
+	Name           string `xml:"name,attr"`
+	BaseInstallDir string `xml:"baseinstalldir,attr"`
+	Dirs           []Dir  `xml:"dir"`
+	Files          []File `xml:"file"`
+}
+
+// File is a file in the Contents tree. Its Name is relative to its parent
+// dir and might contain slashes.
+type File struct {
+	Name           string `xml:"name,attr"`
+	Role           string `xml:"role,attr"`
+	MD5Sum         string `xml:"md5sum,attr"`
+	BaseInstallDir string `xml:"baseinstalldir,attr"`
+}
+
+// Files returns all the files of the Contents tree. Files of a dir come
+// before the files of its subdirs. The Name of the returned files is their
+// path relative to the root dir, and their BaseInstallDir is inherited from
+// their parent dirs when it's not set. Names are not sanitized: they might
+// contain .. and thus point outside of the root dir.
+func (c Contents) Files() []File {
+	return c.Root.files("", "")
+}
+
+func (d Dir) files(parentPath, baseInstallDir string) []File {
+	dirPath := path.Join(parentPath, d.Name)
+	if d.BaseInstallDir != "" {
+		baseInstallDir = d.BaseInstallDir
+	}
+
+	files := make([]File, 0, len(d.Files))
+	for _, f := range d.Files {
+		f.Name = strings.TrimPrefix(path.Join(dirPath, f.Name), "/")
+		if f.BaseInstallDir == "" {
+			f.BaseInstallDir = baseInstallDir
+		}
+		files = append(files, f)
+	}
+	for _, sub := range d.Dirs {
+		files = append(files, sub.files(dirPath, baseInstallDir)...)
+	}
+
+	return files
+}
+
 // Changelog contains all the releases of a specific Package.
 type Changelog struct {
 	Releases []Release `xml:"release"`
@@ -23,6 +23,11 @@ func TestLoadPackageXMLFromFile(t *testing.T) {
 				Description: "This extension provides an API for communicating with Redis servers.",
 				PublishDate: "2019-11-11",
 				PublishTime: "07:36:41",
+				Leads: []peclpkg.Maintainer{
+					{Name: "Michael Grunder", User: "mgrunder", Email: "michael.grunder@gmail.com", Active: "yes"},
+					{Name: "Pavlo Yatsukhnenko", User: "yatsukhnenko", Email: "p.yatsukhnenko@gmail.com", Active: "yes"},
+					{Name: "Nicolas Favre-Felix", User: "nff", Email: "n.favrefelix@gmail.com", Active: "no"},
+				},
 				Version: peclpkg.Version{
 					Release: "5.1.1",
 					API:     "5.1.0",
@@ -108,9 +113,55 @@ This release contains only bugfix for unix-socket connection.
 		// releases wouldn't bring anything more but would make this file
 		// quite big.
 		out.Changelog.Releases = out.Changelog.Releases[:1]
+		// Contents are tested by TestContentsFiles.
+		out.Contents = peclpkg.Contents{}
 
 		if diff := deep.Equal(out, tc.expected); diff != nil {
 			t.Fatal(diff)
 		}
 	}
 }
+
+func TestContentsFiles(t *testing.T) {
+	pkg, err := peclpkg.LoadPackageXMLFromFile("testdata/package-nested-contents.xml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []peclpkg.File{
+		{
+			Name:           "config.m4",
+			Role:           "src",
+			MD5Sum:         "98a3f3f5be95db476d0cd96987410acd",
+			BaseInstallDir: "/",
+		},
+		{
+			Name:           "README.md",
+			Role:           "doc",
+			MD5Sum:         "cddfc6a4f7e128d74045d0f0fad886fa",
+			BaseInstallDir: "/",
+		},
+		{
+			Name:           "tests/001.phpt",
+			Role:           "test",
+			MD5Sum:         "d8565f51f2a88bef02efe295bc7339cf",
+			BaseInstallDir: "tests",
+		},
+		{
+			Name:           "src/ext.c",
+			Role:           "src",
+			MD5Sum:         "b4c97926f7bfba8db5eb6ff503ed87cf",
+			BaseInstallDir: "/",
+		},
+		{
+			Name:           "src/include/ext.h",
+			Role:           "src",
+			MD5Sum:         "2b708e9a4e6694ff3610406b62363f5f",
+			BaseInstallDir: "include",
+		},
+	}
+
+	if diff := deep.Equal(pkg.Contents.Files(), expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,166 @@
+// Package peclsearch implements the search of the packages served by a
+// channel. Packages are matched against their name, their summary and their
+// description, as described by the REST API of the channel.
+package peclsearch
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+)
+
+// DefaultParallelism is the number of packages described at once when Opts
+// doesn't provide any.
+const DefaultParallelism = 8
+
+// Rank indicates how well a package matches a search term. Lower ranks are
+// better matches.
+type Rank int
+
+const (
+	// ExactName means the name of the package is the search term.
+	ExactName Rank = iota
+	// NamePrefix means the name of the package starts with the search term.
+	NamePrefix
+	// Name means the name of the package contains the search term.
+	Name
+	// Summary means the summary of the package contains the search term.
+	Summary
+	// Description means the description of the package contains the search
+	// term.
+	Description
+)
+
+func (r Rank) String() string {
+	switch r {
+	case ExactName:
+		return "exact name"
+	case NamePrefix:
+		return "name prefix"
+	case Name:
+		return "name"
+	case Summary:
+		return "summary"
+	case Description:
+		return "description"
+	}
+	return "unknown"
+}
+
+// Match is a package matching a search term.
+type Match struct {
+	Package peclapi.Package
+	Rank    Rank
+}
+
+type Opts struct {
+	// Category restricts the search to the packages of the given category
+	// (eg. Database).
+	Category string
+	// Parallelism is the maximum number of packages described at once. It
+	// defaults to DefaultParallelism.
+	Parallelism int
+}
+
+// Search lists the packages served by the client (or those of opts.Category)
+// and returns the ones matching the given term, best matches first. Matching
+// is case-insensitive. Packages are described through the REST API to match
+// their summary and description, such that clients should be backed by a
+// cache to not describe every package of the channel each time. Packages that
+// can't be described are skipped.
+func Search(ctx context.Context, client peclapi.Client, term string, opts Opts) ([]Match, error) {
+	var names []string
+	var err error
+	if opts.Category != "" {
+		names, err = client.ListPackagesInCategory(ctx, opts.Category)
+	} else {
+		names, err = client.ListPackages(ctx)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("could not search for %q: %w", term, err)
+	}
+
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+
+	var mu sync.Mutex
+	matches := make([]Match, 0)
+	sem := make(chan struct{}, parallelism)
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for i := range names {
+		name := names[i]
+
+		eg.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-sem }()
+
+			// A package that can't be described (eg. its info.xml is
+			// missing or invalid) shouldn't prevent finding the others.
+			pkg, err := client.DescribePackage(ctx, name)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				logrus.Warnf("Skipping %s: %v", name, err)
+				return nil
+			}
+			if pkg.Name == "" {
+				pkg.Name = name
+			}
+
+			if rank, ok := MatchPackage(pkg, term); ok {
+				mu.Lock()
+				matches = append(matches, Match{Package: pkg, Rank: rank})
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, xerrors.Errorf("could not search for %q: %w", term, err)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank < matches[j].Rank
+		}
+		return strings.ToLower(matches[i].Package.Name) < strings.ToLower(matches[j].Package.Name)
+	})
+
+	return matches, nil
+}
+
+// MatchPackage returns whether the given package matches the search term and
+// how well it does.
+func MatchPackage(pkg peclapi.Package, term string) (Rank, bool) {
+	term = strings.ToLower(term)
+	name := strings.ToLower(pkg.Name)
+
+	switch {
+	case name == term:
+		return ExactName, true
+	case strings.HasPrefix(name, term):
+		return NamePrefix, true
+	case strings.Contains(name, term):
+		return Name, true
+	case strings.Contains(strings.ToLower(pkg.Summary), term):
+		return Summary, true
+	case strings.Contains(strings.ToLower(pkg.Description), term):
+		return Description, true
+	}
+	return 0, false
+}
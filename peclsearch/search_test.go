@@ -0,0 +1,135 @@
+package peclsearch_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/NiR-/notpecl/peclapi"
+	"github.com/NiR-/notpecl/peclsearch"
+	"github.com/go-test/deep"
+)
+
+// testdataRoundTripper serves the package list, the packages of the Database
+// category and the description of packages from the testdata dir. The
+// description of redisearch is missing, such that it can't be described.
+type testdataRoundTripper struct{}
+
+func (testdataRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var file string
+	switch p := strings.TrimPrefix(req.URL.Path, "/rest"); {
+	case p == "/p/packages.xml":
+		file = "testdata/packages.xml"
+	case p == "/c/Database/packages.xml":
+		file = "testdata/category-database.xml"
+	case strings.HasPrefix(p, "/p/") && path.Base(p) == "info.xml":
+		file = "testdata/" + path.Base(path.Dir(p)) + ".xml"
+	}
+
+	body, err := ioutil.ReadFile(file)
+	if file == "" || err != nil {
+		return &http.Response{
+			StatusCode: 404,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+		}, nil
+	}
+
+	return &http.Response{
+		StatusCode:    200,
+		Body:          ioutil.NopCloser(bytes.NewBuffer(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+type searchTC struct {
+	term          string
+	opts          peclsearch.Opts
+	expected      map[string]peclsearch.Rank
+	expectedOrder []string
+	expectedErr   error
+}
+
+func TestSearch(t *testing.T) {
+	testcases := map[string]searchTC{
+		"successfully rank exact and prefix matches first": {
+			term: "Redis",
+			expectedOrder: []string{
+				"redis",
+				"RedisBloom",
+				"phpiredis",
+				"relay",
+				"igbinary",
+			},
+			expected: map[string]peclsearch.Rank{
+				"redis":      peclsearch.ExactName,
+				"RedisBloom": peclsearch.NamePrefix,
+				"phpiredis":  peclsearch.Name,
+				"relay":      peclsearch.Summary,
+				"igbinary":   peclsearch.Description,
+			},
+		},
+		"successfully search in a category": {
+			term: "redis",
+			opts: peclsearch.Opts{Category: "Database", Parallelism: 1},
+			expectedOrder: []string{
+				"redis",
+				"relay",
+			},
+			expected: map[string]peclsearch.Rank{
+				"redis": peclsearch.ExactName,
+				"relay": peclsearch.Summary,
+			},
+		},
+		"successfully find no match": {
+			term:          "memcached-sasl",
+			expectedOrder: []string{},
+			expected:      map[string]peclsearch.Rank{},
+		},
+		"fail to search in an unknown category": {
+			term:        "redis",
+			opts:        peclsearch.Opts{Category: "Unknown"},
+			expectedErr: fmt.Errorf("could not search for \"redis\": could not list packages in Unknown category: category not found"),
+		},
+	}
+
+	for tcname := range testcases {
+		tc := testcases[tcname]
+
+		t.Run(tcname, func(t *testing.T) {
+			t.Parallel()
+
+			client := peclapi.NewClient(peclapi.WithHttpClient(&http.Client{
+				Transport: testdataRoundTripper{},
+			}))
+
+			matches, err := peclsearch.Search(context.Background(), client, tc.term, tc.opts)
+			if tc.expectedErr != nil {
+				if err == nil || err.Error() != tc.expectedErr.Error() {
+					t.Fatalf("Expected error: %v\nGot: %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			order := make([]string, 0, len(matches))
+			ranks := make(map[string]peclsearch.Rank, len(matches))
+			for _, match := range matches {
+				order = append(order, match.Package.Name)
+				ranks[match.Package.Name] = match.Rank
+			}
+			if diff := deep.Equal(order, tc.expectedOrder); diff != nil {
+				t.Fatal(diff)
+			}
+			if diff := deep.Equal(ranks, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	context "context"
 	pecl "github.com/NiR-/notpecl/pecl"
 	peclapi "github.com/NiR-/notpecl/peclapi"
+	peclpkg "github.com/NiR-/notpecl/peclpkg"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -47,6 +48,18 @@ func (mr *MockBackendMockRecorder) Build(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Build", reflect.TypeOf((*MockBackend)(nil).Build), arg0, arg1)
 }
 
+// BuildEnv mocks base method
+func (m *MockBackend) BuildEnv(arg0 pecl.BuildOpts) []string {
+	ret := m.ctrl.Call(m, "BuildEnv", arg0)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// BuildEnv indicates an expected call of BuildEnv
+func (mr *MockBackendMockRecorder) BuildEnv(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildEnv", reflect.TypeOf((*MockBackend)(nil).BuildEnv), arg0)
+}
+
 // Download mocks base method
 func (m *MockBackend) Download(arg0 context.Context, arg1 pecl.DownloadOpts) (string, error) {
 	ret := m.ctrl.Call(m, "Download", arg0, arg1)
@@ -60,6 +73,44 @@ func (mr *MockBackendMockRecorder) Download(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockBackend)(nil).Download), arg0, arg1)
 }
 
+// Enable mocks base method
+func (m *MockBackend) Enable(arg0 context.Context, arg1 pecl.EnableOpts) error {
+	ret := m.ctrl.Call(m, "Enable", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enable indicates an expected call of Enable
+func (mr *MockBackendMockRecorder) Enable(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enable", reflect.TypeOf((*MockBackend)(nil).Enable), arg0, arg1)
+}
+
+// FindLatestReleases mocks base method
+func (m *MockBackend) FindLatestReleases(arg0 context.Context, arg1, arg2 string, arg3 peclapi.Stability) (pecl.LatestReleases, error) {
+	ret := m.ctrl.Call(m, "FindLatestReleases", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(pecl.LatestReleases)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindLatestReleases indicates an expected call of FindLatestReleases
+func (mr *MockBackendMockRecorder) FindLatestReleases(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindLatestReleases", reflect.TypeOf((*MockBackend)(nil).FindLatestReleases), arg0, arg1, arg2, arg3)
+}
+
+// Info mocks base method
+func (m *MockBackend) Info(arg0 context.Context, arg1, arg2 string, arg3 peclapi.Stability) (pecl.ExtensionInfo, error) {
+	ret := m.ctrl.Call(m, "Info", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(pecl.ExtensionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Info indicates an expected call of Info
+func (mr *MockBackendMockRecorder) Info(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Info", reflect.TypeOf((*MockBackend)(nil).Info), arg0, arg1, arg2, arg3)
+}
+
 // Install mocks base method
 func (m *MockBackend) Install(arg0 context.Context, arg1 pecl.InstallOpts) error {
 	ret := m.ctrl.Call(m, "Install", arg0, arg1)
@@ -72,6 +123,33 @@ func (mr *MockBackendMockRecorder) Install(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Install", reflect.TypeOf((*MockBackend)(nil).Install), arg0, arg1)
 }
 
+// LoadSourcePackage mocks base method
+func (m *MockBackend) LoadSourcePackage(arg0 context.Context, arg1, arg2 string) (peclpkg.Package, string, error) {
+	ret := m.ctrl.Call(m, "LoadSourcePackage", arg0, arg1, arg2)
+	ret0, _ := ret[0].(peclpkg.Package)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadSourcePackage indicates an expected call of LoadSourcePackage
+func (mr *MockBackendMockRecorder) LoadSourcePackage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSourcePackage", reflect.TypeOf((*MockBackend)(nil).LoadSourcePackage), arg0, arg1, arg2)
+}
+
+// PHPVersion mocks base method
+func (m *MockBackend) PHPVersion(arg0 context.Context) (string, error) {
+	ret := m.ctrl.Call(m, "PHPVersion", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PHPVersion indicates an expected call of PHPVersion
+func (mr *MockBackendMockRecorder) PHPVersion(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PHPVersion", reflect.TypeOf((*MockBackend)(nil).PHPVersion), arg0)
+}
+
 // ResolveConstraint mocks base method
 func (m *MockBackend) ResolveConstraint(arg0 context.Context, arg1, arg2 string, arg3 peclapi.Stability) (string, error) {
 	ret := m.ctrl.Call(m, "ResolveConstraint", arg0, arg1, arg2, arg3)
@@ -84,3 +162,16 @@ func (m *MockBackend) ResolveConstraint(arg0 context.Context, arg1, arg2 string,
 func (mr *MockBackendMockRecorder) ResolveConstraint(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveConstraint", reflect.TypeOf((*MockBackend)(nil).ResolveConstraint), arg0, arg1, arg2, arg3)
 }
+
+// Uninstall mocks base method
+func (m *MockBackend) Uninstall(arg0 context.Context, arg1 pecl.UninstallOpts) ([]string, error) {
+	ret := m.ctrl.Call(m, "Uninstall", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Uninstall indicates an expected call of Uninstall
+func (mr *MockBackendMockRecorder) Uninstall(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Uninstall", reflect.TypeOf((*MockBackend)(nil).Uninstall), arg0, arg1)
+}